@@ -67,6 +67,12 @@ func newAdmissionControllerCmd(ctx context.Context, logger log.Logger) *cobra.Co
 				},
 			})
 
+			webhookServer.Register("/validate-scylla-scylladb-com-v1alpha1-scyllaclusterautoscaler", &webhook.Admission{
+				Handler: &admission_controller.SCAValidator{
+					Logger: logger,
+				},
+			})
+
 			logger.Info(ctx, "starting manager")
 			if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
 				logger.Error(ctx, "unable to run manager", "err", err)
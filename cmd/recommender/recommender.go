@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"github.com/pkg/errors"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/scylladb/go-log"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
 	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+	"io/ioutil"
+	"k8s.io/client-go/discovery"
+	"math"
+	"net/http"
+	"path/filepath"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
 	"time"
 )
 
@@ -16,12 +24,39 @@ var (
 	metricsInterval    time.Duration
 	metricsSelectorSet map[string]string
 	metricsDefaultStep time.Duration
+	alertsAddress      string
+	alertsTokenFile    string
+	alertsCertDir      string
+
+	queryTimeout      time.Duration
+	queryRetries      int
+	queryRetryBackoff time.Duration
+	breakerThreshold  int
+	breakerCooldown   time.Duration
+	queriesPerSecond  float64
+
+	workers      int
+	queryWorkers int
 )
 
+// maxQueryRetryBackoff caps the backoff between metrics query retries.
+const maxQueryRetryBackoff = 10 * time.Second
+
 func addFlags(cmd *cobra.Command) {
 	cmd.Flags().DurationVarP(&metricsInterval, "interval", "i", time.Minute, "Running interval")
 	cmd.Flags().StringToStringVar(&metricsSelectorSet, "metrics-selector-set", make(map[string]string, 0), "Label selector set for metrics server discovery")
 	cmd.Flags().DurationVar(&metricsDefaultStep, "metrics-default-step", time.Minute, "Metrics ranged queries' default step")
+	cmd.Flags().StringVar(&alertsAddress, "alertmanager-webhook-address", "", "Address of the Alertmanager webhook receiver, disabled if empty")
+	cmd.Flags().StringVar(&alertsTokenFile, "alertmanager-webhook-token-file", "", "File holding the bearer token required by the Alertmanager webhook receiver, which is disabled without it")
+	cmd.Flags().StringVar(&alertsCertDir, "alertmanager-webhook-cert-dir", "", "Directory holding the tls.crt and tls.key the Alertmanager webhook receiver serves TLS with, plain HTTP if empty")
+	cmd.Flags().DurationVar(&queryTimeout, "metrics-query-timeout", 30*time.Second, "Timeout of a single metrics query attempt, no timeout if 0")
+	cmd.Flags().IntVar(&queryRetries, "metrics-query-retries", 3, "Number of retries of metrics queries failing with transient errors")
+	cmd.Flags().DurationVar(&queryRetryBackoff, "metrics-query-retry-backoff", 500*time.Millisecond, "Initial backoff between metrics query retries, doubled after every retry")
+	cmd.Flags().IntVar(&breakerThreshold, "metrics-circuit-breaker-threshold", 5, "Number of consecutive failed metrics queries after which the metrics source is considered unhealthy, disabled if 0")
+	cmd.Flags().DurationVar(&breakerCooldown, "metrics-circuit-breaker-cooldown", time.Minute, "Time for which queries to an unhealthy metrics source are suspended")
+	cmd.Flags().IntVar(&workers, "workers", 4, "Number of SCAs evaluated concurrently")
+	cmd.Flags().IntVar(&queryWorkers, "metrics-query-workers", 8, "Number of metrics queries performed concurrently")
+	cmd.Flags().Float64Var(&queriesPerSecond, "metrics-qps", 20, "Limit of metrics queries per second across all metrics sources, no limit if 0")
 }
 
 func newRecommenderCmd(ctx context.Context, logger log.Logger) *cobra.Command {
@@ -43,20 +78,77 @@ func newRecommenderCmd(ctx context.Context, logger log.Logger) *cobra.Command {
 				return
 			}
 
+			queryPolicy := &metrics.QueryPolicy{
+				Timeout:          queryTimeout,
+				MaxRetries:       queryRetries,
+				RetryBackoff:     queryRetryBackoff,
+				MaxRetryBackoff:  maxQueryRetryBackoff,
+				BreakerThreshold: breakerThreshold,
+				BreakerCooldown:  breakerCooldown,
+			}
+			if queriesPerSecond > 0 {
+				queryPolicy.Limiter = rate.NewLimiter(rate.Limit(queriesPerSecond), int(math.Ceil(queriesPerSecond)))
+			}
+
+			// SCAs may specify their own metrics sources, so a missing default one is not fatal.
+			var pp metrics.Provider
 			pc, err := metrics.NewPrometheusClient(ctx, c, metricsSelectorSet)
 			if err != nil {
-				logger.Fatal(ctx, "create prometheus client", "error", err)
+				logger.Error(ctx, "create default prometheus client", "error", err)
+			} else {
+				pp = metrics.NewResilientProvider(metrics.NewPrometheusProvider(v1.NewAPI(*pc), logger, metricsDefaultStep), *queryPolicy, logger)
+			}
+
+			dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+			if err != nil {
+				logger.Fatal(ctx, "get discovery client", "error", err)
 				return
 			}
 
-			pp := metrics.NewPrometheusProvider(v1.NewAPI(*pc), logger, metricsDefaultStep)
+			var (
+				alertReceiver *metrics.AlertReceiver
+				alerts        <-chan struct{}
+			)
+			if alertsAddress != "" {
+				// The webhook triggers scaling, so it's rather disabled than served without authentication.
+				if alertReceiver, err = newAlertReceiver(logger); err != nil {
+					logger.Error(ctx, "alertmanager webhook disabled", "error", err)
+				} else {
+					alerts = alertReceiver.Notifications()
+
+					mux := http.NewServeMux()
+					mux.Handle("/alerts", alertReceiver)
+					go func() {
+						if err := serveAlerts(mux); err != nil {
+							logger.Fatal(ctx, "serve alertmanager webhook", "error", err)
+						}
+					}()
+				}
+			}
 
-			r := recommender.New(c, pp, logger)
+			r := recommender.New(c, pp, &metrics.Factory{
+				Client:        c,
+				RESTClient:    dc.RESTClient(),
+				AlertReceiver: alertReceiver,
+				Logger:        logger,
+				DefaultStep:   metricsDefaultStep,
+				QueryPolicy:   queryPolicy,
+			}, recommender.Options{
+				Workers:      workers,
+				QueryWorkers: queryWorkers,
+			}, logger)
 
 			ticker := time.Tick(metricsInterval)
-			for range ticker {
-				if err := r.RunOnce(ctx); err != nil {
-					logger.Error(ctx, "running once", "error", err)
+			for {
+				select {
+				case <-ticker:
+					if err := r.RunOnce(ctx); err != nil {
+						logger.Error(ctx, "running once", "error", err)
+					}
+				case <-alerts:
+					if err := r.RunForAlerts(ctx, alertReceiver.PopFired()); err != nil {
+						logger.Error(ctx, "running for alerts", "error", err)
+					}
 				}
 			}
 		},
@@ -65,3 +157,30 @@ func newRecommenderCmd(ctx context.Context, logger log.Logger) *cobra.Command {
 	addFlags(recommenderCmd)
 	return recommenderCmd
 }
+
+func newAlertReceiver(logger log.Logger) (*metrics.AlertReceiver, error) {
+	if alertsTokenFile == "" {
+		return nil, errors.New("token file not specified")
+	}
+	token, err := ioutil.ReadFile(alertsTokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read token")
+	}
+	if alertsCertDir != "" {
+		for _, name := range []string{"tls.crt", "tls.key"} {
+			if _, err := ioutil.ReadFile(filepath.Join(alertsCertDir, name)); err != nil {
+				return nil, errors.Wrap(err, "read certificate")
+			}
+		}
+	}
+
+	return metrics.NewAlertReceiver(logger, strings.TrimSpace(string(token)))
+}
+
+func serveAlerts(handler http.Handler) error {
+	server := &http.Server{Addr: alertsAddress, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	if alertsCertDir != "" {
+		return server.ListenAndServeTLS(filepath.Join(alertsCertDir, "tls.crt"), filepath.Join(alertsCertDir, "tls.key"))
+	}
+	return server.ListenAndServe()
+}
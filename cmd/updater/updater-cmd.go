@@ -12,6 +12,8 @@ import (
 
 func addFlags(cmd *cobra.Command) {
 	cmd.Flags().DurationP("interval", "i", 1*time.Minute, "Update interval")
+	cmd.Flags().String("scylla-manager-url", "", "URL of the Scylla Manager REST API queried for the status of repair and backup tasks, the tasks' schedules are relied on if empty")
+	cmd.Flags().Duration("scylla-manager-timeout", 10*time.Second, "Timeout of a single Scylla Manager query, no timeout if 0")
 }
 
 func newUpdaterCmd(ctx context.Context, logger log.Logger) *cobra.Command {
@@ -23,6 +25,14 @@ func newUpdaterCmd(ctx context.Context, logger log.Logger) *cobra.Command {
 			if err != nil {
 				logger.Fatal(ctx, "get update interval", "err", err)
 			}
+			managerURL, err := cmd.Flags().GetString("scylla-manager-url")
+			if err != nil {
+				logger.Fatal(ctx, "get Scylla Manager URL", "err", err)
+			}
+			managerTimeout, err := cmd.Flags().GetDuration("scylla-manager-timeout")
+			if err != nil {
+				logger.Fatal(ctx, "get Scylla Manager timeout", "err", err)
+			}
 
 			mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 				Scheme: scheme,
@@ -36,7 +46,10 @@ func newUpdaterCmd(ctx context.Context, logger log.Logger) *cobra.Command {
 				logger.Fatal(ctx, "get dynamic client", "error", err)
 			}
 
-			u := updater.NewUpdater(c, logger)
+			u := updater.NewUpdater(c, updater.Options{
+				ScyllaManagerURL:     managerURL,
+				ScyllaManagerTimeout: managerTimeout,
+			}, logger)
 			ticker := time.Tick(updateInterval)
 			for range ticker {
 				if err = u.RunOnce(ctx); err != nil {
@@ -3,15 +3,19 @@ module github.com/scylladb/scylla-operator-autoscaler
 go 1.15
 
 require (
+	github.com/google/cel-go v0.7.3
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/client_golang v1.9.0
 	github.com/prometheus/common v0.15.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/scylladb/go-log v0.0.4
 	github.com/scylladb/scylla-operator v1.0.0
 	github.com/spf13/cobra v1.1.1
 	github.com/stretchr/testify v1.6.1
 	go.uber.org/zap v1.15.0
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
 	golang.org/x/tools v0.0.0-20200616195046-dc31b401abb5 // indirect
+	google.golang.org/genproto v0.0.0-20201110150050-8816d57aaa9a
 	k8s.io/api v0.20.2
 	k8s.io/apimachinery v0.20.2
 	k8s.io/client-go v0.20.2
@@ -0,0 +1,63 @@
+package admission_controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/updater"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SCAValidator rejects ScyllaClusterAutoscalers with invalid scaling rules, e.g. formulas which don't parse,
+// or invalid update policies, e.g. blackout windows with malformed schedules
+type SCAValidator struct {
+	Decoder *admission.Decoder
+	Logger  log.Logger
+}
+
+func (sv *SCAValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	sca := &v1alpha1.ScyllaClusterAutoscaler{}
+	if err := sv.Decoder.Decode(req, sca); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if err := recommender.ValidateMetricsSource(sca.Namespace, sca.Spec.MetricsSource); err != nil {
+		sv.Logger.Debug(ctx, "SCA rejected", "name", sca.Name, "namespace", sca.Namespace, "error", err)
+		return admission.Denied(err.Error())
+	}
+
+	// Scaling policies are only validated when they change, so that SCAs created before a check was introduced
+	// can still be updated, e.g. paused or approved.
+	scalingPolicyChanged := true
+	if req.Operation == admissionv1.Update {
+		old := &v1alpha1.ScyllaClusterAutoscaler{}
+		if err := sv.Decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		scalingPolicyChanged = !equality.Semantic.DeepEqual(old.Spec.ScalingPolicy, sca.Spec.ScalingPolicy)
+	}
+
+	if scalingPolicyChanged {
+		if err := recommender.ValidateScalingPolicy(sca.Spec.ScalingPolicy); err != nil {
+			sv.Logger.Debug(ctx, "SCA rejected", "name", sca.Name, "namespace", sca.Namespace, "error", err)
+			return admission.Denied(err.Error())
+		}
+	}
+
+	if err := updater.ValidateUpdatePolicy(sca.Spec.UpdatePolicy); err != nil {
+		sv.Logger.Debug(ctx, "SCA rejected", "name", sca.Name, "namespace", sca.Namespace, "error", err)
+		return admission.Denied(err.Error())
+	}
+
+	return admission.Allowed("")
+}
+
+func (sv *SCAValidator) InjectDecoder(d *admission.Decoder) error {
+	sv.Decoder = d
+	return nil
+}
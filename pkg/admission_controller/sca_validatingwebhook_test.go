@@ -0,0 +1,184 @@
+package admission_controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSCAValidator(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{Level: atom})
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+
+	newSca := func(rule v1alpha1.ScalingRule) *v1alpha1.ScyllaClusterAutoscaler {
+		return &v1alpha1.ScyllaClusterAutoscaler{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "ScyllaClusterAutoscaler"},
+			ObjectMeta: metav1.ObjectMeta{Name: "sca", Namespace: "sca-ns"},
+			Spec: v1alpha1.ScyllaClusterAutoscalerSpec{
+				ScalingPolicy: &v1alpha1.ScalingPolicy{
+					Datacenters: []v1alpha1.DatacenterScalingPolicy{
+						{
+							Name: "dc",
+							RackScalingPolicies: []v1alpha1.RackScalingPolicy{
+								{
+									Name:         "rack",
+									ScalingRules: []v1alpha1.ScalingRule{rule},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		sca     *v1alpha1.ScyllaClusterAutoscaler
+		old     *v1alpha1.ScyllaClusterAutoscaler
+		allowed bool
+	}{
+		{
+			name: "allow valid formula",
+			sca: newSca(v1alpha1.ScalingRule{
+				Name:        "rule",
+				Expression:  "up",
+				ScalingMode: v1alpha1.ScalingModeHorizontal,
+				Metrics:     []v1alpha1.RuleMetric{{Name: "usage", Expression: "avg(utilization)"}},
+				Formula:     "max(current, ceil(current * usage / 0.6))",
+			}),
+			allowed: true,
+		},
+		{
+			name: "deny formula referencing undefined variable",
+			sca: newSca(v1alpha1.ScalingRule{
+				Name:        "rule",
+				Expression:  "up",
+				ScalingMode: v1alpha1.ScalingModeHorizontal,
+				Formula:     "current * usage",
+			}),
+			allowed: false,
+		},
+		{
+			name: "deny rule without expression",
+			sca: newSca(v1alpha1.ScalingRule{
+				Name:          "rule",
+				ScalingMode:   v1alpha1.ScalingModeHorizontal,
+				ScalingFactor: 2,
+			}),
+			allowed: false,
+		},
+		{
+			name: "deny blackout window with malformed schedule",
+			sca: func() *v1alpha1.ScyllaClusterAutoscaler {
+				sca := newSca(v1alpha1.ScalingRule{
+					Name:          "rule",
+					Expression:    "up",
+					ScalingMode:   v1alpha1.ScalingModeHorizontal,
+					ScalingFactor: 2,
+				})
+				sca.Spec.UpdatePolicy = &v1alpha1.UpdatePolicy{
+					UpdateMode: v1alpha1.UpdateModeAuto,
+					BlackoutWindows: []v1alpha1.BlackoutWindow{
+						{Name: "nightly", Schedule: "every night", Duration: metav1.Duration{Duration: time.Hour}},
+					},
+				}
+				return sca
+			}(),
+			allowed: false,
+		},
+		{
+			name: "allow service in the SCA's namespace",
+			sca: func() *v1alpha1.ScyllaClusterAutoscaler {
+				sca := newSca(v1alpha1.ScalingRule{
+					Name:          "rule",
+					Expression:    "up",
+					ScalingMode:   v1alpha1.ScalingModeHorizontal,
+					ScalingFactor: 2,
+				})
+				sca.Spec.MetricsSource = &v1alpha1.MetricsSource{ServiceRef: &v1alpha1.ServiceRef{Namespace: "sca-ns", Name: "prometheus"}}
+				return sca
+			}(),
+			allowed: true,
+		},
+		{
+			name: "deny service in another namespace",
+			sca: func() *v1alpha1.ScyllaClusterAutoscaler {
+				sca := newSca(v1alpha1.ScalingRule{
+					Name:          "rule",
+					Expression:    "up",
+					ScalingMode:   v1alpha1.ScalingModeHorizontal,
+					ScalingFactor: 2,
+				})
+				sca.Spec.MetricsSource = &v1alpha1.MetricsSource{ServiceRef: &v1alpha1.ServiceRef{Namespace: "monitoring", Name: "prometheus"}}
+				return sca
+			}(),
+			allowed: false,
+		},
+		{
+			name: "allow update of SCA with unchanged invalid scaling policy",
+			sca: func() *v1alpha1.ScyllaClusterAutoscaler {
+				sca := newSca(v1alpha1.ScalingRule{Name: "rule", Expression: "up", ScalingMode: v1alpha1.ScalingModeHorizontal})
+				sca.Annotations = map[string]string{v1alpha1.PausedAnnotation: "true"}
+				return sca
+			}(),
+			old:     newSca(v1alpha1.ScalingRule{Name: "rule", Expression: "up", ScalingMode: v1alpha1.ScalingModeHorizontal}),
+			allowed: true,
+		},
+		{
+			name: "deny update changing scaling policy to invalid one",
+			sca: newSca(v1alpha1.ScalingRule{
+				Name:        "rule",
+				Expression:  "up",
+				ScalingMode: v1alpha1.ScalingModeHorizontal,
+			}),
+			old: newSca(v1alpha1.ScalingRule{
+				Name:          "rule",
+				Expression:    "up",
+				ScalingMode:   v1alpha1.ScalingModeHorizontal,
+				ScalingFactor: 2,
+			}),
+			allowed: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := json.Marshal(test.sca)
+			require.NoError(t, err)
+
+			sv := &SCAValidator{Logger: logger}
+			require.NoError(t, sv.InjectDecoder(decoder))
+
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+			if test.old != nil {
+				oldRaw, err := json.Marshal(test.old)
+				require.NoError(t, err)
+				req.Operation = admissionv1.Update
+				req.OldObject = runtime.RawExtension{Raw: oldRaw}
+			}
+			res := sv.Handle(ctx, req)
+			require.Equal(t, test.allowed, res.Allowed)
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/scylladb/go-log"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
 	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
 	v1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,16 +39,27 @@ func validateClusterChanges(ctx context.Context, logger log.Logger, cluster, old
 			continue
 		}
 
-		if sca.Spec.UpdatePolicy.UpdateMode == v1alpha1.UpdateModeOff {
+		if !util.UpdatesEnabled(sca.Spec.UpdatePolicy) {
 			logger.Debug(ctx, "SCA has 'off' update mode, skipping", "SCA name", sca.Spec.TargetRef.Name)
 			continue
 		}
 
+		pause := util.PauseFromAnnotations(time.Now(), &sca, cluster)
+		if pause.Paused {
+			logger.Debug(ctx, "SCA is paused, skipping", "SCA name", sca.Spec.TargetRef.Name)
+			continue
+		}
+
 		logger.Debug(ctx, "cluster has 'Auto' update mode")
 
 		// check if user is changing resources administered by autoscaler
 		for _, rack := range cluster.Spec.Datacenter.Racks {
 
+			if pause.RackPaused(rack.Name) {
+				logger.Debug(ctx, "rack is paused, skipping", "rack", rack.Name)
+				continue
+			}
+
 			oldRack := scyllav1.RackSpec{}
 			oldRackAssigned := false
 
@@ -59,11 +59,27 @@ func TestValidateClusterChanges(t *testing.T) {
 		corev1.ResourceMemory: resource.MustParse("500M"),
 	}
 
+	pausedDoubleRackWithChangedMembers := doubleRackWithChangedMembers.DeepCopy()
+	pausedDoubleRackWithChangedMembers.Annotations = map[string]string{v1alpha1.PausedAnnotation: "true"}
+
+	rackPausedDoubleRackWithChangedMembers := doubleRackWithChangedMembers.DeepCopy()
+	rackPausedDoubleRackWithChangedMembers.Annotations = map[string]string{v1alpha1.PausedRacksAnnotation: "rack-1"}
+
+	otherRackPausedDoubleRackWithChangedMembers := doubleRackWithChangedMembers.DeepCopy()
+	otherRackPausedDoubleRackWithChangedMembers.Annotations = map[string]string{v1alpha1.PausedRacksAnnotation: "rack-2"}
+
+	expiredPauseDoubleRackWithChangedMembers := doubleRackWithChangedMembers.DeepCopy()
+	expiredPauseDoubleRackWithChangedMembers.Annotations = map[string]string{v1alpha1.PausedAnnotation: "2021-06-07T20:00:00Z"}
+
 	singleRackCluster := doubleRackCluster.DeepCopy()
 	singleRackCluster.Spec.Datacenter.Racks = singleRackCluster.Spec.Datacenter.Racks[:len(singleRackCluster.Spec.Datacenter.Racks)-1]
 
 	autoModeDoubleScaList := unit.NewDoubleScyllaAutoscalerList("test-cluster", "test-cluster-ns", "other-cluster", "test-cluster-ns", autoUpdateMode, autoUpdateMode)
 	offModeDoubleScaList := unit.NewDoubleScyllaAutoscalerList("test-cluster", "test-cluster-ns", "other-cluster", "test-cluster-ns", offUpdateMode, offUpdateMode)
+	scaleUpOnlyDoubleScaList := unit.NewDoubleScyllaAutoscalerList("test-cluster", "test-cluster-ns", "other-cluster", "test-cluster-ns", offUpdateMode, offUpdateMode)
+	scaleUpOnlyDoubleScaList.Items[0].Spec.UpdatePolicy.DirectionalUpdateModes = &v1alpha1.DirectionalUpdateModes{ScaleUp: &autoUpdateMode}
+	pausedDoubleScaList := autoModeDoubleScaList.DeepCopy()
+	pausedDoubleScaList.Items[0].Annotations = map[string]string{v1alpha1.PausedAnnotation: "true"}
 
 	tests := []struct {
 		name            string
@@ -129,6 +145,54 @@ func TestValidateClusterChanges(t *testing.T) {
 			scaledResources: []string{"cpu"},
 			allowed:         true,
 		},
+		{
+			name:            "deny changing member count while SCA in 'OFF' mode applies scale-ups",
+			cluster:         doubleRackWithChangedMembers,
+			oldCluster:      doubleRackCluster,
+			scas:            scaleUpOnlyDoubleScaList,
+			scaledResources: []string{"cpu"},
+			allowed:         false,
+		},
+		{
+			name:            "allow changing member count while SCA is paused",
+			cluster:         doubleRackWithChangedMembers,
+			oldCluster:      doubleRackCluster,
+			scas:            pausedDoubleScaList,
+			scaledResources: []string{"cpu"},
+			allowed:         true,
+		},
+		{
+			name:            "allow changing member count while cluster is paused",
+			cluster:         pausedDoubleRackWithChangedMembers,
+			oldCluster:      doubleRackCluster,
+			scas:            autoModeDoubleScaList,
+			scaledResources: []string{"cpu"},
+			allowed:         true,
+		},
+		{
+			name:            "allow changing member count of paused rack",
+			cluster:         rackPausedDoubleRackWithChangedMembers,
+			oldCluster:      doubleRackCluster,
+			scas:            autoModeDoubleScaList,
+			scaledResources: []string{"cpu"},
+			allowed:         true,
+		},
+		{
+			name:            "deny changing member count of rack other than paused one",
+			cluster:         otherRackPausedDoubleRackWithChangedMembers,
+			oldCluster:      doubleRackCluster,
+			scas:            autoModeDoubleScaList,
+			scaledResources: []string{"cpu"},
+			allowed:         false,
+		},
+		{
+			name:            "deny changing member count after pause expired",
+			cluster:         expiredPauseDoubleRackWithChangedMembers,
+			oldCluster:      doubleRackCluster,
+			scas:            autoModeDoubleScaList,
+			scaledResources: []string{"cpu"},
+			allowed:         false,
+		},
 		{
 			name:            "allow adding new rack to cluster",
 			cluster:         doubleRackCluster,
@@ -61,6 +61,11 @@ type ScyllaClusterAutoscalerSpec struct {
 	// If a rack is not described, it will not undergo autoscaling.
 	// +optional
 	ScalingPolicy *ScalingPolicy `json:"scalingPolicy,omitempty"`
+
+	// MetricsSource references the monitoring service the rules are evaluated against.
+	// If not specified, the recommender's default metrics source is used.
+	// +optional
+	MetricsSource *MetricsSource `json:"metricsSource,omitempty"`
 }
 
 type TargetRef struct {
@@ -69,12 +74,85 @@ type TargetRef struct {
 	Name string `json:"name"`
 }
 
+type MetricsSource struct {
+	// Type of the metrics source. Determines the language of the rules' expressions.
+	// Set to "Prometheus" by default.
+	// +optional
+	// +kubebuilder:default:=Prometheus
+	Type MetricsSourceType `json:"type,omitempty"`
+
+	// ServiceRef references a Prometheus service.
+	// For a Prometheus source, either ServiceRef or URL has to be specified.
+	// +optional
+	ServiceRef *ServiceRef `json:"serviceRef,omitempty"`
+
+	// URL of a Prometheus server.
+	// For a Prometheus source, either ServiceRef or URL has to be specified.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// CredentialsSecretRef references a Secret in the SCA's namespace holding the credentials used to authenticate
+	// against a Prometheus source. The Secret is expected to contain either a "token" key,
+	// or a pair of "username" and "password" keys.
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Prometheus;CustomMetrics;ExternalMetrics;ResourceMetrics;ScyllaAPI;Alertmanager
+type MetricsSourceType string
+
+const (
+	// MetricsSourceTypePrometheus means that the rules' expressions are PromQL queries to a Prometheus server.
+	MetricsSourceTypePrometheus MetricsSourceType = "Prometheus"
+
+	// MetricsSourceTypeCustomMetrics means that the rules' expressions reference pod metrics
+	// served by the custom.metrics.k8s.io API.
+	MetricsSourceTypeCustomMetrics MetricsSourceType = "CustomMetrics"
+
+	// MetricsSourceTypeExternalMetrics means that the rules' expressions reference metrics
+	// served by the external.metrics.k8s.io API.
+	MetricsSourceTypeExternalMetrics MetricsSourceType = "ExternalMetrics"
+
+	// MetricsSourceTypeResourceMetrics means that the rules' expressions reference the CPU and memory usage
+	// of the rack's pods served by the metrics.k8s.io API.
+	MetricsSourceTypeResourceMetrics MetricsSourceType = "ResourceMetrics"
+
+	// MetricsSourceTypeScyllaAPI means that the rules' expressions reference values reported
+	// by the Scylla REST API of the rack's nodes. The API is queried on the pods' IPs, while Scylla binds it
+	// to api_address, which is 127.0.0.1 by default; the target has to set it to the pods' IPs, e.g. with
+	// the "--api-address 0.0.0.0" Scylla argument.
+	MetricsSourceTypeScyllaAPI MetricsSourceType = "ScyllaAPI"
+
+	// MetricsSourceTypeAlertmanager means that the rules' expressions reference alerts
+	// sent by Alertmanager to the Recommender's webhook.
+	MetricsSourceTypeAlertmanager MetricsSourceType = "Alertmanager"
+)
+
+type ServiceRef struct {
+	// Namespace of the service. Has to be the SCA's namespace, to which it defaults.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the service.
+	Name string `json:"name"`
+
+	// Port of the service. Defaults to 9090.
+	// +optional
+	Port int32 `json:"port,omitempty"`
+}
+
 type UpdatePolicy struct {
-	// Determines whether the recommendations are applied to the target. Set to "Auto" by default.
+	// Determines whether the recommendations are applied to the target, and whether they need to be approved first.
+	// Set to "Auto" by default.
 	// +optional
 	// +kubebuilder:default:=Auto
 	UpdateMode UpdateMode `json:"updateMode"`
 
+	// Overrides UpdateMode for the changes of the racks in the given directions, e.g. to apply scale-ups automatically,
+	// but only recommend scale-downs.
+	// +optional
+	DirectionalUpdateModes *DirectionalUpdateModes `json:"directionalUpdateModes,omitempty"`
+
 	// Describes how long the recommendations is valid for after having been saved in a status.
 	// If left blank, recommendations do not expire.
 	// +optional
@@ -84,15 +162,129 @@ type UpdatePolicy struct {
 	// If left blank, there is no cooldown period.
 	// +optional
 	UpdateCooldown *metav1.Duration `json:"updateCooldown,omitempty"`
+
+	// Limits the number of updates of the ScyllaCluster changing the racks' members.
+	// If left blank, the number of such updates is not limited.
+	// +optional
+	HorizontalActionBudget *ActionBudget `json:"horizontalActionBudget,omitempty"`
+
+	// Limits the number of updates of the ScyllaCluster changing the racks' resources.
+	// If left blank, the number of such updates is not limited.
+	// +optional
+	VerticalActionBudget *ActionBudget `json:"verticalActionBudget,omitempty"`
+
+	// Recurring windows, e.g. nightly batch loads, during which recommendations are held instead of being applied.
+	// +optional
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
+
+	// One-off periods, e.g. change freezes, during which recommendations are held instead of being applied.
+	// +optional
+	FreezePeriods []FreezePeriod `json:"freezePeriods,omitempty"`
+
+	// Holds the recommendations while the target's Scylla Manager repair or backup tasks are running or about to run.
+	// +optional
+	ManagerTaskHold *ManagerTaskHold `json:"managerTaskHold,omitempty"`
+}
+
+// DirectionalUpdateModes override the update mode for the changes in the given directions.
+// If the overrides of both the dimension and the direction of a change are set, the more restrictive one applies,
+// "Off" being the most restrictive and "Auto" the least.
+type DirectionalUpdateModes struct {
+	// Update mode of the changes increasing the racks' members or resources.
+	// +optional
+	ScaleUp *UpdateMode `json:"scaleUp,omitempty"`
+
+	// Update mode of the changes decreasing the racks' members or resources.
+	// +optional
+	ScaleDown *UpdateMode `json:"scaleDown,omitempty"`
+
+	// Update mode of the changes of the racks' members.
+	// +optional
+	Horizontal *UpdateMode `json:"horizontal,omitempty"`
+
+	// Update mode of the changes of the racks' resources.
+	// +optional
+	Vertical *UpdateMode `json:"vertical,omitempty"`
+}
+
+// BlackoutWindow is a recurring time window during which the target is not updated.
+type BlackoutWindow struct {
+	// A unique name of the window.
+	Name string `json:"name"`
+
+	// Schedule is a cron expression in the standard five-field format, determining when the window starts.
+	Schedule string `json:"schedule"`
+
+	// Duration is the length of the window.
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA name of the time zone the schedule is evaluated in, e.g. "Europe/Warsaw".
+	// If not set, UTC is used.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// FreezePeriod is a one-off period during which the target is not updated.
+type FreezePeriod struct {
+	// A unique name of the period.
+	Name string `json:"name"`
+
+	// Start of the period.
+	Start metav1.Time `json:"start"`
+
+	// End of the period.
+	End metav1.Time `json:"end"`
 }
 
-// +kubebuilder:validation:Enum=Off;Auto
+// +kubebuilder:validation:Enum=Repair;Backup
+type ManagerTaskType string
+
+const (
+	// ManagerTaskTypeRepair stands for the repair tasks of the ScyllaCluster's spec.
+	ManagerTaskTypeRepair ManagerTaskType = "Repair"
+
+	// ManagerTaskTypeBackup stands for the backup tasks of the ScyllaCluster's spec.
+	ManagerTaskTypeBackup ManagerTaskType = "Backup"
+)
+
+// ManagerTaskHold describes holding the updates around the runs of the target's Scylla Manager tasks,
+// so that scaling doesn't interrupt them.
+type ManagerTaskHold struct {
+	// Types of the tasks holding the updates. If empty, both repairs and backups hold them.
+	// +optional
+	TaskTypes []ManagerTaskType `json:"taskTypes,omitempty"`
+
+	// ExpectedDuration is how long a run of a task is expected to take. Unless the actual status of the tasks
+	// is queried from Scylla Manager, the updates are held for that long after the scheduled start of every run.
+	ExpectedDuration metav1.Duration `json:"expectedDuration"`
+
+	// Lead is how long before the scheduled start of every run the updates are held already, so that the changes
+	// applied right before the run don't overlap with it. If not set, the updates are held from the start.
+	// +optional
+	Lead *metav1.Duration `json:"lead,omitempty"`
+}
+
+// ActionBudget limits the number of scaling actions performed within a rolling time window.
+type ActionBudget struct {
+	// The maximum number of actions performed within the window.
+	// +kubebuilder:validation:Minimum=1
+	MaxActions int32 `json:"maxActions"`
+
+	// The length of the rolling window.
+	Window metav1.Duration `json:"window"`
+}
+
+// +kubebuilder:validation:Enum=Off;Approval;Auto
 type UpdateMode string
 
 const (
 	// UpdateModeOff means that the recommendations are provided but never applied.
 	UpdateModeOff UpdateMode = "Off"
 
+	// UpdateModeApproval means that the recommendations are published for approval,
+	// and applied once approved with ApprovedRecommendationAnnotation.
+	UpdateModeApproval UpdateMode = "Approval"
+
 	// UpdateModeAuto means that the recommendations are applied periodically.
 	UpdateModeAuto UpdateMode = "Auto"
 )
@@ -114,8 +306,14 @@ type DatacenterScalingPolicy struct {
 	RackScalingPolicies []RackScalingPolicy `json:"racks,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
 }
 
+// RackNameWildcard is the name of the datacenter's default rack scaling policy, applying to every rack.
+const RackNameWildcard = "*"
+
 type RackScalingPolicy struct {
 	// Name of a rack subject to autoscaling.
+	// The policy named "*" is the datacenter's default, applying to every rack. Policies of specific racks
+	// are merged on top of it: their fields take precedence and their rules replace the default rules
+	// of the same name.
 	Name string `json:"name"`
 
 	// MemberPolicy describes the limitations on scaling the rack's members.
@@ -136,8 +334,264 @@ type RackScalingPolicy struct {
 	// +patchMergeKey=name
 	// +patchStrategy=merge
 	ScalingRules []ScalingRule `json:"rules,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+
+	// Behavior limits the pace of scaling the rack's members up and down.
+	// If not set, the recommended number of members is the one computed by the applied rule.
+	// +optional
+	Behavior *RackScalingBehavior `json:"behavior,omitempty"`
+
+	// Schedules set the rack's target members, CPU or bounds within recurring time windows,
+	// so that the rack can be scaled in advance of a predictable load.
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	Schedules []ScalingSchedule `json:"schedules,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+
+	// Prediction scales the rack ahead of the peaks of a metric, forecast from its history.
+	// +optional
+	Prediction *PredictiveScaling `json:"prediction,omitempty"`
+
+	// Rightsizing sets the rack's CPU and memory requests from the percentiles of their historical usage,
+	// without any rules.
+	// +optional
+	Rightsizing *RackRightsizing `json:"rightsizing,omitempty"`
+
+	// WarmUp is the period after the rack, changed by the applied recommendations, is ready again, during which
+	// the rack isn't evaluated, so that its metrics can settle, e.g. after streaming data to new members.
+	// Until the rack is ready again, it isn't evaluated either. If not set, the rack is evaluated right after changes.
+	// +optional
+	WarmUp *metav1.Duration `json:"warmUp,omitempty"`
+
+	// Strategy determines how the rules' recommendations translate into scaling the rack.
+	// Neither of the strategies scales the memory, only rightsizing changes it.
+	// If not set, the "Independent" strategy is used.
+	// +optional
+	Strategy RackScalingStrategy `json:"strategy,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Independent;Hybrid
+type RackScalingStrategy string
+
+const (
+	// RackScalingStrategyIndependent means that the rack is scaled horizontally and vertically by the rules
+	// of the respective modes, chosen independently.
+	RackScalingStrategyIndependent RackScalingStrategy = "Independent"
+
+	// RackScalingStrategyHybrid means that the rules' modes are ignored, and the chosen rule scales the rack
+	// vertically until the CPU reaches MaxAllowedCpu before adding members, and removes members
+	// until they reach MinAllowed before shrinking the CPU. Like with vertical rules of the "Independent" strategy,
+	// the memory isn't scaled along with the CPU, only rightsizing changes it.
+	RackScalingStrategyHybrid RackScalingStrategy = "Hybrid"
+)
+
+// PredictiveScaling describes how the rack is scaled ahead of the forecast peaks of a metric.
+type PredictiveScaling struct {
+	// Expression is a numeric query to the monitoring service, whose history is forecast, e.g. the rack's CPU utilization.
+	// It's a Go template, rendered for every rack like the rules' expressions.
+	// The metrics source has to support querying the history of numeric expressions.
+	Expression string `json:"expression"`
+
+	// TargetValue is the value of the expression the rack is scaled to keep at the forecast peak,
+	// assuming the expression is proportional to the load per member, or per CPU, of the rack.
+	TargetValue float64 `json:"targetValue"`
+
+	// Mode determines whether the rack is scaled horizontally or vertically.
+	Mode ScalingMode `json:"mode"`
+
+	// Method of forecasting. Set to "HoltWinters" by default.
+	// +optional
+	// +kubebuilder:default:=HoltWinters
+	Method ForecastMethod `json:"method,omitempty"`
+
+	// History is how far back the history of the expression is queried. It has to cover at least two seasons.
+	// Set to 2 weeks by default.
+	// +optional
+	History *metav1.Duration `json:"history,omitempty"`
+
+	// Season is the length of the expression's recurring pattern. Set to 24 hours by default.
+	// +optional
+	Season *metav1.Duration `json:"season,omitempty"`
+
+	// Step is the resolution of the history and of the forecast. Set to 5 minutes by default.
+	// +optional
+	Step *metav1.Duration `json:"step,omitempty"`
+
+	// Horizon is how far ahead the peaks are forecast, i.e. how long in advance of a peak the rack is scaled.
+	// Set to 1 hour by default.
+	// +optional
+	Horizon *metav1.Duration `json:"horizon,omitempty"`
+}
+
+// RackRightsizing describes how the rack's resource requests are sized from the percentiles of their historical usage.
+type RackRightsizing struct {
+	// Window is how far back the usage is considered. Set to 8 days by default.
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+
+	// Step is the resolution of the usage history. Set to 5 minutes by default.
+	// +optional
+	Step *metav1.Duration `json:"step,omitempty"`
+
+	// CpuPercentile is the percentile of the CPU usage the CPU requests are sized for. Set to 90 by default.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	CpuPercentile *int32 `json:"cpuPercentile,omitempty"`
+
+	// MemoryPercentile is the percentile of the memory usage the memory requests are sized for. Set to 95 by default.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MemoryPercentile *int32 `json:"memoryPercentile,omitempty"`
+
+	// SafetyMarginPercent is the margin added on top of the percentiles, as a percentage of them. Set to 15 by default.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	SafetyMarginPercent *int32 `json:"safetyMarginPercent,omitempty"`
+
+	// TolerancePercent is how much, as a percentage of the current requests, the sized requests have to differ from them
+	// to be recommended, so that the rack isn't restarted for small changes. Set to 10 by default.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	TolerancePercent *int32 `json:"tolerancePercent,omitempty"`
+
+	// CpuExpression is a query to the monitoring service of the CPU usage of a member of the rack, in cores.
+	// It's a Go template, rendered for every rack like the rules' expressions. If not set, the usage
+	// of the busiest member's Scylla container, as reported by cAdvisor, is used.
+	// +optional
+	CpuExpression string `json:"cpuExpression,omitempty"`
+
+	// MemoryExpression is a query to the monitoring service of the memory usage of a member of the rack, in bytes.
+	// It's a Go template, rendered for every rack like the rules' expressions. If not set, the working set
+	// of the busiest member's Scylla container, as reported by cAdvisor, is used.
+	// +optional
+	MemoryExpression string `json:"memoryExpression,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=HoltWinters;SeasonalNaive
+type ForecastMethod string
+
+const (
+	// ForecastMethodHoltWinters means that the forecast follows the level, trend and seasonality of the history,
+	// with additive Holt-Winters exponential smoothing.
+	ForecastMethodHoltWinters ForecastMethod = "HoltWinters"
+
+	// ForecastMethodSeasonalNaive means that the forecast repeats the last season of the history.
+	ForecastMethodSeasonalNaive ForecastMethod = "SeasonalNaive"
+)
+
+// ScalingSchedule describes a recurring time window, within which the rack's targets or bounds are overridden.
+type ScalingSchedule struct {
+	// A unique name of the schedule.
+	Name string `json:"name"`
+
+	// Schedule is a cron expression in the standard five-field format, determining when the window starts.
+	Schedule string `json:"schedule"`
+
+	// Duration is the length of the window.
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA name of the time zone the schedule is evaluated in, e.g. "Europe/Warsaw".
+	// If not set, UTC is used.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Members is the rack's target number of members within the window, recommended unless a horizontal rule is triggered.
+	// +optional
+	Members *int32 `json:"members,omitempty"`
+
+	// Cpu is the rack's target CPU within the window, recommended unless a vertical rule is triggered.
+	// +optional
+	Cpu *resource.Quantity `json:"cpu,omitempty"`
+
+	// MinAllowed overrides the lowest allowed number of members within the window.
+	// +optional
+	MinAllowed *int32 `json:"minAllowed,omitempty"`
+
+	// MaxAllowed overrides the highest allowed number of members within the window.
+	// +optional
+	MaxAllowed *int32 `json:"maxAllowed,omitempty"`
+
+	// MinAllowedCpu overrides the smallest allowed CPU within the window.
+	// +optional
+	MinAllowedCpu *resource.Quantity `json:"minAllowedCpu,omitempty"`
+
+	// MaxAllowedCpu overrides the largest allowed CPU within the window.
+	// +optional
+	MaxAllowedCpu *resource.Quantity `json:"maxAllowedCpu,omitempty"`
+}
+
+// RackScalingBehavior configures scaling the rack's members up and down separately,
+// similarly to the HorizontalPodAutoscaler's behavior.
+type RackScalingBehavior struct {
+	// ScaleUp limits increasing the number of members.
+	// If not set, scaling up is not limited.
+	// +optional
+	ScaleUp *ScalingBehaviorRules `json:"scaleUp,omitempty"`
+
+	// ScaleDown limits decreasing the number of members.
+	// If not set, scaling down is not limited.
+	// +optional
+	ScaleDown *ScalingBehaviorRules `json:"scaleDown,omitempty"`
 }
 
+type ScalingBehaviorRules struct {
+	// StabilizationWindow is the period of time for which past recommendations are considered.
+	// The most conservative of them is used, i.e. the lowest one when scaling up and the highest one
+	// when scaling down, so that the number of members doesn't flap.
+	// If not set, there is no stabilization.
+	// +optional
+	StabilizationWindow *metav1.Duration `json:"stabilizationWindow,omitempty"`
+
+	// SelectPolicy determines which of the Policies is used. Set to "Max" by default,
+	// which allows for the largest change.
+	// +optional
+	// +kubebuilder:default:=Max
+	SelectPolicy ScalingPolicySelect `json:"selectPolicy,omitempty"`
+
+	// Policies limit the change of the number of members within a period of time.
+	// If not set, the change is not limited.
+	// +optional
+	Policies []ScalingRatePolicy `json:"policies,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Max;Min;Disabled
+type ScalingPolicySelect string
+
+const (
+	// ScalingPolicySelectMax selects the policy allowing for the largest change.
+	ScalingPolicySelectMax ScalingPolicySelect = "Max"
+
+	// ScalingPolicySelectMin selects the policy allowing for the smallest change.
+	ScalingPolicySelectMin ScalingPolicySelect = "Min"
+
+	// ScalingPolicySelectDisabled disables scaling in the given direction.
+	ScalingPolicySelectDisabled ScalingPolicySelect = "Disabled"
+)
+
+type ScalingRatePolicy struct {
+	// Type determines whether Value is a number of members or a percentage of the current number of members.
+	Type ScalingRatePolicyType `json:"type"`
+
+	// Value is the largest allowed change of the number of members within Period.
+	// +kubebuilder:validation:Minimum=1
+	Value int32 `json:"value"`
+
+	// Period is the period of time the change is measured over.
+	Period metav1.Duration `json:"period"`
+}
+
+// +kubebuilder:validation:Enum=Members;Percent
+type ScalingRatePolicyType string
+
+const (
+	// ScalingRatePolicyTypeMembers limits the change to a number of members.
+	ScalingRatePolicyTypeMembers ScalingRatePolicyType = "Members"
+
+	// ScalingRatePolicyTypePercent limits the change to a percentage of the number of members.
+	ScalingRatePolicyTypePercent ScalingRatePolicyType = "Percent"
+)
+
 type RackMemberPolicy struct {
 	// The lowest allowed number of members.
 	// The number of rack's members will never go below this value.
@@ -165,6 +619,16 @@ type RackResourcePolicy struct {
 	// +optional
 	MaxAllowedCpu *resource.Quantity `json:"maxAllowedCpu,omitempty"`
 
+	// The smallest allowed memory. Only rightsizing changes the rack's memory.
+	// If not set, there is no minimum.
+	// +optional
+	MinAllowedMemory *resource.Quantity `json:"minAllowedMemory,omitempty"`
+
+	// The largest allowed memory. Only rightsizing changes the rack's memory.
+	// If not set, there is no maximum.
+	// +optional
+	MaxAllowedMemory *resource.Quantity `json:"maxAllowedMemory,omitempty"`
+
 	// Specifies which resource values should be scaled.
 	// Defaults to "RequestsAndLimits".
 	// +optional
@@ -194,10 +658,15 @@ type ScalingRule struct {
 	Priority int32 `json:"priority"`
 
 	// A boolean query to the monitoring service.
-	Expression string `json:"expression"`
+	// It's a Go template, rendered for every rack before being evaluated. Available variables are
+	// .Cluster, .Namespace, .Datacenter, .Rack and .Members.
+	// Either Expression or Condition has to be specified.
+	// +optional
+	Expression string `json:"expression,omitempty"`
 
 	// Describes the duration of a ranged query.
 	// If not set, the query is not ranged.
+	// Only applies to Expression.
 	// +optional
 	For *metav1.Duration `json:"for"`
 
@@ -206,11 +675,77 @@ type ScalingRule struct {
 	// +optional
 	Step *metav1.Duration `json:"step"`
 
+	// Condition is a tree of conditions combining multiple queries.
+	// Either Expression or Condition has to be specified.
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Condition *RuleCondition `json:"condition,omitempty"`
+
 	// ScalingMode specifies the direction of scaling.
 	ScalingMode ScalingMode `json:"mode"`
 
 	// ScalingFactor describes the factor by which the scaled value will be multiplied.
-	ScalingFactor float64 `json:"factor"`
+	// Either ScalingFactor or Formula has to be specified.
+	// +optional
+	ScalingFactor float64 `json:"factor,omitempty"`
+
+	// Metrics are numeric queries to the monitoring service, available in the Formula by their names.
+	// They are templated like the rule's expression.
+	// +optional
+	Metrics []RuleMetric `json:"metrics,omitempty"`
+
+	// Formula is a CEL expression computing the recommended value of the scaled resource, i.e. the number of members
+	// in Horizontal mode and the CPU in cores in Vertical mode, instead of ScalingFactor.
+	// Available variables, all of them doubles, are current, members, cpu, minAllowed, maxAllowed and the names
+	// of the rule's Metrics, and the expression has to evaluate to a double. Numeric literals are doubles,
+	// e.g. "max(members, 3) * 2".
+	// The result is rounded up and clamped by the member or resource policy.
+	// +optional
+	Formula string `json:"formula,omitempty"`
+}
+
+// RuleMetric is a named numeric query used in a rule's formula.
+type RuleMetric struct {
+	// Name by which the metric's value is referenced in the formula.
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Name string `json:"name"`
+
+	// A query to the monitoring service evaluating to a single number.
+	Expression string `json:"expression"`
+}
+
+// RuleCondition is a node of a rule's condition tree.
+// Exactly one of Expression, AllOf, AnyOf and Not has to be specified.
+type RuleCondition struct {
+	// A boolean query to the monitoring service, templated like the rule's expression.
+	// +optional
+	Expression string `json:"expression,omitempty"`
+
+	// Describes the duration of a ranged query.
+	// If not set, the query is not ranged.
+	// +optional
+	For *metav1.Duration `json:"for,omitempty"`
+
+	// Specifies the minimal time period between subsequent points in the time series.
+	// Only applies for ranged queries.
+	// +optional
+	Step *metav1.Duration `json:"step,omitempty"`
+
+	// AllOf is true if all of the sub-conditions are true.
+	// Sub-conditions are evaluated in order, until one of them is false.
+	// +optional
+	AllOf []RuleCondition `json:"allOf,omitempty"`
+
+	// AnyOf is true if any of the sub-conditions is true.
+	// Sub-conditions are evaluated in order, until one of them is true.
+	// +optional
+	AnyOf []RuleCondition `json:"anyOf,omitempty"`
+
+	// Not negates the sub-condition.
+	// +optional
+	Not *RuleCondition `json:"not,omitempty"`
 }
 
 // +kubebuilder:validation:Enum=Horizontal;Vertical
@@ -224,6 +759,44 @@ const (
 	ScalingModeVertical ScalingMode = "Vertical"
 )
 
+// RackForecast is the forecast of a rack's predictive scaling expression.
+type RackForecast struct {
+	// Name of the datacenter.
+	Datacenter string `json:"datacenter"`
+
+	// Name of the rack.
+	Rack string `json:"rack"`
+
+	// Peak is the highest forecast value of the expression within the horizon.
+	Peak float64 `json:"peak"`
+
+	// PeakTime specifies the timestamp of the forecast peak.
+	PeakTime metav1.Time `json:"peakTime"`
+
+	// Error is the weighted absolute percentage error of the forecasts of the last season of the history,
+	// each made one step ahead, as a fraction.
+	Error float64 `json:"error"`
+}
+
+// RackWarmUp is the warm-up of a rack changed by the last applied recommendations.
+type RackWarmUp struct {
+	// Name of the rack.
+	Rack string `json:"rack"`
+
+	// Started specifies the timestamp of the rack being found ready again after the changes, i.e. with all of its
+	// members and pods ready and none of its conditions true, which starts its warm-up.
+	Started metav1.Time `json:"started"`
+}
+
+// PendingRecommendation is a recommendation published for approval.
+type PendingRecommendation struct {
+	// ID identifies the recommendation. Setting ApprovedRecommendationAnnotation of the SCA to it approves the recommendation.
+	ID string `json:"id"`
+
+	// PublishedAt specifies the timestamp of publishing the recommendation for approval.
+	PublishedAt metav1.Time `json:"publishedAt"`
+}
+
 // ScyllaClusterAutoscalerStatus defines the observed state of ScyllaClusterAutoscaler
 type ScyllaClusterAutoscalerStatus struct {
 	// LastUpdated specifies the timestamp of last saved recommendations.
@@ -234,16 +807,97 @@ type ScyllaClusterAutoscalerStatus struct {
 	// +optional
 	LastApplied *metav1.Time `json:"lastApplied,omitempty"`
 
+	// LastAppliedRacks are the names of the racks changed by the last applied recommendations.
+	// +optional
+	LastAppliedRacks []string `json:"lastAppliedRacks,omitempty"`
+
 	// UpdateStatus specifies the result of the latest attempt at preparing and saving recommendations.
 	// +optional
 	UpdateStatus *UpdateStatus `json:"updateStatus,omitempty"`
 
+	// RecentHorizontalActions specifies the timestamps of the applied recommendations which changed the racks' members,
+	// within the window of the horizontal action budget.
+	// +optional
+	RecentHorizontalActions []metav1.Time `json:"recentHorizontalActions,omitempty"`
+
+	// RecentVerticalActions specifies the timestamps of the applied recommendations which changed the racks' resources,
+	// within the window of the vertical action budget.
+	// +optional
+	RecentVerticalActions []metav1.Time `json:"recentVerticalActions,omitempty"`
+
 	// Latest recommendations for the target.
 	// +optional
 	Recommendations *ScyllaClusterRecommendations `json:"recommendations,omitempty"`
+
+	// Forecasts are the latest forecasts of the racks scaled predictively.
+	// +optional
+	Forecasts []RackForecast `json:"forecasts,omitempty"`
+
+	// UnappliedRecommendations are the parts of the latest recommendations which were not applied to the target,
+	// because the update mode of their direction doesn't permit it, or the action budget of their dimension is exhausted.
+	// +optional
+	UnappliedRecommendations *ScyllaClusterRecommendations `json:"unappliedRecommendations,omitempty"`
+
+	// PendingRecommendation identifies the latest recommendations awaiting approval in the "Approval" update mode.
+	// +optional
+	PendingRecommendation *PendingRecommendation `json:"pendingRecommendation,omitempty"`
+
+	// PausedRacks are the names of the racks whose autoscaling is paused with PausedRacksAnnotation.
+	// +optional
+	PausedRacks []string `json:"pausedRacks,omitempty"`
+
+	// WarmUps are the warm-ups of the racks changed by the last applied recommendations, which are ready again.
+	// +optional
+	WarmUps []RackWarmUp `json:"warmUps,omitempty"`
+
+	// WarmingUpRacks are the names of the racks which weren't evaluated, because they're warming up after changes.
+	// +optional
+	WarmingUpRacks []string `json:"warmingUpRacks,omitempty"`
+
+	// Conditions describe the current state of the SCA.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
-// +kubebuilder:validation:Enum=Ok;TargetFetchFail;TargetNotReady;RecommendationsFail
+const (
+	// MetricsSourceHealthyCondition reports whether the SCA's metrics source is healthy.
+	// While it's not, evaluation of the SCA's rules is suspended.
+	MetricsSourceHealthyCondition = "MetricsSourceHealthy"
+
+	// UpdatesHeldCondition reports whether the recommendations are held instead of being applied to the target,
+	// e.g. because of a blackout window, or which of their parts are held back by exhausted action budgets.
+	UpdatesHeldCondition = "UpdatesHeld"
+
+	// PausedCondition reports whether autoscaling of the target is paused with PausedAnnotation.
+	PausedCondition = "Paused"
+
+	// TargetReadyCondition reports whether the target is stable enough for its recommendations to be prepared.
+	// If it's not, the reason tells why, e.g. "MembersNotReady" or "MemberLeaving".
+	TargetReadyCondition = "TargetReady"
+
+	// WarmingUpCondition reports whether any racks aren't evaluated, because they're warming up after changes.
+	WarmingUpCondition = "WarmingUp"
+)
+
+const (
+	// PausedAnnotation pauses evaluating and applying the recommendations for the whole target, when set
+	// on either the SCA or the target ScyllaCluster. Its value is either "true", or an RFC 3339 timestamp
+	// at which the pause expires.
+	PausedAnnotation = "autoscaling.scylla.scylladb.com/paused"
+
+	// PausedRacksAnnotation pauses evaluating and applying the recommendations for the listed racks, when set
+	// on either the SCA or the target ScyllaCluster. Its value is a comma-separated list of rack names, each optionally
+	// followed by "=" and an RFC 3339 timestamp at which the rack's pause expires, e.g. "us-east-1a,us-east-1b=2021-06-07T20:00:00Z".
+	PausedRacksAnnotation = "autoscaling.scylla.scylladb.com/paused-racks"
+
+	// ApprovedRecommendationAnnotation approves the pending recommendation of an SCA in the "Approval" update mode.
+	// Its value is the ID of the approved recommendation.
+	ApprovedRecommendationAnnotation = "autoscaling.scylla.scylladb.com/approved-recommendation"
+)
+
+// +kubebuilder:validation:Enum=Ok;TargetFetchFail;TargetNotReady;MetricsSourceFail;RecommendationsFail
 type UpdateStatus string
 
 const (
@@ -256,6 +910,9 @@ const (
 	// UpdateStatusTargetNotReady says that the target was reachable but unstable.
 	UpdateStatusTargetNotReady UpdateStatus = "TargetNotReady"
 
+	// UpdateStatusMetricsSourceFail says that the metrics source of the SCA could not be set up.
+	UpdateStatusMetricsSourceFail UpdateStatus = "MetricsSourceFail"
+
 	// UpdateStatusRecommendationsFail says that preparing recommendations resulted in an error.
 	UpdateStatusRecommendationsFail UpdateStatus = "RecommendationsFail"
 )
@@ -0,0 +1,52 @@
+package recommender
+
+import (
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setPendingRecommendation publishes the recommendations for approval if any of the SCA's update modes is "Approval"
+// and they differ from the ones last applied to the target. A recommendation that is already pending keeps its ID
+// until it expires, and is published again under a new ID afterwards.
+func setPendingRecommendation(sca *v1alpha1.ScyllaClusterAutoscaler, sc *scyllav1.ScyllaCluster,
+	recommendations *v1alpha1.ScyllaClusterRecommendations, now time.Time) error {
+	updatePolicy := sca.Spec.UpdatePolicy
+	if updatePolicy == nil || !util.ApprovalRequired(updatePolicy) || recommendations == nil {
+		sca.Status.PendingRecommendation = nil
+		return nil
+	}
+
+	checksum, err := util.NewChecksum(*recommendations)
+	if err != nil {
+		return err
+	}
+	if sc.Labels[util.LatestChecksumLabel] == checksum {
+		sca.Status.PendingRecommendation = nil
+		return nil
+	}
+
+	if pending := sca.Status.PendingRecommendation; pending != nil &&
+		!util.PendingRecommendationExpired(pending, updatePolicy.RecommendationExpirationTime, now) {
+		id, err := util.PendingRecommendationID(recommendations, pending.PublishedAt)
+		if err != nil {
+			return err
+		}
+		if id == pending.ID {
+			return nil
+		}
+	}
+
+	// The timestamp is stored with a precision of a second, so it has to be truncated for the ID to be reproducible.
+	publishedAt := metav1.NewTime(now.UTC().Truncate(time.Second))
+	id, err := util.PendingRecommendationID(recommendations, publishedAt)
+	if err != nil {
+		return err
+	}
+	sca.Status.PendingRecommendation = &v1alpha1.PendingRecommendation{ID: id, PublishedAt: publishedAt}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package recommender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetPendingRecommendation(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+	recs := func(members int32) *v1alpha1.ScyllaClusterRecommendations {
+		return &v1alpha1.ScyllaClusterRecommendations{
+			DatacenterRecommendations: []v1alpha1.DatacenterRecommendations{
+				{
+					Name:                "dc",
+					RackRecommendations: []v1alpha1.RackRecommendations{{Name: "rack", Members: util.Int32ptr(members)}},
+				},
+			},
+		}
+	}
+	pending := func(recs *v1alpha1.ScyllaClusterRecommendations, publishedAt metav1.Time) *v1alpha1.PendingRecommendation {
+		id, err := util.PendingRecommendationID(recs, publishedAt)
+		require.NoError(t, err)
+		return &v1alpha1.PendingRecommendation{ID: id, PublishedAt: publishedAt}
+	}
+	appliedChecksum, err := util.NewChecksum(*recs(3))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		updateMode      v1alpha1.UpdateMode
+		expirationTime  *metav1.Duration
+		appliedChecksum string
+		pending         *v1alpha1.PendingRecommendation
+		recommendations *v1alpha1.ScyllaClusterRecommendations
+		expected        *v1alpha1.PendingRecommendation
+	}{
+		{
+			name:            "auto mode",
+			updateMode:      v1alpha1.UpdateModeAuto,
+			pending:         pending(recs(3), earlier),
+			recommendations: recs(3),
+			expected:        nil,
+		},
+		{
+			name:            "no recommendations",
+			updateMode:      v1alpha1.UpdateModeApproval,
+			pending:         pending(recs(3), earlier),
+			recommendations: nil,
+			expected:        nil,
+		},
+		{
+			name:            "recommendations already applied",
+			updateMode:      v1alpha1.UpdateModeApproval,
+			appliedChecksum: appliedChecksum,
+			pending:         pending(recs(3), earlier),
+			recommendations: recs(3),
+			expected:        nil,
+		},
+		{
+			name:            "new recommendations",
+			updateMode:      v1alpha1.UpdateModeApproval,
+			recommendations: recs(3),
+			expected:        pending(recs(3), metav1.NewTime(now)),
+		},
+		{
+			name:            "pending recommendations unchanged",
+			updateMode:      v1alpha1.UpdateModeApproval,
+			expirationTime:  &metav1.Duration{Duration: 2 * time.Hour},
+			pending:         pending(recs(3), earlier),
+			recommendations: recs(3),
+			expected:        pending(recs(3), earlier),
+		},
+		{
+			name:            "pending recommendations changed",
+			updateMode:      v1alpha1.UpdateModeApproval,
+			pending:         pending(recs(3), earlier),
+			recommendations: recs(4),
+			expected:        pending(recs(4), metav1.NewTime(now)),
+		},
+		{
+			name:            "pending recommendations expired",
+			updateMode:      v1alpha1.UpdateModeApproval,
+			expirationTime:  &metav1.Duration{Duration: time.Minute},
+			pending:         pending(recs(3), earlier),
+			recommendations: recs(3),
+			expected:        pending(recs(3), metav1.NewTime(now)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sca := &v1alpha1.ScyllaClusterAutoscaler{
+				Spec: v1alpha1.ScyllaClusterAutoscalerSpec{
+					UpdatePolicy: &v1alpha1.UpdatePolicy{
+						UpdateMode:                   test.updateMode,
+						RecommendationExpirationTime: test.expirationTime,
+					},
+				},
+				Status: v1alpha1.ScyllaClusterAutoscalerStatus{PendingRecommendation: test.pending},
+			}
+			sc := &scyllav1.ScyllaCluster{}
+			if test.appliedChecksum != "" {
+				sc.Labels = map[string]string{util.LatestChecksumLabel: test.appliedChecksum}
+			}
+
+			require.NoError(t, setPendingRecommendation(sca, sc, test.recommendations, now))
+			require.Equal(t, test.expected, sca.Status.PendingRecommendation)
+		})
+	}
+}
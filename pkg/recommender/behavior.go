@@ -0,0 +1,214 @@
+package recommender
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+)
+
+type membersSample struct {
+	time    time.Time
+	members int32
+}
+
+// rackHistory holds the rack's recent recommended and observed numbers of members.
+type rackHistory struct {
+	recommended []membersSample
+	observed    []membersSample
+}
+
+// behaviorHistory keeps the racks' recent numbers of members, which the racks' scaling behavior is applied against.
+// It's kept in memory, so it's lost when the recommender restarts.
+type behaviorHistory struct {
+	mu    sync.Mutex
+	racks map[string]*rackHistory
+	used  map[string]struct{}
+}
+
+func newBehaviorHistory() *behaviorHistory {
+	return &behaviorHistory{
+		racks: make(map[string]*rackHistory),
+		used:  make(map[string]struct{}),
+	}
+}
+
+// rackKey identifies the rack of the cluster in the history.
+func rackKey(cluster *scyllav1.ScyllaCluster, rack string) string {
+	if cluster == nil {
+		return rack
+	}
+	return cluster.Namespace + "/" + cluster.Name + "/" + cluster.Spec.Datacenter.Name + "/" + rack
+}
+
+// touch marks the histories of the cluster's racks as used, so that they're kept by prune
+// even if the racks are not evaluated, e.g. while the cluster isn't ready.
+func (h *behaviorHistory) touch(cluster *scyllav1.ScyllaCluster) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, rack := range cluster.Spec.Datacenter.Racks {
+		h.used[rackKey(cluster, rack.Name)] = struct{}{}
+	}
+}
+
+// prune drops the histories of the racks which were neither evaluated nor touched since the previous call,
+// e.g. of deleted SCAs or removed racks.
+func (h *behaviorHistory) prune() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key := range h.racks {
+		if _, ok := h.used[key]; !ok {
+			delete(h.racks, key)
+		}
+	}
+	h.used = make(map[string]struct{})
+}
+
+// apply records the rack's current and recommended numbers of members, and returns the recommendation
+// stabilized and limited by the behavior.
+func (h *behaviorHistory) apply(key string, now time.Time, current, recommended int32, behavior *v1alpha1.RackScalingBehavior) int32 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rh, ok := h.racks[key]
+	if !ok {
+		rh = &rackHistory{}
+		h.racks[key] = rh
+	}
+	h.used[key] = struct{}{}
+
+	since := now.Add(-behaviorHorizon(behavior))
+	rh.recommended = append(prune(rh.recommended, since), membersSample{time: now, members: recommended})
+	rh.observed = append(prune(rh.observed, since), membersSample{time: now, members: current})
+
+	stabilized := stabilize(rh.recommended, now, current, behavior)
+	return limitRate(rh.observed, now, current, stabilized, behavior)
+}
+
+// behaviorHorizon returns how long the samples have to be kept for.
+func behaviorHorizon(behavior *v1alpha1.RackScalingBehavior) time.Duration {
+	var horizon time.Duration
+	for _, rules := range []*v1alpha1.ScalingBehaviorRules{behavior.ScaleUp, behavior.ScaleDown} {
+		if rules == nil {
+			continue
+		}
+		if rules.StabilizationWindow != nil && rules.StabilizationWindow.Duration > horizon {
+			horizon = rules.StabilizationWindow.Duration
+		}
+		for _, p := range rules.Policies {
+			if p.Period.Duration > horizon {
+				horizon = p.Period.Duration
+			}
+		}
+	}
+	return horizon
+}
+
+// prune drops the samples older than since.
+func prune(samples []membersSample, since time.Time) []membersSample {
+	i := 0
+	for i < len(samples) && samples[i].time.Before(since) {
+		i++
+	}
+	return samples[i:]
+}
+
+// stabilize returns the most conservative of the recommendations within the stabilization windows:
+// the lowest one for scaling up and the highest one for scaling down.
+func stabilize(recommended []membersSample, now time.Time, current int32, behavior *v1alpha1.RackScalingBehavior) int32 {
+	latest := recommended[len(recommended)-1].members
+	up, down := latest, latest
+	for _, s := range recommended {
+		if inWindow(behavior.ScaleUp, s.time, now) && s.members < up {
+			up = s.members
+		}
+		if inWindow(behavior.ScaleDown, s.time, now) && s.members > down {
+			down = s.members
+		}
+	}
+
+	res := current
+	if res < up {
+		res = up
+	}
+	if res > down {
+		res = down
+	}
+	return res
+}
+
+func inWindow(rules *v1alpha1.ScalingBehaviorRules, t, now time.Time) bool {
+	if rules == nil || rules.StabilizationWindow == nil {
+		return false
+	}
+	return !t.Before(now.Add(-rules.StabilizationWindow.Duration))
+}
+
+// limitRate limits the change of the number of members according to the behavior's policies.
+// A policy's change is measured against the lowest number of members observed within its period when scaling up,
+// and against the highest one when scaling down.
+func limitRate(observed []membersSample, now time.Time, current, recommended int32, behavior *v1alpha1.RackScalingBehavior) int32 {
+	switch {
+	case recommended > current && behavior.ScaleUp != nil:
+		if limit, ok := rateLimit(observed, now, behavior.ScaleUp, true); ok && recommended > limit {
+			return util.MaxInt32(limit, current)
+		}
+	case recommended < current && behavior.ScaleDown != nil:
+		if limit, ok := rateLimit(observed, now, behavior.ScaleDown, false); ok && recommended < limit {
+			return util.MinInt32(limit, current)
+		}
+	}
+	return recommended
+}
+
+// rateLimit returns the limit of the number of members set by the rules, if any.
+func rateLimit(observed []membersSample, now time.Time, rules *v1alpha1.ScalingBehaviorRules, up bool) (int32, bool) {
+	if rules.SelectPolicy == v1alpha1.ScalingPolicySelectDisabled {
+		return observed[len(observed)-1].members, true
+	}
+	if len(rules.Policies) == 0 {
+		return 0, false
+	}
+
+	var res int32
+	for i, p := range rules.Policies {
+		base := observed[len(observed)-1].members
+		since := now.Add(-p.Period.Duration)
+		for _, s := range observed {
+			if s.time.Before(since) {
+				continue
+			}
+			if up && s.members < base || !up && s.members > base {
+				base = s.members
+			}
+		}
+
+		var limit int32
+		if up {
+			if p.Type == v1alpha1.ScalingRatePolicyTypePercent {
+				limit = int32(math.Ceil(float64(base) * (1 + float64(p.Value)/100)))
+			} else {
+				limit = base + p.Value
+			}
+		} else {
+			if p.Type == v1alpha1.ScalingRatePolicyTypePercent {
+				limit = int32(math.Ceil(float64(base) * (1 - float64(p.Value)/100)))
+			} else {
+				limit = base - p.Value
+			}
+		}
+
+		// The largest change means the highest limit when scaling up and the lowest one when scaling down.
+		highest := up == (rules.SelectPolicy != v1alpha1.ScalingPolicySelectMin)
+		if i == 0 || highest && limit > res || !highest && limit < res {
+			res = limit
+		}
+	}
+
+	return res, true
+}
@@ -0,0 +1,218 @@
+package recommender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBehaviorHistoryApply(t *testing.T) {
+	window := func(d time.Duration) *metav1.Duration {
+		return &metav1.Duration{Duration: d}
+	}
+	policy := func(policyType v1alpha1.ScalingRatePolicyType, value int32, period time.Duration) v1alpha1.ScalingRatePolicy {
+		return v1alpha1.ScalingRatePolicy{Type: policyType, Value: value, Period: metav1.Duration{Duration: period}}
+	}
+
+	// step is an evaluation of the rack, offset from the start of the test.
+	type step struct {
+		offset      time.Duration
+		current     int32
+		recommended int32
+		expected    int32
+	}
+
+	tests := []struct {
+		name     string
+		behavior *v1alpha1.RackScalingBehavior
+		steps    []step
+	}{
+		{
+			name:     "no limits",
+			behavior: &v1alpha1.RackScalingBehavior{},
+			steps: []step{
+				{offset: 0, current: 3, recommended: 30, expected: 30},
+				{offset: time.Minute, current: 30, recommended: 2, expected: 2},
+			},
+		},
+		{
+			name: "scale down stabilization picks the highest recommendation",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleDown: &v1alpha1.ScalingBehaviorRules{StabilizationWindow: window(5 * time.Minute)},
+			},
+			steps: []step{
+				{offset: 0, current: 6, recommended: 6, expected: 6},
+				{offset: time.Minute, current: 6, recommended: 4, expected: 6},
+				{offset: 2 * time.Minute, current: 6, recommended: 5, expected: 6},
+				{offset: 6 * time.Minute, current: 6, recommended: 3, expected: 5},
+				{offset: 8 * time.Minute, current: 6, recommended: 3, expected: 3},
+			},
+		},
+		{
+			name: "scale up stabilization picks the lowest recommendation",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleUp: &v1alpha1.ScalingBehaviorRules{StabilizationWindow: window(3 * time.Minute)},
+			},
+			steps: []step{
+				{offset: 0, current: 3, recommended: 3, expected: 3},
+				{offset: time.Minute, current: 3, recommended: 6, expected: 3},
+				{offset: 2 * time.Minute, current: 3, recommended: 5, expected: 3},
+				{offset: 4 * time.Minute, current: 3, recommended: 6, expected: 5},
+			},
+		},
+		{
+			name: "scale up stabilization doesn't delay scaling down",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleUp: &v1alpha1.ScalingBehaviorRules{StabilizationWindow: window(3 * time.Minute)},
+			},
+			steps: []step{
+				{offset: 0, current: 6, recommended: 6, expected: 6},
+				{offset: time.Minute, current: 6, recommended: 4, expected: 4},
+			},
+		},
+		{
+			name: "members policy",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleUp: &v1alpha1.ScalingBehaviorRules{
+					Policies: []v1alpha1.ScalingRatePolicy{policy(v1alpha1.ScalingRatePolicyTypeMembers, 2, 10*time.Minute)},
+				},
+			},
+			steps: []step{
+				{offset: 0, current: 3, recommended: 30, expected: 5},
+				{offset: time.Minute, current: 5, recommended: 30, expected: 5},
+				{offset: 11 * time.Minute, current: 5, recommended: 30, expected: 7},
+			},
+		},
+		{
+			name: "percent policy",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleDown: &v1alpha1.ScalingBehaviorRules{
+					Policies: []v1alpha1.ScalingRatePolicy{policy(v1alpha1.ScalingRatePolicyTypePercent, 50, time.Minute)},
+				},
+			},
+			steps: []step{
+				{offset: 0, current: 10, recommended: 1, expected: 5},
+				{offset: 30 * time.Second, current: 5, recommended: 1, expected: 5},
+				{offset: 2 * time.Minute, current: 5, recommended: 1, expected: 3},
+			},
+		},
+		{
+			name: "max select policy allows for the largest change",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleUp: &v1alpha1.ScalingBehaviorRules{
+					Policies: []v1alpha1.ScalingRatePolicy{
+						policy(v1alpha1.ScalingRatePolicyTypeMembers, 2, time.Minute),
+						policy(v1alpha1.ScalingRatePolicyTypePercent, 100, time.Minute),
+					},
+				},
+			},
+			steps: []step{
+				{offset: 0, current: 3, recommended: 30, expected: 6},
+			},
+		},
+		{
+			name: "min select policy allows for the smallest change",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleDown: &v1alpha1.ScalingBehaviorRules{
+					SelectPolicy: v1alpha1.ScalingPolicySelectMin,
+					Policies: []v1alpha1.ScalingRatePolicy{
+						policy(v1alpha1.ScalingRatePolicyTypeMembers, 1, time.Minute),
+						policy(v1alpha1.ScalingRatePolicyTypePercent, 50, time.Minute),
+					},
+				},
+			},
+			steps: []step{
+				{offset: 0, current: 10, recommended: 1, expected: 9},
+			},
+		},
+		{
+			name: "disabled direction",
+			behavior: &v1alpha1.RackScalingBehavior{
+				ScaleDown: &v1alpha1.ScalingBehaviorRules{SelectPolicy: v1alpha1.ScalingPolicySelectDisabled},
+			},
+			steps: []step{
+				{offset: 0, current: 10, recommended: 1, expected: 10},
+				{offset: time.Minute, current: 10, recommended: 12, expected: 12},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newBehaviorHistory()
+			start := time.Now()
+			for i, s := range test.steps {
+				res := h.apply("rack", start.Add(s.offset), s.current, s.recommended, test.behavior)
+				require.Equal(t, s.expected, res, "step %d", i)
+			}
+		})
+	}
+}
+
+func TestBehaviorHistoryPrune(t *testing.T) {
+	behavior := &v1alpha1.RackScalingBehavior{
+		ScaleDown: &v1alpha1.ScalingBehaviorRules{StabilizationWindow: &metav1.Duration{Duration: 5 * time.Minute}},
+	}
+	cluster := &scyllav1.ScyllaCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "sc", Namespace: "sc-ns"},
+		Spec: scyllav1.ClusterSpec{
+			Datacenter: scyllav1.DatacenterSpec{Name: "dc", Racks: []scyllav1.RackSpec{{Name: "touched"}}},
+		},
+	}
+	now := time.Now()
+
+	h := newBehaviorHistory()
+	for _, rack := range []string{"evaluated", "touched", "removed"} {
+		h.apply(rackKey(cluster, rack), now, 6, 6, behavior)
+	}
+	h.prune()
+	require.Len(t, h.racks, 3)
+
+	// Only the evaluated rack and the racks of the touched cluster are kept.
+	h.apply(rackKey(cluster, "evaluated"), now.Add(time.Minute), 6, 4, behavior)
+	h.touch(cluster)
+	h.prune()
+	require.Len(t, h.racks, 2)
+	require.Contains(t, h.racks, rackKey(cluster, "evaluated"))
+	require.Contains(t, h.racks, rackKey(cluster, "touched"))
+
+	// The history of a kept rack still stabilizes its recommendations.
+	require.Equal(t, int32(6), h.apply(rackKey(cluster, "touched"), now.Add(2*time.Minute), 6, 3, behavior))
+
+	h.prune()
+	h.prune()
+	require.Empty(t, h.racks)
+}
+
+func TestApplyBehaviorClampsToChangedBounds(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	r := New(nil, nil, nil, Options{}, logger).(*recommender)
+
+	sc := newSingleDcSc("test-sc", "test-sc-ns", "dc_name",
+		[]scyllav1.RackSpec{*getRackSpec("rack_name", 8, "1", "1", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{"rack_name": *getRackStatus(8, 8)})
+	rack := &sc.Spec.Datacenter.Racks[0]
+	ctx = metrics.WithTarget(ctx, metrics.Target{Cluster: sc, Rack: rack})
+
+	policy := setBehavior(&v1alpha1.RackScalingBehavior{
+		ScaleDown: &v1alpha1.ScalingBehaviorRules{StabilizationWindow: &metav1.Duration{Duration: time.Hour}},
+	}, newRackScalingPolicy("rack_name", nil, 1, 10, resource.MustParse("1"), resource.MustParse("10"),
+		v1alpha1.RackControlledValuesRequestsAndLimits))
+	require.Equal(t, int32(6), r.applyBehavior(ctx, rack, policy, 6))
+
+	// The recommendation kept in the stabilization window exceeds the lowered maximum.
+	policy.MemberPolicy.MaxAllowed = util.Int32ptr(4)
+	require.Equal(t, int32(4), r.applyBehavior(ctx, rack, policy, 4))
+}
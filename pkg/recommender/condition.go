@@ -0,0 +1,155 @@
+package recommender
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+)
+
+// ruleCondition returns the condition tree of the rule.
+// A rule with a plain expression is a single condition with the rule's range.
+func ruleCondition(rule *v1alpha1.ScalingRule) (*v1alpha1.RuleCondition, error) {
+	if rule.Condition != nil {
+		if rule.Expression != "" || rule.For != nil || rule.Step != nil {
+			return nil, errors.New("expression, for and step can't be specified along with condition")
+		}
+		return rule.Condition, nil
+	}
+
+	if rule.Expression == "" {
+		return nil, errors.New("either expression or condition has to be specified")
+	}
+
+	return &v1alpha1.RuleCondition{
+		Expression: rule.Expression,
+		For:        rule.For,
+		Step:       rule.Step,
+	}, nil
+}
+
+// conditionExpressions returns the expressions of all the conditions in the tree.
+func conditionExpressions(c *v1alpha1.RuleCondition) []string {
+	if c == nil {
+		return nil
+	}
+
+	var expressions []string
+	if c.Expression != "" {
+		expressions = append(expressions, c.Expression)
+	}
+	for i := range c.AllOf {
+		expressions = append(expressions, conditionExpressions(&c.AllOf[i])...)
+	}
+	for i := range c.AnyOf {
+		expressions = append(expressions, conditionExpressions(&c.AnyOf[i])...)
+	}
+	return append(expressions, conditionExpressions(c.Not)...)
+}
+
+func validateCondition(c *v1alpha1.RuleCondition) error {
+	set := 0
+	if c.Expression != "" {
+		set++
+	}
+	if len(c.AllOf) > 0 {
+		set++
+	}
+	if len(c.AnyOf) > 0 {
+		set++
+	}
+	if c.Not != nil {
+		set++
+	}
+	if set != 1 {
+		return errors.New("exactly one of expression, allOf, anyOf and not has to be specified")
+	}
+
+	if c.Expression == "" && (c.For != nil || c.Step != nil) {
+		return errors.New("for and step only apply to expressions")
+	}
+
+	return nil
+}
+
+// validateConditionTree validates all the conditions in the tree.
+func validateConditionTree(c *v1alpha1.RuleCondition) error {
+	if err := validateCondition(c); err != nil {
+		return err
+	}
+
+	for i := range c.AllOf {
+		if err := validateConditionTree(&c.AllOf[i]); err != nil {
+			return errors.Wrapf(err, "allOf[%d]", i)
+		}
+	}
+	for i := range c.AnyOf {
+		if err := validateConditionTree(&c.AnyOf[i]); err != nil {
+			return errors.Wrapf(err, "anyOf[%d]", i)
+		}
+	}
+	if c.Not != nil {
+		if err := validateConditionTree(c.Not); err != nil {
+			return errors.Wrap(err, "not")
+		}
+	}
+
+	return nil
+}
+
+// evaluateCondition evaluates the condition tree in order, skipping the sub-conditions
+// which can't change the result.
+func evaluateCondition(ctx context.Context, provider metrics.Provider, c *v1alpha1.RuleCondition, data ExpressionData) (bool, error) {
+	if err := validateCondition(c); err != nil {
+		return false, err
+	}
+
+	switch {
+	case len(c.AllOf) > 0:
+		for i := range c.AllOf {
+			res, err := evaluateCondition(ctx, provider, &c.AllOf[i], data)
+			if err != nil {
+				return false, errors.Wrapf(err, "allOf[%d]", i)
+			}
+			if !res {
+				return false, nil
+			}
+		}
+		return true, nil
+
+	case len(c.AnyOf) > 0:
+		for i := range c.AnyOf {
+			res, err := evaluateCondition(ctx, provider, &c.AnyOf[i], data)
+			if err != nil {
+				return false, errors.Wrapf(err, "anyOf[%d]", i)
+			}
+			if res {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case c.Not != nil:
+		res, err := evaluateCondition(ctx, provider, c.Not, data)
+		if err != nil {
+			return false, errors.Wrap(err, "not")
+		}
+		return !res, nil
+	}
+
+	expression, err := renderExpression(c.Expression, data)
+	if err != nil {
+		return false, err
+	}
+
+	if c.For != nil {
+		var step *time.Duration = nil
+		if c.Step != nil {
+			step = &c.Step.Duration
+		}
+		return provider.RangedQuery(ctx, expression, c.For.Duration, step)
+	}
+	return provider.Query(ctx, expression)
+}
@@ -0,0 +1,207 @@
+package recommender
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordingProvider resolves expressions "true" and "false" to their values, and numbers to themselves,
+// and records the queries.
+type recordingProvider struct {
+	queries []string
+	ranged  []time.Duration
+}
+
+func (p *recordingProvider) Query(_ context.Context, expression string) (bool, error) {
+	p.queries = append(p.queries, expression)
+	switch expression {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, errors.Errorf("unknown expression %q", expression)
+}
+
+func (p *recordingProvider) QueryValue(_ context.Context, expression string) (float64, error) {
+	p.queries = append(p.queries, expression)
+	return strconv.ParseFloat(expression, 64)
+}
+
+func (p *recordingProvider) RangedQuery(ctx context.Context, expression string, duration time.Duration, _ *time.Duration) (bool, error) {
+	p.ranged = append(p.ranged, duration)
+	return p.Query(ctx, expression)
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	leaf := func(expression string) v1alpha1.RuleCondition {
+		return v1alpha1.RuleCondition{Expression: expression}
+	}
+
+	tests := []struct {
+		name            string
+		condition       v1alpha1.RuleCondition
+		expected        bool
+		expectedQueries []string
+		expectedRanged  []time.Duration
+		errorExpected   bool
+	}{
+		{
+			name:            "expression",
+			condition:       leaf("true"),
+			expected:        true,
+			expectedQueries: []string{"true"},
+		},
+		{
+			name: "ranged expression",
+			condition: v1alpha1.RuleCondition{
+				Expression: "true",
+				For:        &metav1.Duration{Duration: time.Minute},
+			},
+			expected:        true,
+			expectedQueries: []string{"true"},
+			expectedRanged:  []time.Duration{time.Minute},
+		},
+		{
+			name:            "all of true",
+			condition:       v1alpha1.RuleCondition{AllOf: []v1alpha1.RuleCondition{leaf("true"), leaf("true")}},
+			expected:        true,
+			expectedQueries: []string{"true", "true"},
+		},
+		{
+			name:            "all of short-circuits on false",
+			condition:       v1alpha1.RuleCondition{AllOf: []v1alpha1.RuleCondition{leaf("false"), leaf("unknown")}},
+			expected:        false,
+			expectedQueries: []string{"false"},
+		},
+		{
+			name:            "any of short-circuits on true",
+			condition:       v1alpha1.RuleCondition{AnyOf: []v1alpha1.RuleCondition{leaf("false"), leaf("true"), leaf("unknown")}},
+			expected:        true,
+			expectedQueries: []string{"false", "true"},
+		},
+		{
+			name:            "any of false",
+			condition:       v1alpha1.RuleCondition{AnyOf: []v1alpha1.RuleCondition{leaf("false"), leaf("false")}},
+			expected:        false,
+			expectedQueries: []string{"false", "false"},
+		},
+		{
+			name: "not",
+			condition: v1alpha1.RuleCondition{Not: &v1alpha1.RuleCondition{
+				AllOf: []v1alpha1.RuleCondition{leaf("true"), leaf("false")},
+			}},
+			expected:        true,
+			expectedQueries: []string{"true", "false"},
+		},
+		{
+			name: "nested ranged expression",
+			condition: v1alpha1.RuleCondition{AllOf: []v1alpha1.RuleCondition{
+				leaf("true"),
+				{Expression: "true", For: &metav1.Duration{Duration: 5 * time.Minute}},
+			}},
+			expected:        true,
+			expectedQueries: []string{"true", "true"},
+			expectedRanged:  []time.Duration{5 * time.Minute},
+		},
+		{
+			name:            "query error",
+			condition:       v1alpha1.RuleCondition{AllOf: []v1alpha1.RuleCondition{leaf("true"), leaf("unknown")}},
+			expectedQueries: []string{"true", "unknown"},
+			errorExpected:   true,
+		},
+		{
+			name:          "empty condition",
+			condition:     v1alpha1.RuleCondition{},
+			errorExpected: true,
+		},
+		{
+			name: "multiple operators",
+			condition: v1alpha1.RuleCondition{
+				Expression: "true",
+				AnyOf:      []v1alpha1.RuleCondition{leaf("true")},
+			},
+			errorExpected: true,
+		},
+		{
+			name: "range of operator",
+			condition: v1alpha1.RuleCondition{
+				AnyOf: []v1alpha1.RuleCondition{leaf("true")},
+				For:   &metav1.Duration{Duration: time.Minute},
+			},
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &recordingProvider{}
+			res, err := evaluateCondition(context.Background(), p, &test.condition, ExpressionData{})
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+			require.Equal(t, test.expectedQueries, p.queries)
+			require.Equal(t, test.expectedRanged, p.ranged)
+		})
+	}
+}
+
+func TestRuleCondition(t *testing.T) {
+	duration := &metav1.Duration{Duration: time.Minute}
+	condition := &v1alpha1.RuleCondition{AnyOf: []v1alpha1.RuleCondition{{Expression: "a"}, {Expression: "b"}}}
+
+	tests := []struct {
+		name          string
+		rule          v1alpha1.ScalingRule
+		expected      *v1alpha1.RuleCondition
+		errorExpected bool
+	}{
+		{
+			name:     "expression",
+			rule:     v1alpha1.ScalingRule{Expression: "a", For: duration},
+			expected: &v1alpha1.RuleCondition{Expression: "a", For: duration},
+		},
+		{
+			name:     "condition",
+			rule:     v1alpha1.ScalingRule{Condition: condition},
+			expected: condition,
+		},
+		{
+			name:          "expression and condition",
+			rule:          v1alpha1.ScalingRule{Expression: "a", Condition: condition},
+			errorExpected: true,
+		},
+		{
+			name:          "for and condition",
+			rule:          v1alpha1.ScalingRule{For: duration, Condition: condition},
+			errorExpected: true,
+		},
+		{
+			name:          "neither expression nor condition",
+			rule:          v1alpha1.ScalingRule{},
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := ruleCondition(&test.rule)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+		})
+	}
+}
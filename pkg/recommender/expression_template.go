@@ -0,0 +1,62 @@
+package recommender
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+)
+
+// ExpressionData holds the variables available in the templates of the rules' expressions,
+// e.g. `avg(scylla_reactor_utilization{scylla_cluster="{{ .Cluster }}", scylla_rack="{{ .Rack }}"}) > bool 70`.
+type ExpressionData struct {
+	// Cluster is the name of the target ScyllaCluster.
+	Cluster string
+
+	// Namespace is the namespace of the target ScyllaCluster.
+	Namespace string
+
+	// Datacenter is the name of the datacenter the rule is evaluated for.
+	Datacenter string
+
+	// Rack is the name of the rack the rule is evaluated for.
+	Rack string
+
+	// Members is the current number of the rack's members.
+	Members int32
+}
+
+func newExpressionData(target metrics.Target) ExpressionData {
+	var data ExpressionData
+	if target.Cluster != nil {
+		data.Cluster = target.Cluster.Name
+		data.Namespace = target.Cluster.Namespace
+		data.Datacenter = target.Cluster.Spec.Datacenter.Name
+	}
+	if target.Rack != nil {
+		data.Rack = target.Rack.Name
+		data.Members = target.Rack.Members
+	}
+	return data
+}
+
+// renderExpression renders the template of an expression with the given data.
+func renderExpression(expression string, data ExpressionData) (string, error) {
+	if !strings.Contains(expression, "{{") {
+		return expression, nil
+	}
+
+	tmpl, err := template.New("expression").Option("missingkey=error").Parse(expression)
+	if err != nil {
+		return "", errors.Wrap(err, "parse expression template")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrap(err, "render expression template")
+	}
+
+	return buf.String(), nil
+}
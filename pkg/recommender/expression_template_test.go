@@ -0,0 +1,62 @@
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderExpression(t *testing.T) {
+	data := ExpressionData{
+		Cluster:    "simple-cluster",
+		Namespace:  "scylla",
+		Datacenter: "us-east-1",
+		Rack:       "us-east-1a",
+		Members:    3,
+	}
+
+	tests := []struct {
+		name          string
+		expression    string
+		expected      string
+		errorExpected bool
+	}{
+		{
+			name:       "plain expression",
+			expression: `avg(scylla_reactor_utilization{scylla_rack="us-east-1a"}) > bool 70`,
+			expected:   `avg(scylla_reactor_utilization{scylla_rack="us-east-1a"}) > bool 70`,
+		},
+		{
+			name:       "variables",
+			expression: `avg(scylla_reactor_utilization{scylla_cluster="{{ .Cluster }}", scylla_datacenter="{{ .Datacenter }}", scylla_rack="{{ .Rack }}", namespace="{{ .Namespace }}"}) > bool 70`,
+			expected:   `avg(scylla_reactor_utilization{scylla_cluster="simple-cluster", scylla_datacenter="us-east-1", scylla_rack="us-east-1a", namespace="scylla"}) > bool 70`,
+		},
+		{
+			name:       "members",
+			expression: `sum(scylla_storage_used) / {{ .Members }} > bool 1e12`,
+			expected:   `sum(scylla_storage_used) / 3 > bool 1e12`,
+		},
+		{
+			name:          "unknown variable",
+			expression:    `up{rack="{{ .Zone }}"}`,
+			errorExpected: true,
+		},
+		{
+			name:          "malformed template",
+			expression:    `up{rack="{{ .Rack "}`,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := renderExpression(test.expression, data)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package recommender
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// holtWintersGrid are the candidate values of the Holt-Winters smoothing parameters.
+var holtWintersGrid = []float64{0.05, 0.2, 0.4, 0.6, 0.8}
+
+// seasonalForecast is the forecast of a series with a recurring pattern.
+type seasonalForecast struct {
+	// values are the forecast values, one per step following the series.
+	values []float64
+	// err is the weighted absolute percentage error of the forecasts of the last season of the series,
+	// each made one step ahead.
+	err float64
+}
+
+// forecastSeasonalNaive forecasts the series by repeating its last season.
+func forecastSeasonalNaive(series []float64, season, horizon int) (*seasonalForecast, error) {
+	if err := checkSeasons(series, season); err != nil {
+		return nil, err
+	}
+
+	n := len(series)
+	fitted := make([]float64, season)
+	for i := range fitted {
+		fitted[i] = series[n-2*season+i]
+	}
+
+	values := make([]float64, horizon)
+	for h := range values {
+		values[h] = series[n-season+h%season]
+	}
+
+	return &seasonalForecast{values: values, err: wape(series[n-season:], fitted)}, nil
+}
+
+// forecastHoltWinters forecasts the series with additive Holt-Winters exponential smoothing.
+// The smoothing parameters are the ones minimizing the squared error of the one-step-ahead forecasts.
+func forecastHoltWinters(series []float64, season, horizon int) (*seasonalForecast, error) {
+	if err := checkSeasons(series, season); err != nil {
+		return nil, err
+	}
+
+	var best *holtWinters
+	for _, alpha := range holtWintersGrid {
+		for _, beta := range holtWintersGrid {
+			for _, gamma := range holtWintersGrid {
+				hw := fitHoltWinters(series, season, alpha, beta, gamma)
+				if best == nil || hw.sse < best.sse {
+					best = hw
+				}
+			}
+		}
+	}
+
+	n := len(series)
+	values := make([]float64, horizon)
+	for h := range values {
+		values[h] = best.level + float64(h+1)*best.trend + best.seasonals[n-season+h%season]
+	}
+
+	return &seasonalForecast{values: values, err: wape(series[n-season:], best.fitted[n-season:])}, nil
+}
+
+// holtWinters is the state of Holt-Winters smoothing fitted to a series.
+type holtWinters struct {
+	level, trend float64
+	// seasonals are the seasonal components, one per point of the series.
+	seasonals []float64
+	// fitted are the one-step-ahead forecasts of the series, starting with its second season.
+	fitted []float64
+	sse    float64
+}
+
+func fitHoltWinters(series []float64, season int, alpha, beta, gamma float64) *holtWinters {
+	first, second := mean(series[:season]), mean(series[season:2*season])
+	hw := &holtWinters{
+		level:     first,
+		trend:     (second - first) / float64(season),
+		seasonals: make([]float64, len(series)),
+		fitted:    make([]float64, len(series)),
+	}
+	for i := 0; i < season; i++ {
+		hw.seasonals[i] = series[i] - first
+		hw.fitted[i] = series[i]
+	}
+
+	for t := season; t < len(series); t++ {
+		hw.fitted[t] = hw.level + hw.trend + hw.seasonals[t-season]
+		hw.sse += (series[t] - hw.fitted[t]) * (series[t] - hw.fitted[t])
+
+		level := alpha*(series[t]-hw.seasonals[t-season]) + (1-alpha)*(hw.level+hw.trend)
+		hw.trend = beta*(level-hw.level) + (1-beta)*hw.trend
+		hw.level = level
+		hw.seasonals[t] = gamma*(series[t]-level) + (1-gamma)*hw.seasonals[t-season]
+	}
+
+	return hw
+}
+
+func checkSeasons(series []float64, season int) error {
+	if season < 1 {
+		return errors.New("season has to span at least a single step")
+	}
+	if len(series) < 2*season {
+		return errors.Errorf("history of %d steps doesn't cover two seasons of %d steps", len(series), season)
+	}
+	return nil
+}
+
+// wape returns the weighted absolute percentage error of the forecasts, i.e. the sum of the absolute errors
+// relative to the sum of the absolute actual values. If the actual values are all zero, it's 1 unless
+// the forecasts are exact.
+func wape(actual, forecast []float64) float64 {
+	var errSum, sum float64
+	for i := range actual {
+		errSum += math.Abs(actual[i] - forecast[i])
+		sum += math.Abs(actual[i])
+	}
+
+	switch {
+	case sum > 0:
+		return errSum / sum
+	case errSum > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
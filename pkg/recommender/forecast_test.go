@@ -0,0 +1,334 @@
+package recommender
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	mockprometheusapi "github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// reactorUtilizationFixture is two weeks of a rack's reactor utilization recorded every 30 minutes,
+// peaking daily at 14:00 UTC at about 75%, with a few points missing.
+const reactorUtilizationFixture = "reactor_utilization_2w.json"
+
+// loadSeriesFixture loads a range query response recorded from Prometheus.
+func loadSeriesFixture(t *testing.T, name string) model.Matrix {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	var response struct {
+		Data struct {
+			Result model.Matrix `json:"result"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &response))
+	return response.Data.Result
+}
+
+// replayedRangedQuery returns a ranged query function replaying the recorded series, shifted by whole days
+// so that it ends right before the end of the queried range.
+func replayedRangedQuery(series model.Matrix) func(string, v1.Range) (model.Value, v1.Warnings, error) {
+	return func(query string, r v1.Range) (model.Value, v1.Warnings, error) {
+		values := series[0].Values
+		last := values[len(values)-1].Timestamp.Time()
+		shift := r.End.Sub(last).Truncate(24 * time.Hour)
+
+		res := &model.SampleStream{Metric: series[0].Metric}
+		for _, v := range values {
+			res.Values = append(res.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(v.Timestamp.Time().Add(shift).UnixNano()), Value: v.Value})
+		}
+		return model.Matrix{res}, nil, nil
+	}
+}
+
+func TestForecastMethods(t *testing.T) {
+	const season = 4
+	periodic := []float64{1, 3, 5, 3, 1, 3, 5, 3, 1, 3, 5, 3}
+	trending := make([]float64, len(periodic))
+	for i := range periodic {
+		trending[i] = periodic[i] + float64(i)
+	}
+
+	tests := []struct {
+		name           string
+		forecast       func(series []float64, season, horizon int) (*seasonalForecast, error)
+		series         []float64
+		expectedValues []float64
+		expectedErr    float64
+		delta          float64
+		errorExpected  bool
+	}{
+		{
+			name:           "seasonal naive repeats the last season",
+			forecast:       forecastSeasonalNaive,
+			series:         periodic,
+			expectedValues: []float64{1, 3, 5, 3, 1, 3},
+		},
+		{
+			name:           "seasonal naive lags behind trend",
+			forecast:       forecastSeasonalNaive,
+			series:         trending,
+			expectedValues: []float64{9, 12, 15, 14, 9, 12},
+			expectedErr:    16.0 / 50,
+		},
+		{
+			name:           "Holt-Winters follows trend",
+			forecast:       forecastHoltWinters,
+			series:         trending,
+			expectedValues: []float64{13, 16, 19, 18, 17, 20},
+			expectedErr:    0.05,
+			delta:          0.5,
+		},
+		{
+			name:          "seasonal naive with history shorter than two seasons",
+			forecast:      forecastSeasonalNaive,
+			series:        periodic[:season+1],
+			errorExpected: true,
+		},
+		{
+			name:          "Holt-Winters with history shorter than two seasons",
+			forecast:      forecastHoltWinters,
+			series:        periodic[:season+1],
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.forecast(test.series, season, 6)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			delta := test.delta
+			if delta == 0 {
+				delta = 1e-6
+			}
+			require.InDeltaSlice(t, test.expectedValues, res.values, delta)
+			require.InDelta(t, test.expectedErr, res.err, delta)
+		})
+	}
+}
+
+func TestWape(t *testing.T) {
+	require.InDelta(t, 0.25, wape([]float64{2, 2}, []float64{1, 2}), 1e-9)
+	require.Equal(t, 0.0, wape([]float64{0, 0}, []float64{0, 0}))
+	require.Equal(t, 1.0, wape([]float64{0, 0}, []float64{1, 0}))
+}
+
+func TestResampleSeries(t *testing.T) {
+	start := time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC)
+	points := []metrics.SeriesPoint{
+		{Timestamp: start.Add(-time.Minute), Value: 1},
+		{Timestamp: start.Add(2 * time.Minute), Value: 2},
+		{Timestamp: start.Add(3*time.Minute + 10*time.Second), Value: 3},
+		{Timestamp: start.Add(6 * time.Minute), Value: 6},
+	}
+
+	first, values := resampleSeries(points, start, time.Minute)
+	require.Equal(t, start.Add(2*time.Minute), first)
+	require.Equal(t, []float64{2, 3, 3, 3, 6}, values)
+}
+
+func TestForecastRecordedSeries(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	series := loadSeriesFixture(t, reactorUtilizationFixture)
+	m := mockprometheusapi.NewMockApi(nil, replayedRangedQuery(series))
+	pp := metrics.NewPrometheusProvider(m, logger, time.Minute)
+	r := New(nil, pp, nil, Options{}, logger).(*recommender)
+
+	sc := newSingleDcSc("test-sc", "test-sc-ns", "dc_name",
+		[]scyllav1.RackSpec{*getRackSpec("rack_name", 3, "5", "5", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{"rack_name": *getRackStatus(3, 3)})
+	ctx = metrics.WithTarget(ctx, metrics.Target{Cluster: sc, Rack: &sc.Spec.Datacenter.Racks[0]})
+
+	for _, method := range []v1alpha1.ForecastMethod{v1alpha1.ForecastMethodHoltWinters, v1alpha1.ForecastMethodSeasonalNaive} {
+		t.Run(string(method), func(t *testing.T) {
+			recorded := series[0].Values[len(series[0].Values)-1].Timestamp.Time()
+			end := recorded.Add(time.Since(recorded).Truncate(24 * time.Hour))
+			prediction := &v1alpha1.PredictiveScaling{
+				Expression:  `avg(scylla_reactor_utilization{scylla_rack="{{ .Rack }}"})`,
+				TargetValue: 60,
+				Mode:        v1alpha1.ScalingModeHorizontal,
+				Method:      method,
+				Step:        &metav1.Duration{Duration: 30 * time.Minute},
+				Horizon:     &metav1.Duration{Duration: 24 * time.Hour},
+			}
+
+			forecast, err := r.forecast(ctx, pp, prediction, end)
+			require.NoError(t, err)
+			require.NotNil(t, forecast)
+			require.Equal(t, "dc_name", forecast.Datacenter)
+			require.Equal(t, "rack_name", forecast.Rack)
+			require.InDelta(t, 75, forecast.Peak, 5)
+			require.True(t, forecast.PeakTime.Time.After(end) && !forecast.PeakTime.Time.After(end.Add(24*time.Hour)))
+			require.Equal(t, 14, forecast.PeakTime.UTC().Hour())
+			require.Less(t, forecast.Error, 0.1)
+
+			prediction.History = &metav1.Duration{Duration: 72 * time.Hour}
+			prediction.Season = &metav1.Duration{Duration: 7 * 24 * time.Hour}
+			forecast, err = r.forecast(ctx, pp, prediction, end)
+			require.NoError(t, err)
+			require.Nil(t, forecast, "history shorter than two seasons")
+		})
+	}
+}
+
+func TestForecastIsRefittedOncePerStep(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	series := loadSeriesFixture(t, reactorUtilizationFixture)
+	queries := 0
+	replay := replayedRangedQuery(series)
+	m := mockprometheusapi.NewMockApi(nil, func(query string, r v1.Range) (model.Value, v1.Warnings, error) {
+		queries++
+		return replay(query, r)
+	})
+	pp := metrics.NewPrometheusProvider(m, logger, time.Minute)
+	r := New(nil, pp, nil, Options{}, logger).(*recommender)
+
+	sc := newSingleDcSc("test-sc", "test-sc-ns", "dc_name",
+		[]scyllav1.RackSpec{*getRackSpec("rack_name", 3, "5", "5", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{"rack_name": *getRackStatus(3, 3)})
+	ctx = metrics.WithTarget(ctx, metrics.Target{Cluster: sc, Rack: &sc.Spec.Datacenter.Racks[0]})
+
+	recorded := series[0].Values[len(series[0].Values)-1].Timestamp.Time()
+	end := recorded.Add(time.Since(recorded).Truncate(24 * time.Hour))
+	prediction := &v1alpha1.PredictiveScaling{
+		Expression:  `avg(scylla_reactor_utilization{scylla_rack="{{ .Rack }}"})`,
+		TargetValue: 60,
+		Mode:        v1alpha1.ScalingModeHorizontal,
+		Step:        &metav1.Duration{Duration: 30 * time.Minute},
+	}
+
+	first, err := r.forecast(ctx, pp, prediction, end)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	require.Equal(t, 1, queries)
+
+	first.Peak = 0
+	second, err := r.forecast(ctx, pp, prediction, end.Add(10*time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, second)
+	require.NotZero(t, second.Peak, "cached forecasts are returned as copies")
+	require.Equal(t, 1, queries, "forecast refitted within the step")
+
+	_, err = r.forecast(ctx, pp, prediction, end.Add(30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 2, queries, "forecast not refitted after the step")
+
+	r.results.prune()
+	r.results.prune()
+	_, err = r.forecast(ctx, pp, prediction, end.Add(40*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 3, queries, "forecast of an unused rack not pruned")
+}
+
+func TestRunOnceScalesAheadOfForecastPeaks(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	series := loadSeriesFixture(t, reactorUtilizationFixture)
+
+	tests := []struct {
+		name          string
+		mode          v1alpha1.ScalingMode
+		targetValue   float64
+		noRecommended bool
+	}{
+		{
+			name:        "horizontal",
+			mode:        v1alpha1.ScalingModeHorizontal,
+			targetValue: 50,
+		},
+		{
+			name:        "vertical",
+			mode:        v1alpha1.ScalingModeVertical,
+			targetValue: 50,
+		},
+		{
+			name:          "forecast peak below target",
+			mode:          v1alpha1.ScalingModeHorizontal,
+			targetValue:   90,
+			noRecommended: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc := newSingleDcSc("test-sc", "test-sc-ns", dcName,
+				[]scyllav1.RackSpec{*getRackSpec(rackName, 3, "5", "5", "1Gi", "1Gi")},
+				map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)})
+			rackPolicy := newRackScalingPolicy(rackName,
+				[]v1alpha1.ScalingRule{
+					*newScalingRule("rule_name", 1, mockprometheusapi.QueryWillReturnFalse, nil, nil, v1alpha1.ScalingModeHorizontal, 2),
+				},
+				1, 100, resource.MustParse("1"), resource.MustParse("100"),
+				v1alpha1.RackControlledValuesRequestsAndLimits)
+			rackPolicy.Prediction = &v1alpha1.PredictiveScaling{
+				Expression:  "avg(scylla_reactor_utilization)",
+				TargetValue: test.targetValue,
+				Mode:        test.mode,
+				Method:      v1alpha1.ForecastMethodSeasonalNaive,
+				Step:        &metav1.Duration{Duration: 30 * time.Minute},
+				Horizon:     &metav1.Duration{Duration: 24 * time.Hour},
+			}
+			sca := newSingleDcSca("test-sca", "test-sca-ns", sc.Name, sc.Namespace, dcName, rackPolicy)
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(getStatefulSets(sc), sc, sca)...).Build()
+			m := mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), replayedRangedQuery(series))
+			pp := metrics.NewPrometheusProvider(m, logger, time.Minute)
+			r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{}, logger)
+			require.NoError(t, r.RunOnce(ctx))
+
+			res := &v1alpha1.ScyllaClusterAutoscaler{}
+			require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+			require.Equal(t, v1alpha1.UpdateStatusOk, *res.Status.UpdateStatus)
+			require.Len(t, res.Status.Forecasts, 1)
+			require.Equal(t, rackName, res.Status.Forecasts[0].Rack)
+			require.False(t, math.IsNaN(res.Status.Forecasts[0].Error))
+
+			if test.noRecommended {
+				require.Nil(t, res.Status.Recommendations)
+				return
+			}
+			require.NotNil(t, res.Status.Recommendations)
+			rec := res.Status.Recommendations.DatacenterRecommendations[0].RackRecommendations[0]
+			factor := res.Status.Forecasts[0].Peak / test.targetValue
+			expectedMembers, expectedCpu := int32(3), int64(5000)
+			if test.mode == v1alpha1.ScalingModeHorizontal {
+				expectedMembers = int32(math.Ceil(3 * factor))
+			} else {
+				expectedCpu = int64(5000 * factor)
+			}
+			require.Equal(t, util.Int32ptr(expectedMembers), rec.Members)
+			require.Equal(t, expectedCpu, rec.Resources.Requests.Cpu().MilliValue())
+			require.Equal(t, expectedCpu, rec.Resources.Limits.Cpu().MilliValue())
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Variables available in the rules' formulas, apart from the rules' metrics.
+const (
+	// formulaCurrent is the current value of the scaled resource, i.e. members or CPU in cores.
+	formulaCurrent = "current"
+	// formulaMembers is the current number of the rack's members.
+	formulaMembers = "members"
+	// formulaCPU is the rack's current CPU requests in cores.
+	formulaCPU = "cpu"
+	// formulaMinAllowed and formulaMaxAllowed are the policy bounds of the scaled resource.
+	// They are undefined if the policy doesn't set them.
+	formulaMinAllowed = "minAllowed"
+	formulaMaxAllowed = "maxAllowed"
+)
+
+var formulaVariableNames = []string{formulaCurrent, formulaMembers, formulaCPU, formulaMinAllowed, formulaMaxAllowed}
+
+// maxFormulaArguments is the largest number of arguments of min and max in formulas.
+const maxFormulaArguments = 8
+
+// formulaEnv is the CEL environment of the formulas, declaring the variables available in all of them, and the
+// numeric functions missing from CEL: abs, ceil and floor of a double, and min and max of 2 to maxFormulaArguments
+// doubles.
+var formulaEnv, formulaFunctions = newFormulaEnv()
+
+func newFormulaEnv() (*cel.Env, []*functions.Overload) {
+	var declarations []*exprpb.Decl
+	for _, name := range formulaVariableNames {
+		declarations = append(declarations, decls.NewVar(name, decls.Double))
+	}
+
+	var impls []*functions.Overload
+	for name, fn := range map[string]func(float64) float64{
+		"abs":   math.Abs,
+		"ceil":  math.Ceil,
+		"floor": math.Floor,
+	} {
+		fn := fn
+		declarations = append(declarations, decls.NewFunction(name,
+			decls.NewOverload(name+"_double", []*exprpb.Type{decls.Double}, decls.Double)))
+		impls = append(impls, &functions.Overload{
+			Operator: name,
+			Unary: func(value ref.Val) ref.Val {
+				v, ok := value.(types.Double)
+				if !ok {
+					return types.MaybeNoSuchOverloadErr(value)
+				}
+				return types.Double(fn(float64(v)))
+			},
+		})
+	}
+	for name, fn := range map[string]func(float64, float64) float64{
+		"min": math.Min,
+		"max": math.Max,
+	} {
+		fn := fn
+		var overloads []*exprpb.Decl_FunctionDecl_Overload
+		args := []*exprpb.Type{decls.Double}
+		for n := 2; n <= maxFormulaArguments; n++ {
+			args = append(args, decls.Double)
+			overloads = append(overloads, decls.NewOverload(fmt.Sprintf("%s_double_%d", name, n),
+				append([]*exprpb.Type(nil), args...), decls.Double))
+		}
+		declarations = append(declarations, decls.NewFunction(name, overloads...))
+		reduce := func(values ...ref.Val) ref.Val {
+			var res float64
+			for i, value := range values {
+				v, ok := value.(types.Double)
+				if !ok {
+					return types.MaybeNoSuchOverloadErr(value)
+				}
+				if i == 0 {
+					res = float64(v)
+				} else {
+					res = fn(res, float64(v))
+				}
+			}
+			return types.Double(res)
+		}
+		impls = append(impls, &functions.Overload{
+			Operator: name,
+			Binary: func(lhs, rhs ref.Val) ref.Val {
+				return reduce(lhs, rhs)
+			},
+			Function: reduce,
+		})
+	}
+
+	env, err := cel.NewEnv(cel.Declarations(declarations...))
+	if err != nil {
+		panic(errors.Wrap(err, "formula environment"))
+	}
+	return env, impls
+}
+
+// formulaVariables returns the values of the variables available in the rule's formula for the rack.
+func formulaVariables(rack *scyllav1.RackSpec, mode v1alpha1.ScalingMode, scalingPolicy *v1alpha1.RackScalingPolicy) map[string]float64 {
+	variables := map[string]float64{
+		formulaMembers: float64(rack.Members),
+	}
+	if cpu, ok := rack.Resources.Requests[corev1.ResourceCPU]; ok {
+		variables[formulaCPU] = float64(cpu.MilliValue()) / 1000
+	}
+
+	if mode == v1alpha1.ScalingModeHorizontal {
+		variables[formulaCurrent] = variables[formulaMembers]
+		if mp := scalingPolicy.MemberPolicy; mp != nil {
+			if mp.MinAllowed != nil {
+				variables[formulaMinAllowed] = float64(*mp.MinAllowed)
+			}
+			if mp.MaxAllowed != nil {
+				variables[formulaMaxAllowed] = float64(*mp.MaxAllowed)
+			}
+		}
+	} else {
+		if cpu, ok := variables[formulaCPU]; ok {
+			variables[formulaCurrent] = cpu
+		}
+		if rp := scalingPolicy.ResourcePolicy; rp != nil {
+			if rp.MinAllowedCpu != nil {
+				variables[formulaMinAllowed] = float64(rp.MinAllowedCpu.MilliValue()) / 1000
+			}
+			if rp.MaxAllowedCpu != nil {
+				variables[formulaMaxAllowed] = float64(rp.MaxAllowedCpu.MilliValue()) / 1000
+			}
+		}
+	}
+
+	return variables
+}
+
+// formulaKey identifies a compiled formula, which depends on the names of the rule's metrics.
+type formulaKey struct {
+	formula string
+	metrics string
+}
+
+// formulaPrograms caches the programs of the formulas compiled so far, so that formulas are compiled
+// once instead of on every evaluation.
+var formulaPrograms sync.Map
+
+// compileFormula compiles the formula as a CEL expression, with the available variables and the rule's metrics
+// declared as doubles, and type-checks it. Numeric literals are doubles, so that e.g. "current * 2" type-checks.
+func compileFormula(formula string, ruleMetrics []v1alpha1.RuleMetric) (cel.Program, error) {
+	names := make([]string, 0, len(ruleMetrics))
+	for _, m := range ruleMetrics {
+		names = append(names, m.Name)
+	}
+	key := formulaKey{formula: formula, metrics: strings.Join(names, ",")}
+	if prg, ok := formulaPrograms.Load(key); ok {
+		return prg.(cel.Program), nil
+	}
+
+	prg, err := newFormulaProgram(formula, ruleMetrics)
+	if err != nil {
+		return nil, err
+	}
+	formulaPrograms.Store(key, prg)
+	return prg, nil
+}
+
+func newFormulaProgram(formula string, ruleMetrics []v1alpha1.RuleMetric) (cel.Program, error) {
+	declarations := make([]*exprpb.Decl, 0, len(ruleMetrics))
+	known := make(map[string]bool, len(formulaVariableNames)+len(ruleMetrics))
+	for _, name := range formulaVariableNames {
+		known[name] = true
+	}
+	for _, m := range ruleMetrics {
+		if known[m.Name] {
+			return nil, errors.Errorf("metric \"%s\" is defined more than once or shadows a variable", m.Name)
+		}
+		declarations = append(declarations, decls.NewVar(m.Name, decls.Double))
+		known[m.Name] = true
+	}
+
+	env, err := formulaEnv.Extend(cel.Declarations(declarations...))
+	if err != nil {
+		return nil, errors.Wrap(err, "formula environment")
+	}
+	ast, issues := env.Parse(formula)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Wrap(issues.Err(), "compile formula")
+	}
+	parsed, err := cel.AstToParsedExpr(ast)
+	if err != nil {
+		return nil, errors.Wrap(err, "compile formula")
+	}
+	literalsToDoubles(parsed.GetExpr())
+	ast, issues = env.Check(cel.ParsedExprToAst(parsed))
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Wrap(issues.Err(), "compile formula")
+	}
+	if t := ast.ResultType(); t.GetPrimitive() != exprpb.Type_DOUBLE {
+		return nil, errors.Errorf("formula has to evaluate to a double, not %s", checker.FormatCheckedType(t))
+	}
+
+	prg, err := env.Program(ast, cel.Functions(formulaFunctions...))
+	if err != nil {
+		return nil, errors.Wrap(err, "formula program")
+	}
+	return prg, nil
+}
+
+// literalsToDoubles replaces the integer literals of the parsed expression with doubles.
+func literalsToDoubles(expr *exprpb.Expr) {
+	if expr == nil {
+		return
+	}
+
+	switch kind := expr.GetExprKind().(type) {
+	case *exprpb.Expr_ConstExpr:
+		switch c := kind.ConstExpr.GetConstantKind().(type) {
+		case *exprpb.Constant_Int64Value:
+			kind.ConstExpr.ConstantKind = &exprpb.Constant_DoubleValue{DoubleValue: float64(c.Int64Value)}
+		case *exprpb.Constant_Uint64Value:
+			kind.ConstExpr.ConstantKind = &exprpb.Constant_DoubleValue{DoubleValue: float64(c.Uint64Value)}
+		}
+	case *exprpb.Expr_SelectExpr:
+		literalsToDoubles(kind.SelectExpr.GetOperand())
+	case *exprpb.Expr_CallExpr:
+		literalsToDoubles(kind.CallExpr.GetTarget())
+		for _, arg := range kind.CallExpr.GetArgs() {
+			literalsToDoubles(arg)
+		}
+	case *exprpb.Expr_ListExpr:
+		for _, elem := range kind.ListExpr.GetElements() {
+			literalsToDoubles(elem)
+		}
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range kind.StructExpr.GetEntries() {
+			literalsToDoubles(entry.GetMapKey())
+			literalsToDoubles(entry.GetValue())
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		c := kind.ComprehensionExpr
+		for _, e := range []*exprpb.Expr{c.GetIterRange(), c.GetAccuInit(), c.GetLoopCondition(), c.GetLoopStep(), c.GetResult()} {
+			literalsToDoubles(e)
+		}
+	}
+}
+
+// evaluateFormula evaluates the rule's formula. The rule's metrics are queried with their templates rendered
+// with data, only if the formula references them.
+func evaluateFormula(ctx context.Context, provider metrics.Provider, rule *v1alpha1.ScalingRule, data ExpressionData, variables map[string]float64) (float64, error) {
+	prg, err := compileFormula(rule.Formula, rule.Metrics)
+	if err != nil {
+		return 0, err
+	}
+
+	bindings := make(map[string]interface{}, len(variables)+len(rule.Metrics))
+	for name, v := range variables {
+		bindings[name] = v
+	}
+	// The first failed query is kept, so that e.g. metrics.ErrSourceUnhealthy can be told apart.
+	var queryErr error
+	for _, m := range rule.Metrics {
+		name, expr := m.Name, m.Expression
+		bindings[name] = func() ref.Val {
+			rendered, err := renderExpression(expr, data)
+			if err == nil {
+				var v float64
+				if v, err = provider.QueryValue(ctx, rendered); err == nil {
+					return types.Double(v)
+				}
+			}
+			err = errors.Wrapf(err, "metric \"%s\"", name)
+			if queryErr == nil {
+				queryErr = err
+			}
+			return types.NewErr(err.Error())
+		}
+	}
+
+	out, _, err := prg.Eval(bindings)
+	if queryErr != nil {
+		return 0, errors.Wrap(queryErr, "evaluate formula")
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "evaluate formula")
+	}
+	res, ok := out.Value().(float64)
+	if !ok {
+		return 0, errors.Errorf("formula evaluated to %v instead of a double", out.Value())
+	}
+	if math.IsNaN(res) || math.IsInf(res, 0) || res < 0 {
+		return 0, errors.Errorf("formula evaluated to invalid value %v", res)
+	}
+
+	return res, nil
+}
+
+// validateFormula checks whether the formula compiles to a double, only referencing the available variables
+// and the rule's metrics.
+func validateFormula(formula string, ruleMetrics []v1alpha1.RuleMetric) error {
+	_, err := compileFormula(formula, ruleMetrics)
+	return err
+}
+
+// MembersFromFormula rounds up the result of a formula to the number of members, clamped by min and max.
+func MembersFromFormula(value float64, min, max *int32) int32 {
+	var val int32 = math.MaxInt32
+	if value < float64(math.MaxInt32) {
+		val = int32(math.Ceil(value))
+	}
+
+	if max != nil {
+		val = util.MinInt32(val, *max)
+	}
+
+	if min != nil {
+		val = util.MaxInt32(val, *min)
+	}
+
+	return val
+}
+
+// CPUFromFormula rounds up the result of a formula in cores to CPU millicores, clamped by min and max.
+func CPUFromFormula(value float64, min, max *resource.Quantity) resource.Quantity {
+	var val resource.Quantity
+	if value*1000 < float64(math.MaxInt64) {
+		val = *resource.NewMilliQuantity(int64(math.Ceil(value*1000)), resource.DecimalSI)
+	} else {
+		val = *resource.NewQuantity(math.MaxInt64, resource.DecimalSI)
+	}
+
+	if max != nil {
+		val = util.MinQuantity(val, *max)
+	}
+
+	if min != nil {
+		val = util.MaxQuantity(val, *min)
+	}
+
+	return val
+}
@@ -0,0 +1,248 @@
+package recommender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestEvaluateFormula(t *testing.T) {
+	variables := map[string]float64{
+		formulaCurrent:    3,
+		formulaMembers:    3,
+		formulaMinAllowed: 1,
+	}
+	data := ExpressionData{Rack: "us-east-1a"}
+
+	tests := []struct {
+		name            string
+		formula         string
+		metrics         []v1alpha1.RuleMetric
+		expected        float64
+		expectedQueries []string
+		errorExpected   bool
+	}{
+		{
+			name:     "variables",
+			formula:  "current * 2.0 + minAllowed",
+			expected: 7,
+		},
+		{
+			name:            "metrics",
+			formula:         "ceil(current * usage / 0.6)",
+			metrics:         []v1alpha1.RuleMetric{{Name: "usage", Expression: "0.9"}},
+			expected:        5,
+			expectedQueries: []string{"0.9"},
+		},
+		{
+			name:    "max of arguments",
+			formula: "max(current, rf)",
+			metrics: []v1alpha1.RuleMetric{
+				{Name: "rf", Expression: "5"},
+				{Name: "unused", Expression: "1"},
+			},
+			expected:        5,
+			expectedQueries: []string{"5"},
+		},
+		{
+			name:            "metric is queried once",
+			formula:         "rf * rf",
+			metrics:         []v1alpha1.RuleMetric{{Name: "rf", Expression: "3"}},
+			expected:        9,
+			expectedQueries: []string{"3"},
+		},
+		{
+			name:            "metric expression is rendered",
+			formula:         "value",
+			metrics:         []v1alpha1.RuleMetric{{Name: "value", Expression: `{{ if eq .Rack "us-east-1a" }}4{{ else }}2{{ end }}`}},
+			expected:        4,
+			expectedQueries: []string{"4"},
+		},
+		{
+			name:          "undefined variable",
+			formula:       "maxAllowed",
+			errorExpected: true,
+		},
+		{
+			name:            "query error",
+			formula:         "value",
+			metrics:         []v1alpha1.RuleMetric{{Name: "value", Expression: "not a number"}},
+			expectedQueries: []string{"not a number"},
+			errorExpected:   true,
+		},
+		{
+			name:          "negative result",
+			formula:       "current - 5.0",
+			errorExpected: true,
+		},
+		{
+			name:          "malformed formula",
+			formula:       "current *",
+			errorExpected: true,
+		},
+		{
+			name:            "functions",
+			formula:         "min(abs(current - 5.0), floor(value), 7.0)",
+			metrics:         []v1alpha1.RuleMetric{{Name: "value", Expression: "2.5"}},
+			expected:        2,
+			expectedQueries: []string{"2.5"},
+		},
+		{
+			name:     "integer literals",
+			formula:  "current * 2 + max(members, 3) / 2",
+			expected: 7.5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &recordingProvider{}
+			rule := &v1alpha1.ScalingRule{Formula: test.formula, Metrics: test.metrics}
+			res, err := evaluateFormula(context.Background(), p, rule, data, variables)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+			require.Equal(t, test.expectedQueries, p.queries)
+		})
+	}
+}
+
+func TestCompileFormulaIsCached(t *testing.T) {
+	metrics := []v1alpha1.RuleMetric{{Name: "usage", Expression: "up"}}
+
+	prg, err := compileFormula("current * usage", metrics)
+	require.NoError(t, err)
+	cached, err := compileFormula("current * usage", metrics)
+	require.NoError(t, err)
+	require.Same(t, prg, cached)
+
+	// The same formula is compiled anew for other metrics.
+	_, err = compileFormula("current * usage", nil)
+	require.Error(t, err)
+}
+
+func TestFormulaVariables(t *testing.T) {
+	rack := getRackSpec("rack", 3, "1500m", "2", "1Gi", "1Gi")
+	minCpu, maxCpu := resource.MustParse("1"), resource.MustParse("8")
+	policy := &v1alpha1.RackScalingPolicy{
+		MemberPolicy: &v1alpha1.RackMemberPolicy{
+			MaxAllowed: util.Int32ptr(10),
+		},
+		ResourcePolicy: &v1alpha1.RackResourcePolicy{
+			MinAllowedCpu: &minCpu,
+			MaxAllowedCpu: &maxCpu,
+		},
+	}
+
+	require.Equal(t, map[string]float64{
+		formulaCurrent:    3,
+		formulaMembers:    3,
+		formulaCPU:        1.5,
+		formulaMaxAllowed: 10,
+	}, formulaVariables(rack, v1alpha1.ScalingModeHorizontal, policy))
+
+	require.Equal(t, map[string]float64{
+		formulaCurrent:    1.5,
+		formulaMembers:    3,
+		formulaCPU:        1.5,
+		formulaMinAllowed: 1,
+		formulaMaxAllowed: 8,
+	}, formulaVariables(rack, v1alpha1.ScalingModeVertical, policy))
+}
+
+func TestValidateFormula(t *testing.T) {
+	tests := []struct {
+		name          string
+		formula       string
+		metrics       []v1alpha1.RuleMetric
+		errorExpected bool
+	}{
+		{
+			name:    "variables and metrics",
+			formula: "min(maxAllowed, max(current, members, cpu, minAllowed, ceil(current * usage / 0.6)))",
+			metrics: []v1alpha1.RuleMetric{{Name: "usage", Expression: "up"}},
+		},
+		{
+			name:          "undefined variable",
+			formula:       "current * usage",
+			errorExpected: true,
+		},
+		{
+			name:          "selector",
+			formula:       `current * usage{rack="a"}`,
+			metrics:       []v1alpha1.RuleMetric{{Name: "usage", Expression: "up"}},
+			errorExpected: true,
+		},
+		{
+			name:          "duplicate metric",
+			formula:       "usage",
+			metrics:       []v1alpha1.RuleMetric{{Name: "usage", Expression: "up"}, {Name: "usage", Expression: "up"}},
+			errorExpected: true,
+		},
+		{
+			name:          "metric shadowing a variable",
+			formula:       "current",
+			metrics:       []v1alpha1.RuleMetric{{Name: "current", Expression: "up"}},
+			errorExpected: true,
+		},
+		{
+			name:          "malformed formula",
+			formula:       "ceil(current",
+			errorExpected: true,
+		},
+		{
+			name:    "integer literals",
+			formula: "max(members, 3) + current * 2",
+		},
+		{
+			name:          "non-double result",
+			formula:       "current > 1",
+			errorExpected: true,
+		},
+		{
+			name:    "max of many arguments",
+			formula: "max(current, members, cpu, minAllowed, maxAllowed, 1.0, 2.0, 3.0)",
+		},
+		{
+			name:          "max of too many arguments",
+			formula:       "max(current, members, cpu, minAllowed, maxAllowed, 1.0, 2.0, 3.0, 4.0)",
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateFormula(test.formula, test.metrics)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMembersFromFormula(t *testing.T) {
+	min, max := util.Int32ptr(2), util.Int32ptr(10)
+
+	require.Equal(t, int32(5), MembersFromFormula(4.2, min, max))
+	require.Equal(t, int32(2), MembersFromFormula(0.5, min, max))
+	require.Equal(t, int32(10), MembersFromFormula(1e12, min, max))
+	require.Equal(t, int32(4), MembersFromFormula(4, nil, nil))
+}
+
+func TestCPUFromFormula(t *testing.T) {
+	min, max := resource.MustParse("500m"), resource.MustParse("4")
+
+	require.True(t, resource.MustParse("1501m").Equal(CPUFromFormula(1.5001, &min, &max)))
+	require.True(t, min.Equal(CPUFromFormula(0.1, &min, &max)))
+	require.True(t, max.Equal(CPUFromFormula(1e30, &min, &max)))
+	require.True(t, resource.MustParse("2").Equal(CPUFromFormula(2, nil, nil)))
+}
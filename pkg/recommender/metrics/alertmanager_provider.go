@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/expression"
+)
+
+const (
+	alertNameLabel = "alertname"
+
+	// Labels attributing alerts to the racks of ScyllaClusters, as set by Scylla Monitoring.
+	alertNamespaceLabel  = "namespace"
+	alertClusterLabel    = "cluster"
+	alertDatacenterLabel = "dc"
+	alertRackLabel       = "rack"
+
+	alertStatusFiring   = "firing"
+	alertStatusResolved = "resolved"
+
+	// maxAlertPayloadSize limits the size of the accepted webhook payloads.
+	maxAlertPayloadSize = 1 << 20
+)
+
+// alertmanagerPayload is the subset of the Alertmanager webhook payload used by the receiver.
+type alertmanagerPayload struct {
+	Alerts []struct {
+		Status      string            `json:"status"`
+		Labels      map[string]string `json:"labels"`
+		StartsAt    time.Time         `json:"startsAt"`
+		EndsAt      time.Time         `json:"endsAt"`
+		Fingerprint string            `json:"fingerprint"`
+	} `json:"alerts"`
+}
+
+type alert struct {
+	labels   map[string]string
+	startsAt time.Time
+	endsAt   time.Time
+}
+
+// FiredAlert is an alert which started firing.
+type FiredAlert struct {
+	Name   string
+	Labels map[string]string
+}
+
+// TargetsCluster checks whether the alert belongs to the ScyllaCluster with the given namespace and name.
+func (a FiredAlert) TargetsCluster(namespace, name string) bool {
+	return alertTargets(a.Labels, namespace, name, "", "")
+}
+
+// alertTargets checks whether an alert with the given labels belongs to the given rack of a ScyllaCluster.
+// The alert's cluster label has to name the cluster, while its namespace, datacenter and rack labels only have
+// to match if they are set. Empty datacenter and rack match any.
+func alertTargets(labels map[string]string, namespace, cluster, datacenter, rack string) bool {
+	if labels[alertClusterLabel] != cluster {
+		return false
+	}
+	matches := func(label, value string) bool {
+		v, ok := labels[label]
+		return !ok || value == "" || v == value
+	}
+	return matches(alertNamespaceLabel, namespace) && matches(alertDatacenterLabel, datacenter) && matches(alertRackLabel, rack)
+}
+
+// AlertReceiver is an http.Handler accepting Alertmanager webhook payloads authenticated with a bearer token.
+// It keeps track of the firing alerts and notifies about the alerts which started firing.
+type AlertReceiver struct {
+	logger log.Logger
+	token  []byte
+
+	mu     sync.Mutex
+	alerts map[string]alert
+	fired  map[string]FiredAlert
+	notify chan struct{}
+}
+
+// NewAlertReceiver creates a receiver accepting the payloads sent with the given bearer token, which can't be empty.
+func NewAlertReceiver(logger log.Logger, token string) (*AlertReceiver, error) {
+	if token == "" {
+		return nil, errors.New("empty token")
+	}
+
+	return &AlertReceiver{
+		logger: logger,
+		token:  []byte(token),
+		alerts: make(map[string]alert),
+		fired:  make(map[string]FiredAlert),
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+func (ar *AlertReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ar.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payload := &alertmanagerPayload{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAlertPayloadSize)).Decode(payload); err != nil {
+		ar.logger.Error(r.Context(), "decode alertmanager payload", "error", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	now := time.Now()
+	for _, a := range payload.Alerts {
+		key := a.Fingerprint
+		if key == "" {
+			key = labelsKey(a.Labels)
+		}
+
+		switch a.Status {
+		case alertStatusFiring:
+			if _, ok := ar.alerts[key]; !ok {
+				ar.fired[key] = FiredAlert{Name: a.Labels[alertNameLabel], Labels: a.Labels}
+			}
+			startsAt := a.StartsAt
+			if startsAt.IsZero() {
+				startsAt = now
+			}
+			ar.alerts[key] = alert{labels: a.Labels, startsAt: startsAt, endsAt: a.EndsAt}
+		case alertStatusResolved:
+			delete(ar.alerts, key)
+		}
+	}
+
+	if len(ar.fired) > 0 {
+		select {
+		case ar.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized checks whether the request carries the receiver's bearer token.
+func (ar *AlertReceiver) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), ar.token) == 1
+}
+
+// Notifications returns a channel which receives a value whenever an alert starts firing.
+// The alerts are retrieved with PopFired.
+func (ar *AlertReceiver) Notifications() <-chan struct{} {
+	return ar.notify
+}
+
+// PopFired returns the alerts which started firing since the last call.
+func (ar *AlertReceiver) PopFired() []FiredAlert {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	alerts := make([]FiredAlert, 0, len(ar.fired))
+	for _, a := range ar.fired {
+		alerts = append(alerts, a)
+	}
+	ar.fired = make(map[string]FiredAlert)
+
+	return alerts
+}
+
+// isFiring checks whether an alert of the target with the given name and labels matching the selector
+// has been firing since at least the given time.
+func (ar *AlertReceiver) isFiring(target Target, name string, selector expression.Selector, since time.Time) bool {
+	rack := ""
+	if target.Rack != nil {
+		rack = target.Rack.Name
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	now := time.Now()
+	for key, a := range ar.alerts {
+		// Alertmanager sets the end time of firing alerts to when they are considered resolved
+		// in case they are not updated.
+		if !a.endsAt.IsZero() && a.endsAt.Before(now) {
+			delete(ar.alerts, key)
+			continue
+		}
+		if a.labels[alertNameLabel] == name && selector.Matches(a.labels) && !a.startsAt.After(since) &&
+			alertTargets(a.labels, target.Cluster.Namespace, target.Cluster.Name, target.Cluster.Spec.Datacenter.Name, rack) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func labelsKey(labels map[string]string) string {
+	// json.Marshal sorts the keys of maps.
+	key, _ := json.Marshal(labels)
+	return string(key)
+}
+
+// alertmanagerProvider evaluates expressions (see package expression) against the alerts received
+// by an AlertReceiver. A metric reference is an alert name optionally followed by a label selector,
+// and it resolves to 1 if a matching alert of the target is firing, and to 0 otherwise.
+// In ranged queries, references resolve to 1 only if a matching alert has been firing for the whole range.
+type alertmanagerProvider struct {
+	provider
+	receiver *AlertReceiver
+}
+
+func NewAlertmanagerProvider(receiver *AlertReceiver, logger log.Logger) Provider {
+	return &alertmanagerProvider{
+		provider: provider{
+			logger: logger,
+		},
+		receiver: receiver,
+	}
+}
+
+func (p *alertmanagerProvider) Query(ctx context.Context, expr string) (bool, error) {
+	res, err := p.query(ctx, expr, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return res != 0, nil
+}
+
+func (p *alertmanagerProvider) QueryValue(ctx context.Context, expr string) (float64, error) {
+	return p.query(ctx, expr, time.Now())
+}
+
+func (p *alertmanagerProvider) RangedQuery(ctx context.Context, expr string, duration time.Duration, _ *time.Duration) (bool, error) {
+	res, err := p.query(ctx, expr, time.Now().Add(-duration))
+	if err != nil {
+		return false, err
+	}
+	return res != 0, nil
+}
+
+func (p *alertmanagerProvider) query(ctx context.Context, expr string, since time.Time) (float64, error) {
+	target, ok := TargetFromContext(ctx)
+	if !ok {
+		return 0, errors.New("query target not specified")
+	}
+
+	e, err := expression.Parse(expr)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse expression")
+	}
+
+	res, err := e.Evaluate(ctx, expression.ResolverFunc(func(_ context.Context, ref expression.Reference) ([]float64, error) {
+		if p.receiver.isFiring(target, ref.Name, ref.Selector, since) {
+			return []float64{1}, nil
+		}
+		return []float64{0}, nil
+	}))
+	if err != nil {
+		return 0, errors.Wrap(err, "query")
+	}
+
+	return res, nil
+}
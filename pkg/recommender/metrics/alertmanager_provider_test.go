@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAlertmanagerProvider(t *testing.T) {
+	sc := &scyllav1.ScyllaCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-cluster", Namespace: "scylla"},
+		Spec: scyllav1.ClusterSpec{
+			Datacenter: scyllav1.DatacenterSpec{
+				Name:  "us-east-1",
+				Racks: []scyllav1.RackSpec{{Name: "us-east-1a"}},
+			},
+		},
+	}
+	ctx := WithTarget(log.WithNewTraceID(context.Background()), Target{Cluster: sc, Rack: &sc.Spec.Datacenter.Racks[0]})
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	receiver, err := NewAlertReceiver(logger, "token")
+	require.NoError(t, err)
+	p := NewAlertmanagerProvider(receiver, logger)
+
+	request := func(method, token, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/alerts", strings.NewReader(payload))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		return rec
+	}
+	send := func(t *testing.T, payload string) {
+		require.Equal(t, http.StatusOK, request(http.MethodPost, "token", payload).Code)
+	}
+	startsAt := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339)
+
+	send(t, `{"version":"4","status":"firing","alerts":[
+		{"status":"firing","labels":{"alertname":"ScyllaOverload","cluster":"simple-cluster"},"startsAt":"`+startsAt+`","fingerprint":"a"},
+		{"status":"firing","labels":{"alertname":"ScyllaDiskFull","cluster":"other-cluster"},"fingerprint":"b"},
+		{"status":"firing","labels":{"alertname":"ScyllaNodeDown","cluster":"simple-cluster","dc":"us-east-1","rack":"us-east-1b"},"startsAt":"`+startsAt+`","fingerprint":"c"},
+		{"status":"firing","labels":{"alertname":"ScyllaCompactionsPending"},"startsAt":"`+startsAt+`","fingerprint":"d"}
+	]}`)
+
+	select {
+	case <-receiver.Notifications():
+	default:
+		t.Fatal("expected a notification")
+	}
+	var fired []string
+	for _, a := range receiver.PopFired() {
+		fired = append(fired, a.Name)
+		require.Equal(t, a.Name == "ScyllaOverload" || a.Name == "ScyllaNodeDown", a.TargetsCluster("scylla", "simple-cluster"))
+	}
+	require.ElementsMatch(t, []string{"ScyllaOverload", "ScyllaDiskFull", "ScyllaNodeDown", "ScyllaCompactionsPending"}, fired)
+	require.Empty(t, receiver.PopFired())
+
+	tests := []struct {
+		name           string
+		expression     string
+		duration       time.Duration
+		expectedResult bool
+		errorExpected  bool
+	}{
+		{name: "firing alert", expression: `ScyllaOverload`, expectedResult: true},
+		{name: "firing alert with matching labels", expression: `ScyllaOverload{cluster="simple-cluster"}`, expectedResult: true},
+		{name: "firing alert with different labels", expression: `ScyllaDiskFull{cluster="simple-cluster"}`, expectedResult: false},
+		{name: "not firing alert", expression: `ScyllaTooManyFiles`, expectedResult: false},
+		{name: "alert of another cluster", expression: `ScyllaDiskFull`, expectedResult: false},
+		{name: "alert of another rack", expression: `ScyllaNodeDown`, expectedResult: false},
+		{name: "alert without cluster label", expression: `ScyllaCompactionsPending`, expectedResult: false},
+		{name: "logical operators", expression: `ScyllaOverload and not ScyllaNodeDown`, expectedResult: true},
+		{name: "firing for the whole range", expression: `ScyllaOverload`, duration: 5 * time.Minute, expectedResult: true},
+		{name: "not firing for the whole range", expression: `ScyllaDiskFull`, duration: 5 * time.Minute, expectedResult: false},
+		{name: "invalid expression", expression: `ScyllaOverload and`, errorExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				res bool
+				err error
+			)
+			if test.duration > 0 {
+				res, err = p.RangedQuery(ctx, test.expression, test.duration, nil)
+			} else {
+				res, err = p.Query(ctx, test.expression)
+			}
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expectedResult, res)
+			}
+		})
+	}
+
+	t.Run("target not specified", func(t *testing.T) {
+		_, err := p.Query(context.Background(), `ScyllaOverload`)
+		require.Error(t, err)
+	})
+
+	t.Run("resolved alert", func(t *testing.T) {
+		send(t, `{"version":"4","status":"resolved","alerts":[
+			{"status":"resolved","labels":{"alertname":"ScyllaOverload","cluster":"simple-cluster"},"fingerprint":"a"}
+		]}`)
+
+		res, err := p.Query(ctx, `ScyllaOverload`)
+		require.NoError(t, err)
+		require.False(t, res)
+		require.Empty(t, receiver.PopFired())
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, request(http.MethodPost, "token", "{").Code)
+	})
+
+	t.Run("oversized payload", func(t *testing.T) {
+		payload := `{"alerts":[],"padding":"` + strings.Repeat("x", maxAlertPayloadSize) + `"}`
+		require.Equal(t, http.StatusBadRequest, request(http.MethodPost, "token", payload).Code)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		payload := `{"alerts":[{"status":"firing","labels":{"alertname":"ScyllaOverload","cluster":"simple-cluster"},"fingerprint":"e"}]}`
+		require.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "", payload).Code)
+		require.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "other", payload).Code)
+		require.Empty(t, receiver.PopFired())
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		require.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "token", "").Code)
+	})
+}
+
+func TestNewAlertReceiverRequiresToken(t *testing.T) {
+	_, err := NewAlertReceiver(log.NewDevelopment(), "")
+	require.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package expression
+
+import (
+	"context"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// value is either a scalar or a vector of values a metric reference resolved to.
+type value struct {
+	values []float64
+	vector bool
+}
+
+func scalar(f float64) value {
+	return value{values: []float64{f}}
+}
+
+func (v value) scalar() (float64, error) {
+	if !v.vector {
+		return v.values[0], nil
+	}
+	switch len(v.values) {
+	case 0:
+		return 0, errors.New("no results")
+	case 1:
+		return v.values[0], nil
+	}
+	return 0, errors.Errorf("expected a single value, got %d; use an aggregation function", len(v.values))
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func evaluate(ctx context.Context, n node, resolver Resolver) (value, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return scalar(n.value), nil
+	case *referenceNode:
+		values, err := resolver.Resolve(ctx, n.ref)
+		if err != nil {
+			return value{}, errors.Wrapf(err, "metric \"%s\"", n.ref.Name)
+		}
+		return value{values: values, vector: true}, nil
+	case *callNode:
+		return evaluateCall(ctx, n, resolver)
+	case *unaryNode:
+		operand, err := evaluateScalar(ctx, n.operand, resolver)
+		if err != nil {
+			return value{}, err
+		}
+		if n.op == "not" {
+			return scalar(boolToFloat(operand == 0)), nil
+		}
+		return scalar(-operand), nil
+	case *binaryNode:
+		return evaluateBinary(ctx, n, resolver)
+	}
+	return value{}, errors.Errorf("unknown node %T", n)
+}
+
+func evaluateScalar(ctx context.Context, n node, resolver Resolver) (float64, error) {
+	v, err := evaluate(ctx, n, resolver)
+	if err != nil {
+		return 0, err
+	}
+	return v.scalar()
+}
+
+func evaluateBinary(ctx context.Context, n *binaryNode, resolver Resolver) (value, error) {
+	left, err := evaluateScalar(ctx, n.left, resolver)
+	if err != nil {
+		return value{}, err
+	}
+
+	// Logical operators short-circuit.
+	switch n.op {
+	case "and":
+		if left == 0 {
+			return scalar(0), nil
+		}
+	case "or":
+		if left != 0 {
+			return scalar(1), nil
+		}
+	}
+
+	right, err := evaluateScalar(ctx, n.right, resolver)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch n.op {
+	case "and", "or":
+		return scalar(boolToFloat(right != 0)), nil
+	case "+":
+		return scalar(left + right), nil
+	case "-":
+		return scalar(left - right), nil
+	case "*":
+		return scalar(left * right), nil
+	case "/":
+		if right == 0 {
+			return value{}, errors.New("division by zero")
+		}
+		return scalar(left / right), nil
+	case ">":
+		return scalar(boolToFloat(left > right)), nil
+	case "<":
+		return scalar(boolToFloat(left < right)), nil
+	case ">=":
+		return scalar(boolToFloat(left >= right)), nil
+	case "<=":
+		return scalar(boolToFloat(left <= right)), nil
+	case "==":
+		return scalar(boolToFloat(left == right)), nil
+	case "!=":
+		return scalar(boolToFloat(left != right)), nil
+	}
+	return value{}, errors.Errorf("unknown operator %q", n.op)
+}
+
+func evaluateCall(ctx context.Context, n *callNode, resolver Resolver) (value, error) {
+	// min and max of multiple arguments compare scalars instead of aggregating a vector.
+	if compare, ok := comparisons[n.function]; ok && len(n.args) > 1 {
+		res, err := evaluateScalar(ctx, n.args[0], resolver)
+		if err != nil {
+			return value{}, err
+		}
+		for _, a := range n.args[1:] {
+			arg, err := evaluateScalar(ctx, a, resolver)
+			if err != nil {
+				return value{}, err
+			}
+			res = compare(res, arg)
+		}
+		return scalar(res), nil
+	}
+
+	if aggregate, ok := aggregations[n.function]; ok {
+		if len(n.args) != 1 {
+			return value{}, errors.Errorf("%s expects 1 argument, got %d", n.function, len(n.args))
+		}
+		v, err := evaluate(ctx, n.args[0], resolver)
+		if err != nil {
+			return value{}, err
+		}
+		if len(v.values) == 0 {
+			if n.function == "count" {
+				return scalar(0), nil
+			}
+			return value{}, errors.Errorf("%s: no results", n.function)
+		}
+		return scalar(aggregate(v.values)), nil
+	}
+
+	if f, ok := functions[n.function]; ok {
+		if len(n.args) != 1 {
+			return value{}, errors.Errorf("%s expects 1 argument, got %d", n.function, len(n.args))
+		}
+		arg, err := evaluateScalar(ctx, n.args[0], resolver)
+		if err != nil {
+			return value{}, err
+		}
+		return scalar(f(arg)), nil
+	}
+
+	return value{}, errors.Errorf("unknown function %q", n.function)
+}
+
+var aggregations = map[string]func([]float64) float64{
+	"avg": func(values []float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	},
+	"sum": func(values []float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	},
+	"min": func(values []float64) float64 {
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min
+	},
+	"max": func(values []float64) float64 {
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max
+	},
+	"count": func(values []float64) float64 {
+		return float64(len(values))
+	},
+}
+
+var comparisons = map[string]func(float64, float64) float64{
+	"min": math.Min,
+	"max": math.Max,
+}
+
+var functions = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+}
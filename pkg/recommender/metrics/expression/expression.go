@@ -0,0 +1,127 @@
+// Package expression implements a small expression language used by the metrics providers which are not backed
+// by a query language of their own.
+//
+// An expression consists of numbers, metric references, function calls and operators, e.g.
+//
+//	avg(scylla_reactor_utilization{scylla_rack="us-east-1a"}) > 70 and max(pending_compactions) > 10
+//
+// A metric reference is a metric name optionally followed by a label selector. What a reference resolves to
+// is up to the provider. A reference resolves to a vector of values, which has to be aggregated with one of
+// avg, sum, min, max or count, unless it consists of a single value. Given multiple arguments, min and max
+// return the smallest and the largest of them, e.g. max(current, 3).
+// Comparison and logical operators evaluate to 1 if true and to 0 otherwise.
+package expression
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Matcher is a single label matcher of a metric reference's selector.
+type Matcher struct {
+	Label   string
+	Value   string
+	Negated bool
+}
+
+// Selector is a list of label matchers.
+type Selector []Matcher
+
+// String returns the selector in the Kubernetes label selector format.
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, m := range s {
+		op := "="
+		if m.Negated {
+			op = "!="
+		}
+		parts = append(parts, m.Label+op+m.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Matches checks whether the given set of labels satisfies the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, m := range s {
+		value, ok := labels[m.Label]
+		if m.Negated == (ok && value == m.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reference is a reference to a metric in an expression.
+type Reference struct {
+	Name     string
+	Selector Selector
+}
+
+// Resolver resolves metric references to vectors of values.
+type Resolver interface {
+	Resolve(ctx context.Context, ref Reference) ([]float64, error)
+}
+
+// ResolverFunc is an adapter allowing for the use of ordinary functions as Resolvers.
+type ResolverFunc func(ctx context.Context, ref Reference) ([]float64, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref Reference) ([]float64, error) {
+	return f(ctx, ref)
+}
+
+// Expression is a parsed expression.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Parse parses an expression.
+func Parse(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errors.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// References returns the metric references used in the expression, in order of appearance.
+func (e *Expression) References() []Reference {
+	var refs []Reference
+	walk(e.root, func(n node) {
+		if ref, ok := n.(*referenceNode); ok {
+			refs = append(refs, ref.ref)
+		}
+	})
+	return refs
+}
+
+// Evaluate evaluates the expression resolving its metric references with the given resolver.
+func (e *Expression) Evaluate(ctx context.Context, resolver Resolver) (float64, error) {
+	v, err := evaluate(ctx, e.root, resolver)
+	if err != nil {
+		return 0, err
+	}
+	return v.scalar()
+}
+
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,142 @@
+package expression
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	resolver := ResolverFunc(func(_ context.Context, ref Reference) ([]float64, error) {
+		switch ref.Name {
+		case "utilization":
+			if ref.Selector.String() == `rack=a` {
+				return []float64{10}, nil
+			}
+			return []float64{50, 70, 90}, nil
+		case "single":
+			return []float64{42}, nil
+		case "empty":
+			return []float64{}, nil
+		}
+		return nil, errors.New("unknown metric")
+	})
+
+	tests := []struct {
+		name          string
+		expression    string
+		expected      float64
+		errorExpected bool
+	}{
+		{name: "number", expression: "42", expected: 42},
+		{name: "arithmetic precedence", expression: "1 + 2 * 3 - 4 / 2", expected: 5},
+		{name: "parentheses", expression: "(1 + 2) * 3", expected: 9},
+		{name: "unary minus", expression: "-2 * -3", expected: 6},
+		{name: "aggregation", expression: "avg(utilization)", expected: 70},
+		{name: "sum", expression: "sum(utilization)", expected: 210},
+		{name: "min", expression: "min(utilization)", expected: 50},
+		{name: "max", expression: "max(utilization)", expected: 90},
+		{name: "count", expression: "count(utilization)", expected: 3},
+		{name: "count of empty vector", expression: "count(empty)", expected: 0},
+		{name: "selector", expression: `avg(utilization{rack="a"})`, expected: 10},
+		{name: "single value reference", expression: "single / 2", expected: 21},
+		{name: "comparison true", expression: "avg(utilization) > 60", expected: 1},
+		{name: "comparison false", expression: "avg(utilization) <= 60", expected: 0},
+		{name: "equality", expression: "single == 42", expected: 1},
+		{name: "and", expression: "single > 40 and max(utilization) > 80", expected: 1},
+		{name: "&&", expression: "single > 40 && max(utilization) > 95", expected: 0},
+		{name: "or", expression: "single > 50 or max(utilization) > 80", expected: 1},
+		{name: "||", expression: "single > 50 || max(utilization) > 95", expected: 0},
+		{name: "not", expression: "not single > 50", expected: 1},
+		{name: "!", expression: "!(single > 40)", expected: 0},
+		{name: "and short-circuits", expression: "single > 50 and unknown > 1", expected: 0},
+		{name: "or short-circuits", expression: "single > 40 or unknown > 1", expected: 1},
+		{name: "functions", expression: "ceil(1.2) + floor(1.8) + abs(-1)", expected: 4},
+		{name: "max of arguments", expression: "max(single, avg(utilization), 3)", expected: 70},
+		{name: "min of arguments", expression: "min(single, ceil(single / 4))", expected: 11},
+		{name: "vector argument of max", expression: "max(single, utilization)", errorExpected: true},
+		{name: "vector in scalar context", expression: "utilization > 1", errorExpected: true},
+		{name: "empty vector", expression: "empty > 1", errorExpected: true},
+		{name: "aggregation of empty vector", expression: "avg(empty)", errorExpected: true},
+		{name: "unknown metric", expression: "unknown > 1", errorExpected: true},
+		{name: "unknown function", expression: "median(utilization)", errorExpected: true},
+		{name: "wrong number of arguments", expression: "avg(utilization, single)", errorExpected: true},
+		{name: "division by zero", expression: "single / 0", errorExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, err := Parse(test.expression)
+			require.NoError(t, err)
+
+			res, err := e.Evaluate(ctx, resolver)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name               string
+		expression         string
+		expectedReferences []Reference
+		errorExpected      bool
+	}{
+		{
+			name:       "references",
+			expression: `avg(load{dc="us-east-1", rack!="b"}) > 10 and pending_compactions{} > 2`,
+			expectedReferences: []Reference{
+				{Name: "load", Selector: Selector{{Label: "dc", Value: "us-east-1"}, {Label: "rack", Value: "b", Negated: true}}},
+				{Name: "pending_compactions"},
+			},
+		},
+		{
+			name:               "escaped quotes",
+			expression:         `load{name='a\'b'}`,
+			expectedReferences: []Reference{{Name: "load", Selector: Selector{{Label: "name", Value: "a'b"}}}},
+		},
+		{
+			name:               "kubernetes label names",
+			expression:         `avg(utilization{app.kubernetes.io/name="scylla", scylla/rack-name="a"}) - 1`,
+			expectedReferences: []Reference{{Name: "utilization", Selector: Selector{{Label: "app.kubernetes.io/name", Value: "scylla"}, {Label: "scylla/rack-name", Value: "a"}}}},
+		},
+		{name: "empty", expression: "", errorExpected: true},
+		{name: "unbalanced parentheses", expression: "(1 + 2", errorExpected: true},
+		{name: "trailing operator", expression: "1 +", errorExpected: true},
+		{name: "trailing tokens", expression: "1 2", errorExpected: true},
+		{name: "unterminated string", expression: `load{a="b}`, errorExpected: true},
+		{name: "unquoted label value", expression: `load{a=b}`, errorExpected: true},
+		{name: "unexpected character", expression: "load # 2", errorExpected: true},
+		{name: "keyword as operand", expression: "and > 1", errorExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, err := Parse(test.expression)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedReferences, e.References())
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	selector := Selector{{Label: "alertname", Value: "ScyllaOverload"}, {Label: "severity", Value: "info", Negated: true}}
+
+	require.True(t, selector.Matches(map[string]string{"alertname": "ScyllaOverload", "severity": "critical"}))
+	require.True(t, selector.Matches(map[string]string{"alertname": "ScyllaOverload"}))
+	require.False(t, selector.Matches(map[string]string{"alertname": "ScyllaOverload", "severity": "info"}))
+	require.False(t, selector.Matches(map[string]string{"alertname": "Other"}))
+	require.Equal(t, "alertname=ScyllaOverload,severity!=info", selector.String())
+}
@@ -0,0 +1,139 @@
+package expression
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdentifier
+	tokenString
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBrace
+	tokenRightBrace
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+var operators = []string{"&&", "||", "==", "!=", ">=", "<=", "=", ">", "<", "+", "-", "*", "/", "!"}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	// Within selectors, identifiers are label names, which may contain some of the operator characters,
+	// e.g. "app.kubernetes.io/name".
+	inSelector := false
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(input) && (isDigit(input[i]) || input[i] == '.') {
+				i++
+			}
+			if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+				i++
+				if i < len(input) && (input[i] == '+' || input[i] == '-') {
+					i++
+				}
+				for i < len(input) && isDigit(input[i]) {
+					i++
+				}
+			}
+			value, err := parseNumber(input[start:i])
+			if err != nil {
+				return nil, errors.Errorf("invalid number %q at position %d", input[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: input[start:i], value: value, pos: start})
+		case isIdentifierStart(c):
+			start := i
+			for i < len(input) && (isIdentifierPart(rune(input[i])) || inSelector && isLabelNamePart(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, text: input[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(input) && rune(input[i]) != c {
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			if i >= len(input) {
+				return nil, errors.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: start})
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: i})
+			i++
+		case c == '{':
+			tokens = append(tokens, token{kind: tokenLeftBrace, text: "{", pos: i})
+			inSelector = true
+			i++
+		case c == '}':
+			tokens = append(tokens, token{kind: tokenRightBrace, text: "}", pos: i})
+			inSelector = false
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		default:
+			op := matchOperator(input[i:])
+			if op == "" {
+				return nil, errors.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+func matchOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifierStart(c rune) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLabelNamePart(c byte) bool {
+	return c == '.' || c == '/' || c == '-'
+}
+
+func isIdentifierPart(c rune) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
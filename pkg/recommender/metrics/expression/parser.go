@@ -0,0 +1,295 @@
+package expression
+
+import (
+	"github.com/pkg/errors"
+)
+
+type node interface{}
+
+type numberNode struct {
+	value float64
+}
+
+type referenceNode struct {
+	ref Reference
+}
+
+type callNode struct {
+	function string
+	args     []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func walk(n node, f func(node)) {
+	f(n)
+	switch n := n.(type) {
+	case *callNode:
+		for _, arg := range n.args {
+			walk(arg, f)
+		}
+	case *unaryNode:
+		walk(n.operand, f)
+	case *binaryNode:
+		walk(n.left, f)
+		walk(n.right, f)
+	}
+}
+
+// parser is a recursive descent parser of the following grammar, listed from the lowest precedence:
+//
+//	expression     = and { ("or" | "||") and }
+//	and            = not { ("and" | "&&") not }
+//	not            = ("not" | "!") not | comparison
+//	comparison     = additive [ (">" | "<" | ">=" | "<=" | "==" | "!=") additive ]
+//	additive       = multiplicative { ("+" | "-") multiplicative }
+//	multiplicative = unary { ("*" | "/") unary }
+//	unary          = "-" unary | primary
+//	primary        = number | reference | call | "(" expression ")"
+//	reference      = identifier [ "{" [ matcher { "," matcher } ] "}" ]
+//	matcher        = identifier ("=" | "!=") string
+//	call           = identifier "(" [ expression { "," expression } ] ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdentifier {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		if t.kind == tokenEOF {
+			return t, errors.Errorf("expected %s at the end of expression", what)
+		}
+		return t, errors.Errorf("expected %s at position %d, got %q", what, t.pos, t.text)
+	}
+	return t, nil
+}
+
+func (p *parser) parseExpression() (node, error) {
+	return p.parseBinary(p.parseAnd, "or", "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseNot, "and", "&&")
+}
+
+func (p *parser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.isOperator(ops...)
+		if !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: normalizeOperator(op), left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.isOperator("not", "!"); ok {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "not", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.isOperator(">", "<", ">=", "<=", "==", "!=")
+	if !ok || p.peek().kind != tokenOperator {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	return p.parseArithmetic(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	return p.parseArithmetic(p.parseUnary, "*", "/")
+}
+
+func (p *parser) parseArithmetic(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.isOperator(ops...)
+		if !ok || p.peek().kind != tokenOperator {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.isOperator("-"); ok && p.peek().kind == tokenOperator {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return &numberNode{value: t.value}, nil
+	case tokenLeftParen:
+		n, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRightParen, "\")\""); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokenIdentifier:
+		if isKeyword(t.text) {
+			return nil, errors.Errorf("unexpected %q at position %d", t.text, t.pos)
+		}
+		switch p.peek().kind {
+		case tokenLeftParen:
+			return p.parseCall(t)
+		case tokenLeftBrace:
+			return p.parseReference(t)
+		}
+		return &referenceNode{ref: Reference{Name: t.text}}, nil
+	case tokenEOF:
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, errors.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	p.next()
+	call := &callNode{function: name.text}
+	if p.peek().kind == tokenRightParen {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+
+		t := p.next()
+		if t.kind == tokenRightParen {
+			return call, nil
+		}
+		if t.kind != tokenComma {
+			return nil, errors.Errorf("expected \",\" or \")\" at position %d, got %q", t.pos, t.text)
+		}
+	}
+}
+
+func (p *parser) parseReference(name token) (node, error) {
+	p.next()
+	ref := Reference{Name: name.text}
+	if p.peek().kind == tokenRightBrace {
+		p.next()
+		return &referenceNode{ref: ref}, nil
+	}
+	for {
+		label, err := p.expect(tokenIdentifier, "label name")
+		if err != nil {
+			return nil, err
+		}
+		op := p.next()
+		if op.kind != tokenOperator || (op.text != "=" && op.text != "!=") {
+			return nil, errors.Errorf("expected \"=\" or \"!=\" at position %d, got %q", op.pos, op.text)
+		}
+		value, err := p.expect(tokenString, "label value")
+		if err != nil {
+			return nil, err
+		}
+		ref.Selector = append(ref.Selector, Matcher{Label: label.text, Value: value.text, Negated: op.text == "!="})
+
+		t := p.next()
+		if t.kind == tokenRightBrace {
+			return &referenceNode{ref: ref}, nil
+		}
+		if t.kind != tokenComma {
+			return nil, errors.Errorf("expected \",\" or \"}\" at position %d, got %q", t.pos, t.text)
+		}
+	}
+}
+
+func isKeyword(s string) bool {
+	return s == "and" || s == "or" || s == "not"
+}
+
+func normalizeOperator(op string) string {
+	switch op {
+	case "||":
+		return "or"
+	case "&&":
+		return "and"
+	}
+	return op
+}
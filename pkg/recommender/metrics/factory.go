@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Factory creates providers for the metrics sources specified by SCAs.
+type Factory struct {
+	// Client is used for fetching the objects referenced by the metrics sources.
+	Client client.Client
+
+	// RESTClient is a client of the Kubernetes API server used by the providers backed by aggregated APIs.
+	RESTClient rest.Interface
+
+	// AlertReceiver receives the alerts evaluated by Alertmanager metrics sources.
+	// If nil, Alertmanager metrics sources are not supported.
+	AlertReceiver *AlertReceiver
+
+	Logger log.Logger
+
+	// DefaultStep is the default step of ranged queries.
+	DefaultStep time.Duration
+
+	// QueryPolicy is applied to the queries of the created providers, if set.
+	QueryPolicy *QueryPolicy
+}
+
+// New creates a provider for the given metrics source.
+// namespace is the SCA's namespace; it is used for resolving the objects referenced by the source.
+func (f *Factory) New(ctx context.Context, namespace string, source *v1alpha1.MetricsSource) (Provider, error) {
+	p, err := f.newProvider(ctx, namespace, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.QueryPolicy != nil {
+		p = NewResilientProvider(p, *f.QueryPolicy, f.Logger)
+	}
+
+	return p, nil
+}
+
+func (f *Factory) newProvider(ctx context.Context, namespace string, source *v1alpha1.MetricsSource) (Provider, error) {
+	switch source.Type {
+	case v1alpha1.MetricsSourceTypePrometheus, "":
+		promClient, err := NewPrometheusClientForSource(ctx, f.Client, namespace, source)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrometheusProvider(v1.NewAPI(*promClient), f.Logger, f.DefaultStep), nil
+	case v1alpha1.MetricsSourceTypeCustomMetrics, v1alpha1.MetricsSourceTypeExternalMetrics, v1alpha1.MetricsSourceTypeResourceMetrics:
+		if f.RESTClient == nil {
+			return nil, errors.Errorf("%s metrics source not supported", source.Type)
+		}
+		switch source.Type {
+		case v1alpha1.MetricsSourceTypeCustomMetrics:
+			return NewCustomMetricsProvider(f.RESTClient, f.Logger), nil
+		case v1alpha1.MetricsSourceTypeExternalMetrics:
+			return NewExternalMetricsProvider(f.RESTClient, f.Logger), nil
+		default:
+			return NewResourceMetricsProvider(f.RESTClient, f.Logger), nil
+		}
+	case v1alpha1.MetricsSourceTypeScyllaAPI:
+		return NewScyllaAPIProvider(f.Client, f.Logger), nil
+	case v1alpha1.MetricsSourceTypeAlertmanager:
+		if f.AlertReceiver == nil {
+			return nil, errors.Errorf("%s metrics source not supported", source.Type)
+		}
+		return NewAlertmanagerProvider(f.AlertReceiver, f.Logger), nil
+	}
+
+	return nil, errors.Errorf("unknown metrics source type \"%s\"", source.Type)
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// historyRetention is how long the samples of an expression are kept after it was last queried.
+const historyRetention = time.Hour
+
+// history keeps the results of instant queries, so that ranged queries can be answered by providers
+// whose backends only support instant ones.
+// Each ranged query takes a new sample. The query is true if the expression was true in all samples taken within
+// the range and the samples cover the whole range, i.e. the expression has been true for at least the range's duration.
+type history struct {
+	mu      sync.Mutex
+	entries map[string]*historyEntry
+}
+
+type historyEntry struct {
+	samples  []sample
+	duration time.Duration
+}
+
+type sample struct {
+	timestamp time.Time
+	value     bool
+}
+
+func newHistory() *history {
+	return &history{
+		entries: make(map[string]*historyEntry),
+	}
+}
+
+// record saves a sample and reports whether the expression identified by key was true during the given range.
+func (h *history) record(key string, now time.Time, value bool, duration time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.prune(now)
+
+	entry, ok := h.entries[key]
+	if !ok {
+		entry = &historyEntry{}
+		h.entries[key] = entry
+	}
+	entry.duration = duration
+
+	start := now.Add(-duration)
+	samples := append(entry.samples, sample{timestamp: now, value: value})
+
+	// Keep the latest sample preceding the range, as it marks the beginning of the covered period.
+	first := 0
+	for i := range samples {
+		if !samples[i].timestamp.After(start) {
+			first = i
+		}
+	}
+	entry.samples = samples[first:]
+
+	if entry.samples[0].timestamp.After(start) {
+		return false
+	}
+	for _, s := range entry.samples {
+		if !s.value {
+			return false
+		}
+	}
+	return true
+}
+
+// prune drops the samples of expressions which haven't been queried for a while.
+func (h *history) prune(now time.Time) {
+	for key, entry := range h.entries {
+		last := entry.samples[len(entry.samples)-1].timestamp
+		if now.Sub(last) > entry.duration+historyRetention {
+			delete(h.entries, key)
+		}
+	}
+}
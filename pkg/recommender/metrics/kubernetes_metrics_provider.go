@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/expression"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	customMetricsAPIPath   = "/apis/custom.metrics.k8s.io/v1beta1"
+	externalMetricsAPIPath = "/apis/external.metrics.k8s.io/v1beta1"
+)
+
+// kubernetesMetricsProvider evaluates expressions (see package expression) against the Kubernetes custom
+// or external metrics API, e.g. as served by prometheus-adapter or KEDA.
+// Metric references are resolved within the namespace of the target ScyllaCluster. In case of the custom metrics API,
+// a reference resolves to the values of the metric of all pods matching its selector; in case of the external
+// metrics API, to the values of all series of the external metric matching its selector.
+type kubernetesMetricsProvider struct {
+	provider
+	client  rest.Interface
+	apiPath string
+	history *history
+}
+
+// NewCustomMetricsProvider creates a provider backed by the custom.metrics.k8s.io API.
+// client is expected to be a client of the Kubernetes API server, e.g. a discovery REST client.
+func NewCustomMetricsProvider(client rest.Interface, logger log.Logger) Provider {
+	return newKubernetesMetricsProvider(client, customMetricsAPIPath, logger)
+}
+
+// NewExternalMetricsProvider creates a provider backed by the external.metrics.k8s.io API.
+// client is expected to be a client of the Kubernetes API server, e.g. a discovery REST client.
+func NewExternalMetricsProvider(client rest.Interface, logger log.Logger) Provider {
+	return newKubernetesMetricsProvider(client, externalMetricsAPIPath, logger)
+}
+
+func newKubernetesMetricsProvider(client rest.Interface, apiPath string, logger log.Logger) Provider {
+	return &kubernetesMetricsProvider{
+		provider: provider{
+			logger: logger,
+		},
+		client:  client,
+		apiPath: apiPath,
+		history: newHistory(),
+	}
+}
+
+// metricValueList is the common subset of the custom and external metrics APIs' value lists.
+type metricValueList struct {
+	Items []struct {
+		Value resource.Quantity `json:"value"`
+	} `json:"items"`
+}
+
+func (p *kubernetesMetricsProvider) Query(ctx context.Context, expr string) (bool, error) {
+	res, err := p.QueryValue(ctx, expr)
+	if err != nil {
+		return false, err
+	}
+	return res != 0, nil
+}
+
+func (p *kubernetesMetricsProvider) QueryValue(ctx context.Context, expr string) (float64, error) {
+	target, ok := TargetFromContext(ctx)
+	if !ok {
+		return 0, errors.New("query target not specified")
+	}
+
+	e, err := expression.Parse(expr)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse expression")
+	}
+
+	namespace := target.Cluster.Namespace
+	res, err := e.Evaluate(ctx, expression.ResolverFunc(func(ctx context.Context, ref expression.Reference) ([]float64, error) {
+		return p.fetchValues(ctx, namespace, ref)
+	}))
+	if err != nil {
+		return 0, errors.Wrap(err, "query")
+	}
+
+	return res, nil
+}
+
+func (p *kubernetesMetricsProvider) RangedQuery(ctx context.Context, expr string, duration time.Duration, _ *time.Duration) (bool, error) {
+	res, err := p.Query(ctx, expr)
+	if err != nil {
+		return false, err
+	}
+
+	target, _ := TargetFromContext(ctx)
+	return p.history.record(target.key()+"/"+expr, time.Now(), res, duration), nil
+}
+
+func (p *kubernetesMetricsProvider) fetchValues(ctx context.Context, namespace string, ref expression.Reference) ([]float64, error) {
+	var req *rest.Request
+	if p.apiPath == customMetricsAPIPath {
+		req = p.client.Get().AbsPath(p.apiPath, "namespaces", namespace, "pods", "*", ref.Name)
+	} else {
+		req = p.client.Get().AbsPath(p.apiPath, "namespaces", namespace, ref.Name)
+	}
+	if len(ref.Selector) > 0 {
+		req = req.Param("labelSelector", ref.Selector.String())
+	}
+
+	raw, err := req.Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &metricValueList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, errors.Wrap(err, "decode metric values")
+	}
+
+	values := make([]float64, 0, len(list.Items))
+	for _, item := range list.Items {
+		values = append(values, quantityToFloat64(item.Value))
+	}
+
+	return values, nil
+}
+
+func quantityToFloat64(q resource.Quantity) float64 {
+	f, _ := strconv.ParseFloat(q.AsDec().String(), 64)
+	return f
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+func TestKubernetesMetricsProviderQuery(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	// A fake API server serving a custom pod metric and an external metric in the "scylla" namespace.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/apis/custom.metrics.k8s.io/v1beta1/namespaces/scylla/pods/*/scylla_reactor_utilization":
+			if r.URL.Query().Get("labelSelector") == "scylla/rack=us-east-1a" {
+				fmt.Fprint(w, `{"items":[{"value":"60"},{"value":"80"}]}`)
+			} else {
+				fmt.Fprint(w, `{"items":[{"value":"60"},{"value":"80"},{"value":"10"}]}`)
+			}
+		case "/apis/external.metrics.k8s.io/v1beta1/namespaces/scylla/queue_length":
+			fmt.Fprint(w, `{"items":[{"value":"1500m"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+		}
+	}))
+	defer srv.Close()
+
+	restClient := discovery.NewDiscoveryClientForConfigOrDie(&rest.Config{Host: srv.URL}).RESTClient()
+	target := Target{
+		Cluster: &scyllav1.ScyllaCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "simple-cluster", Namespace: "scylla"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		provider       Provider
+		expression     string
+		noTarget       bool
+		expectedResult bool
+		errorExpected  bool
+	}{
+		{
+			name:           "custom metric of selected pods",
+			provider:       NewCustomMetricsProvider(restClient, logger),
+			expression:     `avg(scylla_reactor_utilization{scylla/rack="us-east-1a"}) > 65`,
+			expectedResult: true,
+		},
+		{
+			name:           "custom metric of all pods",
+			provider:       NewCustomMetricsProvider(restClient, logger),
+			expression:     `avg(scylla_reactor_utilization) > 65`,
+			expectedResult: false,
+		},
+		{
+			name:           "external metric",
+			provider:       NewExternalMetricsProvider(restClient, logger),
+			expression:     `queue_length >= 1.5`,
+			expectedResult: true,
+		},
+		{
+			name:          "unknown metric",
+			provider:      NewExternalMetricsProvider(restClient, logger),
+			expression:    `unknown > 1`,
+			errorExpected: true,
+		},
+		{
+			name:          "invalid expression",
+			provider:      NewExternalMetricsProvider(restClient, logger),
+			expression:    `queue_length >`,
+			errorExpected: true,
+		},
+		{
+			name:          "no target",
+			provider:      NewExternalMetricsProvider(restClient, logger),
+			expression:    `queue_length > 1`,
+			noTarget:      true,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queryCtx := ctx
+			if !test.noTarget {
+				queryCtx = WithTarget(ctx, target)
+			}
+
+			res, err := test.provider.Query(queryCtx, test.expression)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expectedResult, res)
+			}
+		})
+	}
+
+	t.Run("ranged query is answered from the history of samples", func(t *testing.T) {
+		p := NewExternalMetricsProvider(restClient, logger)
+
+		res, err := p.RangedQuery(WithTarget(ctx, target), `queue_length > 1`, 0, nil)
+		require.NoError(t, err)
+		require.True(t, res)
+
+		res, err = p.RangedQuery(WithTarget(ctx, target), `queue_length > 1`, time.Hour, nil)
+		require.NoError(t, err)
+		require.False(t, res)
+	})
+}
+
+func TestHistory(t *testing.T) {
+	const key = "expression"
+	now := time.Now()
+	at := func(seconds int) time.Time {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	h := newHistory()
+	require.False(t, h.record(key, at(0), true, 30*time.Second), "range not covered yet")
+	require.False(t, h.record(key, at(20), true, 30*time.Second), "range not covered yet")
+	require.True(t, h.record(key, at(40), true, 30*time.Second))
+	require.False(t, h.record(key, at(60), false, 30*time.Second))
+	require.False(t, h.record(key, at(80), true, 30*time.Second), "false sample within range")
+	require.False(t, h.record(key, at(100), true, 30*time.Second), "false sample precedes range")
+	require.True(t, h.record(key, at(120), true, 30*time.Second))
+
+	h.record("other", at(120+3600+31), true, 30*time.Second)
+	require.NotContains(t, h.entries, key, "stale entries are pruned")
+}
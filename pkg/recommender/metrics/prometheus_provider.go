@@ -8,8 +8,10 @@ import (
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"net/http"
 	"net/url"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"time"
@@ -48,12 +50,47 @@ func NewPrometheusClient(ctx context.Context, c client.Client, selector map[stri
 	}
 
 	svc := svcList.Items[0]
-	addr := (&url.URL{
-		Scheme: "http",
-		Host:   fmt.Sprintf("%s.%s.svc.cluster.local:%d", svc.Name, svc.Namespace, svcPort),
-	}).String()
+	promClient, err := api.NewClient(api.Config{Address: serviceAddress(svc.Name, svc.Namespace, svcPort)})
+	if err != nil {
+		return nil, errors.Wrap(err, "create prometheus client")
+	}
+
+	return &promClient, nil
+}
+
+// NewPrometheusClientForSource creates a client of the Prometheus server described by the metrics source of an SCA.
+// namespace is the SCA's namespace; it is used for resolving the credentials and the service reference.
+func NewPrometheusClientForSource(ctx context.Context, c client.Client, namespace string, source *v1alpha1.MetricsSource) (*api.Client, error) {
+	var addr string
+	if source.URL != "" {
+		addr = source.URL
+	} else if source.ServiceRef != nil {
+		// Services of other namespaces are rejected by the Admission Controller, and ignored if it's bypassed.
+		if source.ServiceRef.Namespace != "" && source.ServiceRef.Namespace != namespace {
+			return nil, errors.Errorf("service \"%s\" isn't in the SCA's namespace", source.ServiceRef.Name)
+		}
+		port := int32(svcPort)
+		if source.ServiceRef.Port != 0 {
+			port = source.ServiceRef.Port
+		}
+		addr = serviceAddress(source.ServiceRef.Name, namespace, port)
+	} else {
+		return nil, errors.New("neither url nor service reference specified")
+	}
 
-	promClient, err := api.NewClient(api.Config{Address: addr})
+	rt := api.DefaultRoundTripper
+	if source.CredentialsSecretRef != nil {
+		secret, err := FetchCredentialsSecret(ctx, c, namespace, source.CredentialsSecretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		rt, err = newAuthRoundTripper(secret, rt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret \"%s\"", secret.Name)
+		}
+	}
+
+	promClient, err := api.NewClient(api.Config{Address: addr, RoundTripper: rt})
 	if err != nil {
 		return nil, errors.Wrap(err, "create prometheus client")
 	}
@@ -61,6 +98,53 @@ func NewPrometheusClient(ctx context.Context, c client.Client, selector map[stri
 	return &promClient, nil
 }
 
+// FetchCredentialsSecret fetches the Secret referenced by a metrics source.
+func FetchCredentialsSecret(ctx context.Context, c client.Client, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: namespace,
+		Name:      name,
+	}, secret); err != nil {
+		return nil, errors.Wrap(err, "fetch credentials secret")
+	}
+
+	return secret, nil
+}
+
+func serviceAddress(name, namespace string, port int32) string {
+	return (&url.URL{
+		Scheme: "http",
+		Host:   fmt.Sprintf("%s.%s.svc.cluster.local:%d", name, namespace, port),
+	}).String()
+}
+
+type authRoundTripper struct {
+	authorization string
+	rt            http.RoundTripper
+}
+
+func newAuthRoundTripper(secret *corev1.Secret, rt http.RoundTripper) (http.RoundTripper, error) {
+	if token, ok := secret.Data["token"]; ok {
+		return &authRoundTripper{authorization: "Bearer " + string(token), rt: rt}, nil
+	}
+
+	username, usernameOk := secret.Data["username"]
+	password, passwordOk := secret.Data["password"]
+	if !usernameOk || !passwordOk {
+		return nil, errors.New("neither token nor username and password found")
+	}
+
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(string(username), string(password))
+	return &authRoundTripper{authorization: req.Header.Get("Authorization"), rt: rt}, nil
+}
+
+func (a *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", a.authorization)
+	return a.rt.RoundTrip(req)
+}
+
 func (p *prometheusProvider) Query(ctx context.Context, expression string) (bool, error) {
 	result, warnings, err := p.api.Query(ctx, expression, time.Now())
 
@@ -84,6 +168,34 @@ func (p *prometheusProvider) Query(ctx context.Context, expression string) (bool
 	return resultVector[0].Value != 0, nil //TODO check all results instead of just the first one???
 }
 
+func (p *prometheusProvider) QueryValue(ctx context.Context, expression string) (float64, error) {
+	result, warnings, err := p.api.Query(ctx, expression, time.Now())
+
+	if err != nil {
+		return 0, errors.Wrap(err, "query")
+	}
+
+	if len(warnings) > 0 {
+		p.logger.Error(ctx, "query", "warnings", warnings)
+	}
+
+	switch result.Type() {
+	case model.ValScalar:
+		return float64(result.(*model.Scalar).Value), nil
+	case model.ValVector:
+		resultVector := result.(model.Vector)
+		switch resultVector.Len() {
+		case 0:
+			return 0, errors.New("no results")
+		case 1:
+			return float64(resultVector[0].Value), nil
+		}
+		return 0, errors.Errorf("expected a single value, got %d; use an aggregation", resultVector.Len())
+	}
+
+	return 0, errors.New("unhandled ValueType returned")
+}
+
 func (p *prometheusProvider) RangedQuery(ctx context.Context, expression string, duration time.Duration, argStep *time.Duration) (bool, error) {
 	now := time.Now()
 	step := p.defaultStep
@@ -21,6 +21,12 @@ type Provider interface {
 	// Return value: unless an error has occurred, return a boolean value corresponding to the evaluated expression,
 	// i.e. whether the expression was true in each data point in the given time range.
 	RangedQuery(ctx context.Context, expression string, duration time.Duration, argStep *time.Duration) (bool, error)
+
+	// Perform an instant query to the metrics provider.
+	// ctx - context.Context
+	// expression - an expression to be queried
+	// Return value: unless an error has occurred, return the numeric value of the evaluated expression.
+	QueryValue(ctx context.Context, expression string) (float64, error)
 }
 
 type provider struct {
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// targetIndependent is implemented by providers whose query results don't depend on the query target,
+// i.e. which are not scoped by Target.
+type targetIndependent interface {
+	targetIndependent() bool
+}
+
+func isTargetIndependent(p Provider) bool {
+	ti, ok := p.(targetIndependent)
+	return ok && ti.targetIndependent()
+}
+
+func (p *prometheusProvider) targetIndependent() bool {
+	return true
+}
+
+func (p *resilientProvider) targetIndependent() bool {
+	return isTargetIndependent(p.Provider)
+}
+
+type queryKey struct {
+	provider   Provider
+	target     string
+	expression string
+	ranged     bool
+	value      bool
+	duration   time.Duration
+	step       time.Duration
+}
+
+type queryResult struct {
+	done chan struct{}
+	res  float64
+	err  error
+}
+
+// QueryCache deduplicates identical queries, i.e. queries with the same expression, range and step, performed
+// against the same provider and, unless the provider's results don't depend on it, for the same target.
+// Results, including errors, are cached for the lifetime of the cache, so it's meant to be used within
+// a single evaluation cycle. Concurrent identical queries wait for the result of the first one.
+type QueryCache struct {
+	mu      sync.Mutex
+	results map[queryKey]*queryResult
+}
+
+func NewQueryCache() *QueryCache {
+	return &QueryCache{
+		results: make(map[queryKey]*queryResult),
+	}
+}
+
+// Wrap returns a provider whose queries are deduplicated by the cache.
+func (c *QueryCache) Wrap(p Provider) Provider {
+	return &cachingProvider{
+		Provider: p,
+		cache:    c,
+	}
+}
+
+func (c *QueryCache) do(ctx context.Context, key queryKey, query func() (float64, error)) (float64, error) {
+	if !isTargetIndependent(key.provider) {
+		if target, ok := TargetFromContext(ctx); ok {
+			key.target = target.key()
+		}
+	}
+
+	c.mu.Lock()
+	result, ok := c.results[key]
+	if !ok {
+		result = &queryResult{done: make(chan struct{})}
+		c.results[key] = result
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		result.res, result.err = query()
+		close(result.done)
+		return result.res, result.err
+	}
+
+	select {
+	case <-result.done:
+		return result.res, result.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+type cachingProvider struct {
+	Provider
+	cache *QueryCache
+}
+
+func (p *cachingProvider) Query(ctx context.Context, expression string) (bool, error) {
+	key := queryKey{provider: p.Provider, expression: expression}
+	res, err := p.cache.do(ctx, key, func() (float64, error) {
+		res, err := p.Provider.Query(ctx, expression)
+		return boolToFloat(res), err
+	})
+	return res != 0, err
+}
+
+func (p *cachingProvider) RangedQuery(ctx context.Context, expression string, duration time.Duration, argStep *time.Duration) (bool, error) {
+	key := queryKey{provider: p.Provider, expression: expression, ranged: true, duration: duration, step: -1}
+	if argStep != nil {
+		key.step = *argStep
+	}
+	res, err := p.cache.do(ctx, key, func() (float64, error) {
+		res, err := p.Provider.RangedQuery(ctx, expression, duration, argStep)
+		return boolToFloat(res), err
+	})
+	return res != 0, err
+}
+
+func (p *cachingProvider) QueryValue(ctx context.Context, expression string) (float64, error) {
+	key := queryKey{provider: p.Provider, expression: expression, value: true}
+	return p.cache.do(ctx, key, func() (float64, error) {
+		return p.Provider.QueryValue(ctx, expression)
+	})
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
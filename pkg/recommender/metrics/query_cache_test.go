@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// countingProvider counts the queries it performs.
+type countingProvider struct {
+	calls int32
+}
+
+func (p *countingProvider) Query(context.Context, string) (bool, error) {
+	atomic.AddInt32(&p.calls, 1)
+	time.Sleep(time.Millisecond)
+	return true, nil
+}
+
+func (p *countingProvider) RangedQuery(ctx context.Context, expression string, _ time.Duration, _ *time.Duration) (bool, error) {
+	return p.Query(ctx, expression)
+}
+
+func (p *countingProvider) QueryValue(ctx context.Context, expression string) (float64, error) {
+	_, err := p.Query(ctx, expression)
+	return 42, err
+}
+
+func TestQueryCache(t *testing.T) {
+	ctx := context.Background()
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	cluster := &scyllav1.ScyllaCluster{ObjectMeta: metav1.ObjectMeta{Name: "simple-cluster", Namespace: "scylla"}}
+	rackA := WithTarget(ctx, Target{Cluster: cluster, Rack: &scyllav1.RackSpec{Name: "a"}})
+	rackB := WithTarget(ctx, Target{Cluster: cluster, Rack: &scyllav1.RackSpec{Name: "b"}})
+	minute := time.Minute
+
+	t.Run("identical queries are performed once", func(t *testing.T) {
+		cp := &countingProvider{}
+		cache := NewQueryCache()
+		p := cache.Wrap(cp)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := p.Query(rackA, "up")
+				require.NoError(t, err)
+				require.True(t, res)
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int32(1), cp.calls)
+
+		_, _ = p.Query(rackB, "up")
+		require.Equal(t, int32(2), cp.calls, "results of target scoped providers are cached per target")
+	})
+
+	t.Run("queries differing in range or step are performed separately", func(t *testing.T) {
+		cp := &countingProvider{}
+		p := NewQueryCache().Wrap(cp)
+
+		_, _ = p.Query(ctx, "up")
+		_, _ = p.RangedQuery(ctx, "up", time.Hour, nil)
+		_, _ = p.RangedQuery(ctx, "up", time.Hour, &minute)
+		_, _ = p.RangedQuery(ctx, "up", 2*time.Hour, &minute)
+		_, _ = p.RangedQuery(ctx, "up", 2*time.Hour, &minute)
+		require.Equal(t, int32(4), cp.calls)
+	})
+
+	t.Run("target independent providers share results across targets", func(t *testing.T) {
+		pp := NewPrometheusProvider(mock.NewMockApi(mock.SimpleQueryFunction(), nil), logger, time.Minute)
+		p := NewQueryCache().Wrap(NewResilientProvider(pp, QueryPolicy{}, logger))
+
+		res, err := p.Query(rackA, mock.QueryWillReturnTrue)
+		require.NoError(t, err)
+		require.True(t, res)
+		_, _ = p.Query(rackB, mock.QueryWillReturnTrue)
+		require.Len(t, p.(*cachingProvider).cache.results, 1)
+	})
+
+	t.Run("alerts are cached per target", func(t *testing.T) {
+		receiver, err := NewAlertReceiver(logger, "token")
+		require.NoError(t, err)
+		p := NewQueryCache().Wrap(NewResilientProvider(NewAlertmanagerProvider(receiver, logger), QueryPolicy{}, logger))
+
+		res, err := p.Query(rackA, "ScyllaOverload")
+		require.NoError(t, err)
+		require.False(t, res)
+		_, _ = p.Query(rackB, "ScyllaOverload")
+		require.Len(t, p.(*cachingProvider).cache.results, 2)
+	})
+}
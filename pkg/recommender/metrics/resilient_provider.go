@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/scylladb/go-log"
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrSourceUnhealthy is returned instead of performing queries while the metrics source is considered unhealthy.
+var ErrSourceUnhealthy = errors.New("metrics source unhealthy")
+
+// QueryPolicy determines how the queries to a metrics source are performed.
+type QueryPolicy struct {
+	// Timeout of a single query attempt. No timeout if 0.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a query failing with a transient error is retried.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry. It's doubled after every retry, up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// BreakerThreshold is the number of consecutive failed queries after which the metrics source is considered
+	// unhealthy. The circuit breaker is disabled if 0.
+	BreakerThreshold int
+
+	// BreakerCooldown is the time for which queries are suspended after the metrics source is considered unhealthy.
+	BreakerCooldown time.Duration
+
+	// Limiter limits the rate of queries. It's meant to be shared between all metrics sources. No limit if nil.
+	Limiter *rate.Limiter
+}
+
+// HealthChecker is implemented by providers which keep track of their metrics source's health.
+type HealthChecker interface {
+	// Healthy checks whether queries to the metrics source are currently allowed.
+	Healthy() bool
+}
+
+// resilientProvider wraps a provider, applying a QueryPolicy to its queries.
+// Only transient errors, e.g. network errors, timeouts or server errors, are retried and count towards
+// the circuit breaker's threshold, so that invalid expressions don't make the metrics source unhealthy.
+type resilientProvider struct {
+	Provider
+	policy QueryPolicy
+	logger log.Logger
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewResilientProvider(p Provider, policy QueryPolicy, logger log.Logger) Provider {
+	return &resilientProvider{
+		Provider: p,
+		policy:   policy,
+		logger:   logger,
+	}
+}
+
+func (p *resilientProvider) Query(ctx context.Context, expression string) (bool, error) {
+	var res bool
+	err := p.do(ctx, func(ctx context.Context) (err error) {
+		res, err = p.Provider.Query(ctx, expression)
+		return err
+	})
+	return res, err
+}
+
+func (p *resilientProvider) RangedQuery(ctx context.Context, expression string, duration time.Duration, argStep *time.Duration) (bool, error) {
+	var res bool
+	err := p.do(ctx, func(ctx context.Context) (err error) {
+		res, err = p.Provider.RangedQuery(ctx, expression, duration, argStep)
+		return err
+	})
+	return res, err
+}
+
+func (p *resilientProvider) QueryValue(ctx context.Context, expression string) (float64, error) {
+	var res float64
+	err := p.do(ctx, func(ctx context.Context) (err error) {
+		res, err = p.Provider.QueryValue(ctx, expression)
+		return err
+	})
+	return res, err
+}
+
+func (p *resilientProvider) Healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return !time.Now().Before(p.openUntil)
+}
+
+func (p *resilientProvider) do(ctx context.Context, query func(ctx context.Context) error) error {
+	if !p.Healthy() {
+		return ErrSourceUnhealthy
+	}
+
+	backoff := p.policy.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.attempt(ctx, query)
+		if err == nil {
+			p.recordResult(ctx, true)
+			return nil
+		}
+		if ctx.Err() != nil || !isTransient(err) {
+			return err
+		}
+		if attempt >= p.policy.MaxRetries {
+			err = errors.Wrapf(err, "%d attempts failed", attempt+1)
+			if opened := p.recordResult(ctx, false); opened {
+				return errors.Wrap(ErrSourceUnhealthy, err.Error())
+			}
+			return err
+		}
+
+		p.logger.Debug(ctx, "retrying query", "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if p.policy.MaxRetryBackoff > 0 && backoff > p.policy.MaxRetryBackoff {
+			backoff = p.policy.MaxRetryBackoff
+		}
+	}
+}
+
+func (p *resilientProvider) attempt(ctx context.Context, query func(ctx context.Context) error) error {
+	if p.policy.Limiter != nil {
+		if err := p.policy.Limiter.Wait(ctx); err != nil {
+			return errors.Wrap(err, "wait for rate limiter")
+		}
+	}
+
+	if p.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.policy.Timeout)
+		defer cancel()
+	}
+
+	return query(ctx)
+}
+
+// recordResult records the result of a query, returning true if it opened the circuit breaker.
+func (p *resilientProvider) recordResult(ctx context.Context, success bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if success {
+		p.failures = 0
+		return false
+	}
+
+	p.failures++
+	if p.policy.BreakerThreshold == 0 || p.failures < p.policy.BreakerThreshold {
+		return false
+	}
+
+	p.logger.Error(ctx, "metrics source unhealthy, suspending queries", "failures", p.failures,
+		"cooldown", p.policy.BreakerCooldown)
+	p.openUntil = time.Now().Add(p.policy.BreakerCooldown)
+	// Once the cooldown passes, a single failure opens the breaker again.
+	p.failures = p.policy.BreakerThreshold - 1
+	return true
+}
+
+// transientError marks errors which are worth retrying.
+type transientError struct {
+	error
+}
+
+func (e transientError) Unwrap() error {
+	return e.error
+}
+
+func markTransient(err error) error {
+	return transientError{err}
+}
+
+func isTransient(err error) bool {
+	var te transientError
+	if errors.As(err, &te) {
+		return true
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var promErr *v1.Error
+	if errors.As(err, &promErr) {
+		switch promErr.Type {
+		case v1.ErrTimeout, v1.ErrServer:
+			return true
+		case v1.ErrClient:
+			return strings.HasSuffix(promErr.Msg, "429")
+		}
+		return false
+	}
+
+	return apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err)
+}
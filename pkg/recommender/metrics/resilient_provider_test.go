@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/scylladb/go-log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+)
+
+// scriptedProvider returns the scripted errors in order, and succeeds once they run out.
+type scriptedProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *scriptedProvider) Query(ctx context.Context, _ string) (bool, error) {
+	p.calls++
+	if len(p.errs) == 0 {
+		return true, nil
+	}
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+	if err == context.DeadlineExceeded {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return false, err
+}
+
+func (p *scriptedProvider) RangedQuery(ctx context.Context, expression string, _ time.Duration, _ *time.Duration) (bool, error) {
+	return p.Query(ctx, expression)
+}
+
+func (p *scriptedProvider) QueryValue(ctx context.Context, expression string) (float64, error) {
+	_, err := p.Query(ctx, expression)
+	return 42, err
+}
+
+func TestResilientProvider(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	transient := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	permanent := &v1.Error{Type: v1.ErrBadData, Msg: "parse error"}
+	policy := QueryPolicy{
+		Timeout:          50 * time.Millisecond,
+		MaxRetries:       2,
+		RetryBackoff:     time.Millisecond,
+		MaxRetryBackoff:  2 * time.Millisecond,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+	}
+
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		errorExpected bool
+	}{
+		{
+			name:          "transient errors are retried",
+			errs:          []error{transient, &v1.Error{Type: v1.ErrServer, Msg: "server error: 503"}},
+			expectedCalls: 3,
+		},
+		{
+			name:          "timed out attempts are retried",
+			errs:          []error{context.DeadlineExceeded},
+			expectedCalls: 2,
+		},
+		{
+			name:          "permanent errors are not retried",
+			errs:          []error{errors.Wrap(permanent, "query")},
+			expectedCalls: 1,
+			errorExpected: true,
+		},
+		{
+			name:          "retries are bounded",
+			errs:          []error{transient, transient, transient, transient},
+			expectedCalls: 3,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sp := &scriptedProvider{errs: test.errs}
+			p := NewResilientProvider(sp, policy, logger)
+
+			res, err := p.Query(ctx, "up")
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.True(t, res)
+			}
+			require.Equal(t, test.expectedCalls, sp.calls)
+		})
+	}
+
+	t.Run("circuit breaker suspends queries after consecutive failures", func(t *testing.T) {
+		sp := &scriptedProvider{errs: []error{transient, transient, transient, transient, transient, transient}}
+		p := NewResilientProvider(sp, policy, logger)
+
+		_, err := p.Query(ctx, "up")
+		require.Error(t, err)
+		require.True(t, p.(HealthChecker).Healthy(), "a single failed query doesn't open the breaker")
+
+		_, err = p.RangedQuery(ctx, "up", time.Minute, nil)
+		require.True(t, errors.Is(err, ErrSourceUnhealthy), "the query opening the breaker reports the source unhealthy")
+		require.False(t, p.(HealthChecker).Healthy())
+
+		calls := sp.calls
+		_, err = p.Query(ctx, "up")
+		require.True(t, errors.Is(err, ErrSourceUnhealthy))
+		require.Equal(t, calls, sp.calls, "no queries are performed while the breaker is open")
+	})
+
+	t.Run("queries are resumed after cooldown", func(t *testing.T) {
+		cooldownPolicy := policy
+		cooldownPolicy.BreakerThreshold = 1
+		cooldownPolicy.BreakerCooldown = 10 * time.Millisecond
+		sp := &scriptedProvider{errs: []error{transient, transient, transient}}
+		p := NewResilientProvider(sp, cooldownPolicy, logger)
+
+		_, err := p.Query(ctx, "up")
+		require.Error(t, err)
+		require.False(t, p.(HealthChecker).Healthy())
+
+		time.Sleep(cooldownPolicy.BreakerCooldown)
+		require.True(t, p.(HealthChecker).Healthy())
+		res, err := p.Query(ctx, "up")
+		require.NoError(t, err)
+		require.True(t, res)
+	})
+
+	t.Run("queries are rate limited", func(t *testing.T) {
+		limitedPolicy := policy
+		limitedPolicy.Limiter = rate.NewLimiter(rate.Every(20*time.Millisecond), 1)
+		p := NewResilientProvider(&scriptedProvider{}, limitedPolicy, logger)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := p.Query(ctx, "up")
+			require.NoError(t, err)
+		}
+		require.True(t, time.Since(start) >= 40*time.Millisecond)
+	})
+}
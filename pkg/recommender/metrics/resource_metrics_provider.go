@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/expression"
+	"github.com/scylladb/scylla-operator/pkg/naming"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+)
+
+const resourceMetricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+// Metrics exposed to the expressions by the resource metrics provider.
+// Each of them resolves to a vector with a single value per pod of the target rack.
+const (
+	// CPUUtilizationMetric is the CPU usage of the Scylla container, as a percentage of the rack's CPU requests.
+	CPUUtilizationMetric = "cpu_utilization"
+
+	// MemoryUtilizationMetric is the memory usage of the Scylla container, as a percentage of the rack's memory requests.
+	MemoryUtilizationMetric = "memory_utilization"
+
+	// CPUUsageMetric is the CPU usage of the Scylla container in cores.
+	CPUUsageMetric = "cpu_usage"
+
+	// MemoryUsageMetric is the memory usage of the Scylla container in bytes.
+	MemoryUsageMetric = "memory_usage"
+)
+
+// resourceMetricsProvider evaluates expressions (see package expression) against the resource usage of the target
+// rack's pods, as served by the metrics.k8s.io API (e.g. by metrics-server).
+// Selectors of the metric references narrow down the rack's pods further.
+type resourceMetricsProvider struct {
+	provider
+	client  rest.Interface
+	history *history
+}
+
+// NewResourceMetricsProvider creates a provider backed by the metrics.k8s.io API.
+// client is expected to be a client of the Kubernetes API server, e.g. a discovery REST client.
+func NewResourceMetricsProvider(client rest.Interface, logger log.Logger) Provider {
+	return &resourceMetricsProvider{
+		provider: provider{
+			logger: logger,
+		},
+		client:  client,
+		history: newHistory(),
+	}
+}
+
+// podMetricsList is the subset of the metrics.k8s.io PodMetricsList used by the provider.
+type podMetricsList struct {
+	Items []struct {
+		Containers []struct {
+			Name  string              `json:"name"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+func (p *resourceMetricsProvider) Query(ctx context.Context, expr string) (bool, error) {
+	res, err := p.QueryValue(ctx, expr)
+	if err != nil {
+		return false, err
+	}
+	return res != 0, nil
+}
+
+func (p *resourceMetricsProvider) QueryValue(ctx context.Context, expr string) (float64, error) {
+	target, ok := TargetFromContext(ctx)
+	if !ok || target.Rack == nil {
+		return 0, errors.New("query target rack not specified")
+	}
+
+	e, err := expression.Parse(expr)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse expression")
+	}
+
+	res, err := e.Evaluate(ctx, expression.ResolverFunc(func(ctx context.Context, ref expression.Reference) ([]float64, error) {
+		return p.resolve(ctx, target, ref)
+	}))
+	if err != nil {
+		return 0, errors.Wrap(err, "query")
+	}
+
+	return res, nil
+}
+
+func (p *resourceMetricsProvider) RangedQuery(ctx context.Context, expr string, duration time.Duration, _ *time.Duration) (bool, error) {
+	res, err := p.Query(ctx, expr)
+	if err != nil {
+		return false, err
+	}
+
+	target, _ := TargetFromContext(ctx)
+	return p.history.record(target.key()+"/"+expr, time.Now(), res, duration), nil
+}
+
+func (p *resourceMetricsProvider) resolve(ctx context.Context, target Target, ref expression.Reference) ([]float64, error) {
+	var (
+		resourceName corev1.ResourceName
+		utilization  bool
+	)
+	switch ref.Name {
+	case CPUUtilizationMetric:
+		resourceName, utilization = corev1.ResourceCPU, true
+	case MemoryUtilizationMetric:
+		resourceName, utilization = corev1.ResourceMemory, true
+	case CPUUsageMetric:
+		resourceName = corev1.ResourceCPU
+	case MemoryUsageMetric:
+		resourceName = corev1.ResourceMemory
+	default:
+		return nil, errors.Errorf("unknown metric \"%s\"", ref.Name)
+	}
+
+	var requested float64
+	if utilization {
+		request, ok := target.Rack.Resources.Requests[resourceName]
+		if !ok {
+			// Scylla Operator defaults the requests to the limits.
+			request, ok = target.Rack.Resources.Limits[resourceName]
+		}
+		if !ok || request.IsZero() {
+			return nil, errors.Errorf("rack \"%s\" doesn't request %s", target.Rack.Name, resourceName)
+		}
+		requested = quantityToFloat64(request)
+	}
+
+	usages, err := p.fetchUsage(ctx, target, ref.Selector, resourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, 0, len(usages))
+	for _, usage := range usages {
+		value := quantityToFloat64(usage)
+		if utilization {
+			value = 100 * value / requested
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// fetchUsage returns the usage of the given resource by the Scylla containers of the target rack's pods
+// matching the selector.
+func (p *resourceMetricsProvider) fetchUsage(ctx context.Context, target Target, selector expression.Selector,
+	resourceName corev1.ResourceName) ([]resource.Quantity, error) {
+	labelSelector := naming.RackSelector(*target.Rack, target.Cluster).String()
+	if len(selector) > 0 {
+		labelSelector += "," + selector.String()
+	}
+	if _, err := labels.Parse(labelSelector); err != nil {
+		return nil, errors.Wrap(err, "parse label selector")
+	}
+
+	raw, err := p.client.Get().
+		AbsPath(resourceMetricsAPIPath, "namespaces", target.Cluster.Namespace, "pods").
+		Param("labelSelector", labelSelector).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &podMetricsList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, errors.Wrap(err, "decode pod metrics")
+	}
+
+	var usages []resource.Quantity
+	for _, item := range list.Items {
+		for _, container := range item.Containers {
+			if container.Name != naming.ScyllaContainerName {
+				continue
+			}
+			if usage, ok := container.Usage[resourceName]; ok {
+				usages = append(usages, usage)
+			}
+		}
+	}
+
+	return usages, nil
+}
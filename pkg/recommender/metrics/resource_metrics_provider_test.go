@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scylladb/go-log"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+func TestResourceMetricsProviderQuery(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	const rackSelector = "app=scylla,app.kubernetes.io/managed-by=scylla-operator,app.kubernetes.io/name=scylla," +
+		"scylla/cluster=simple-cluster,scylla/datacenter=us-east-1,scylla/rack=us-east-1a"
+
+	// A fake API server serving the usage of two pods of the rack.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/apis/metrics.k8s.io/v1beta1/namespaces/scylla/pods" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			return
+		}
+		switch r.URL.Query().Get("labelSelector") {
+		case rackSelector:
+			fmt.Fprint(w, `{"items":[
+				{"containers":[{"name":"scylla","usage":{"cpu":"1500m","memory":"2Gi"}},{"name":"sidecar","usage":{"cpu":"2","memory":"1Gi"}}]},
+				{"containers":[{"name":"scylla","usage":{"cpu":"500m","memory":"1Gi"}}]}
+			]}`)
+		case rackSelector + ",statefulset.kubernetes.io/pod-name=simple-cluster-us-east-1-us-east-1a-0":
+			fmt.Fprint(w, `{"items":[{"containers":[{"name":"scylla","usage":{"cpu":"1500m","memory":"2Gi"}}]}]}`)
+		default:
+			fmt.Fprint(w, `{"items":[]}`)
+		}
+	}))
+	defer srv.Close()
+
+	restClient := discovery.NewDiscoveryClientForConfigOrDie(&rest.Config{Host: srv.URL}).RESTClient()
+	cluster := &scyllav1.ScyllaCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-cluster", Namespace: "scylla"},
+		Spec: scyllav1.ClusterSpec{
+			Datacenter: scyllav1.DatacenterSpec{Name: "us-east-1"},
+		},
+	}
+	rack := &scyllav1.RackSpec{
+		Name: "us-east-1a",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+		},
+	}
+	noResourcesRack := &scyllav1.RackSpec{Name: "us-east-1a"}
+
+	tests := []struct {
+		name           string
+		target         Target
+		expression     string
+		expectedResult bool
+		errorExpected  bool
+	}{
+		{
+			name:           "cpu utilization relative to requests",
+			target:         Target{Cluster: cluster, Rack: rack},
+			expression:     `avg(cpu_utilization) == 50 and max(cpu_utilization) == 75`,
+			expectedResult: true,
+		},
+		{
+			name:           "memory utilization relative to limits when requests are not set",
+			target:         Target{Cluster: cluster, Rack: rack},
+			expression:     `max(memory_utilization) == 50`,
+			expectedResult: true,
+		},
+		{
+			name:           "usage",
+			target:         Target{Cluster: cluster, Rack: rack},
+			expression:     `sum(cpu_usage) == 2 and min(memory_usage) == 1024 * 1024 * 1024`,
+			expectedResult: true,
+		},
+		{
+			name:           "selector narrows down the pods",
+			target:         Target{Cluster: cluster, Rack: rack},
+			expression:     `cpu_utilization{statefulset.kubernetes.io/pod-name="simple-cluster-us-east-1-us-east-1a-0"} > 70`,
+			expectedResult: true,
+		},
+		{
+			name:          "unknown metric",
+			target:        Target{Cluster: cluster, Rack: rack},
+			expression:    `avg(disk_utilization) > 1`,
+			errorExpected: true,
+		},
+		{
+			name:          "resource not requested",
+			target:        Target{Cluster: cluster, Rack: noResourcesRack},
+			expression:    `avg(cpu_utilization) > 1`,
+			errorExpected: true,
+		},
+		{
+			name:          "no target rack",
+			target:        Target{Cluster: cluster},
+			expression:    `avg(cpu_utilization) > 1`,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewResourceMetricsProvider(restClient, logger)
+
+			res, err := p.Query(WithTarget(ctx, test.target), test.expression)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expectedResult, res)
+			}
+		})
+	}
+}
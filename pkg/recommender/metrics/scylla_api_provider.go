@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/expression"
+	"github.com/scylladb/scylla-operator/pkg/naming"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	scyllaAPIPort    = 10000
+	scyllaAPITimeout = 10 * time.Second
+)
+
+// scyllaAPIMetrics maps the metrics exposed to the expressions by the Scylla REST API provider
+// to the Scylla REST API endpoints serving them.
+var scyllaAPIMetrics = map[string]string{
+	// Size of the data stored by the node in bytes.
+	"load": "/storage_service/load",
+	// Disk space used by the node's live SSTables in bytes.
+	"live_disk_space_used": "/column_family/metrics/live_disk_space_used",
+	// Disk space used by all of the node's SSTables in bytes.
+	"total_disk_space_used": "/column_family/metrics/total_disk_space_used",
+	// Number of pending compaction tasks of the node.
+	"pending_compactions": "/compaction_manager/metrics/pending_tasks",
+	// Number of pending memtable flushes of the node.
+	"pending_flushes": "/column_family/metrics/pending_flushes",
+}
+
+// scyllaAPIProvider evaluates expressions (see package expression) against the Scylla REST API of the target
+// rack's nodes. A metric reference resolves to the values reported by each of the rack's pods matching
+// its selector. Pods which haven't been assigned an IP yet are skipped. Scylla only serves the API on the pods' IPs
+// if its api_address is set to them, e.g. to 0.0.0.0, instead of the default 127.0.0.1.
+type scyllaAPIProvider struct {
+	provider
+	client     client.Client
+	httpClient *http.Client
+	port       int
+	history    *history
+}
+
+// NewScyllaAPIProvider creates a provider querying the Scylla REST API of the target rack's pods.
+func NewScyllaAPIProvider(c client.Client, logger log.Logger) Provider {
+	return &scyllaAPIProvider{
+		provider: provider{
+			logger: logger,
+		},
+		client:     c,
+		httpClient: &http.Client{Timeout: scyllaAPITimeout},
+		port:       scyllaAPIPort,
+		history:    newHistory(),
+	}
+}
+
+func (p *scyllaAPIProvider) Query(ctx context.Context, expr string) (bool, error) {
+	res, err := p.QueryValue(ctx, expr)
+	if err != nil {
+		return false, err
+	}
+	return res != 0, nil
+}
+
+func (p *scyllaAPIProvider) QueryValue(ctx context.Context, expr string) (float64, error) {
+	target, ok := TargetFromContext(ctx)
+	if !ok || target.Rack == nil {
+		return 0, errors.New("query target rack not specified")
+	}
+
+	e, err := expression.Parse(expr)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse expression")
+	}
+
+	res, err := e.Evaluate(ctx, expression.ResolverFunc(func(ctx context.Context, ref expression.Reference) ([]float64, error) {
+		return p.resolve(ctx, target, ref)
+	}))
+	if err != nil {
+		return 0, errors.Wrap(err, "query")
+	}
+
+	return res, nil
+}
+
+func (p *scyllaAPIProvider) RangedQuery(ctx context.Context, expr string, duration time.Duration, _ *time.Duration) (bool, error) {
+	res, err := p.Query(ctx, expr)
+	if err != nil {
+		return false, err
+	}
+
+	target, _ := TargetFromContext(ctx)
+	return p.history.record(target.key()+"/"+expr, time.Now(), res, duration), nil
+}
+
+func (p *scyllaAPIProvider) resolve(ctx context.Context, target Target, ref expression.Reference) ([]float64, error) {
+	path, ok := scyllaAPIMetrics[ref.Name]
+	if !ok {
+		return nil, errors.Errorf("unknown metric \"%s\"", ref.Name)
+	}
+
+	selector := naming.RackSelector(*target.Rack, target.Cluster).String()
+	if len(ref.Selector) > 0 {
+		selector += "," + ref.Selector.String()
+	}
+	labelSelector, err := labels.Parse(selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse label selector")
+	}
+
+	podList := &corev1.PodList{}
+	if err := p.client.List(ctx, podList, client.InNamespace(target.Cluster.Namespace),
+		client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return nil, errors.Wrap(err, "list pods")
+	}
+
+	values := make([]float64, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		if pod.Status.PodIP == "" {
+			p.logger.Debug(ctx, "pod has no IP assigned, skipping", "pod", pod.Name)
+			continue
+		}
+
+		value, err := p.fetchValue(ctx, pod.Status.PodIP, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch %s of pod %s", ref.Name, pod.Name)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func (p *scyllaAPIProvider) fetchValue(ctx context.Context, host, path string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s:%d%s", host, p.port, path), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "request Scylla REST API, is its api_address set to the pod's IP?")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errors.Wrap(err, "read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := errors.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return 0, markTransient(err)
+		}
+		return 0, err
+	}
+
+	var value float64
+	if err := json.Unmarshal(body, &value); err != nil {
+		return 0, errors.Wrap(err, "decode response")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/scylladb/go-log"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/scylladb/scylla-operator/pkg/naming"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestScyllaAPIProviderQuery(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	// A fake Scylla REST API; all of the pods share its address.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/storage_service/load":
+			fmt.Fprint(w, "1.5e9")
+		case "/compaction_manager/metrics/pending_tasks":
+			fmt.Fprint(w, "120")
+		case "/column_family/metrics/pending_flushes":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message":"internal error","code":500}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	cluster := &scyllav1.ScyllaCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-cluster", Namespace: "scylla"},
+		Spec: scyllav1.ClusterSpec{
+			Datacenter: scyllav1.DatacenterSpec{Name: "us-east-1"},
+		},
+	}
+	rack := &scyllav1.RackSpec{Name: "us-east-1a"}
+	otherRack := &scyllav1.RackSpec{Name: "us-east-1b"}
+
+	newPod := func(name string, rack *scyllav1.RackSpec, ip string) *corev1.Pod {
+		labels := naming.RackLabels(*rack, cluster)
+		labels["statefulset.kubernetes.io/pod-name"] = name
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: cluster.Namespace,
+				Labels:    labels,
+			},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+	}
+	c := fake.NewClientBuilder().WithObjects(
+		newPod("simple-cluster-us-east-1-us-east-1a-0", rack, host),
+		newPod("simple-cluster-us-east-1-us-east-1a-1", rack, host),
+		newPod("simple-cluster-us-east-1-us-east-1a-2", rack, ""),
+		newPod("simple-cluster-us-east-1-us-east-1b-0", otherRack, "10.0.0.1"),
+	).Build()
+
+	tests := []struct {
+		name           string
+		target         Target
+		expression     string
+		expectedResult bool
+		errorExpected  bool
+	}{
+		{
+			name:           "aggregation across the rack's nodes",
+			target:         Target{Cluster: cluster, Rack: rack},
+			expression:     `sum(load) == 3e9 and count(pending_compactions) == 2`,
+			expectedResult: true,
+		},
+		{
+			name:           "selector narrows down the pods",
+			target:         Target{Cluster: cluster, Rack: rack},
+			expression:     `pending_compactions{statefulset.kubernetes.io/pod-name!="simple-cluster-us-east-1-us-east-1a-1"} > 100`,
+			expectedResult: true,
+		},
+		{
+			name:           "rack without pods",
+			target:         Target{Cluster: cluster, Rack: &scyllav1.RackSpec{Name: "us-east-1c"}},
+			expression:     `count(load) > 0`,
+			expectedResult: false,
+		},
+		{
+			name:          "API error",
+			target:        Target{Cluster: cluster, Rack: rack},
+			expression:    `max(pending_flushes) > 1`,
+			errorExpected: true,
+		},
+		{
+			name:          "unknown metric",
+			target:        Target{Cluster: cluster, Rack: rack},
+			expression:    `max(hints) > 1`,
+			errorExpected: true,
+		},
+		{
+			name:          "no target rack",
+			target:        Target{Cluster: cluster},
+			expression:    `avg(load) > 1`,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewScyllaAPIProvider(c, logger).(*scyllaAPIProvider)
+			p.port, err = strconv.Atoi(port)
+			require.NoError(t, err)
+
+			res, err := p.Query(WithTarget(ctx, test.target), test.expression)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expectedResult, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// SeriesPoint is the value of an expression at a point in time.
+type SeriesPoint struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// SeriesProvider is implemented by providers which can query the history of numeric expressions.
+type SeriesProvider interface {
+	// QuerySeries returns the values of the expression within the range of the given duration ending now,
+	// sampled every step. Points at which the expression had no value are missing.
+	QuerySeries(ctx context.Context, expression string, duration, step time.Duration) ([]SeriesPoint, error)
+}
+
+// ErrSeriesNotSupported is returned when querying the history of an expression from a provider which doesn't support it.
+var ErrSeriesNotSupported = errors.New("metrics source doesn't support querying the history of expressions")
+
+// QuerySeries queries the history of the expression from the provider, if it supports it.
+func QuerySeries(ctx context.Context, p Provider, expression string, duration, step time.Duration) ([]SeriesPoint, error) {
+	sp, ok := p.(SeriesProvider)
+	if !ok {
+		return nil, ErrSeriesNotSupported
+	}
+	return sp.QuerySeries(ctx, expression, duration, step)
+}
+
+func (p *prometheusProvider) QuerySeries(ctx context.Context, expression string, duration, step time.Duration) ([]SeriesPoint, error) {
+	if step <= 0 || duration/step > maxQueriesInRange {
+		return nil, errors.Errorf("range of %s at step %s exceeds %d points", duration, step, maxQueriesInRange)
+	}
+
+	now := time.Now()
+	result, warnings, err := p.api.QueryRange(ctx, expression, v1.Range{Start: now.Add(-duration), End: now, Step: step})
+	if err != nil {
+		return nil, errors.Wrap(err, "series query")
+	}
+
+	if len(warnings) > 0 {
+		p.logger.Error(ctx, "series query", "warnings", warnings)
+	}
+
+	if result.Type() != model.ValMatrix {
+		return nil, errors.New("unhandled ValueType returned")
+	}
+
+	resultMatrix := result.(model.Matrix)
+	switch resultMatrix.Len() {
+	case 0:
+		return nil, errors.New("no results")
+	case 1:
+	default:
+		return nil, errors.Errorf("expected a single series, got %d; use an aggregation", resultMatrix.Len())
+	}
+
+	points := make([]SeriesPoint, 0, len(resultMatrix[0].Values))
+	for _, v := range resultMatrix[0].Values {
+		points = append(points, SeriesPoint{Timestamp: v.Timestamp.Time(), Value: float64(v.Value)})
+	}
+	return points, nil
+}
+
+func (p *cachingProvider) QuerySeries(ctx context.Context, expression string, duration, step time.Duration) ([]SeriesPoint, error) {
+	return QuerySeries(ctx, p.Provider, expression, duration, step)
+}
+
+func (p *resilientProvider) QuerySeries(ctx context.Context, expression string, duration, step time.Duration) ([]SeriesPoint, error) {
+	if _, ok := p.Provider.(SeriesProvider); !ok {
+		return nil, ErrSeriesNotSupported
+	}
+
+	var res []SeriesPoint
+	err := p.do(ctx, func(ctx context.Context) (err error) {
+		res, err = QuerySeries(ctx, p.Provider, expression, duration, step)
+		return err
+	})
+	return res, err
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPrometheusProviderQuerySeries(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	start := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	stream := func(values ...float64) *model.SampleStream {
+		s := &model.SampleStream{}
+		for i, v := range values {
+			s.Values = append(s.Values, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(start.Add(time.Duration(i) * time.Minute).UnixNano()),
+				Value:     model.SampleValue(v),
+			})
+		}
+		return s
+	}
+
+	tests := []struct {
+		name          string
+		result        model.Value
+		duration      time.Duration
+		expected      []SeriesPoint
+		errorExpected bool
+	}{
+		{
+			name:     "single series",
+			result:   model.Matrix{stream(1, 2)},
+			duration: time.Hour,
+			expected: []SeriesPoint{{Timestamp: start, Value: 1}, {Timestamp: start.Add(time.Minute), Value: 2}},
+		},
+		{
+			name:          "multiple series",
+			result:        model.Matrix{stream(1), stream(2)},
+			duration:      time.Hour,
+			errorExpected: true,
+		},
+		{
+			name:          "no series",
+			result:        model.Matrix{},
+			duration:      time.Hour,
+			errorExpected: true,
+		},
+		{
+			name:          "unexpected value type",
+			result:        model.Vector{},
+			duration:      time.Hour,
+			errorExpected: true,
+		},
+		{
+			name:          "too many points",
+			result:        model.Matrix{stream(1)},
+			duration:      maxQueriesInRange * time.Hour,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := mock.NewMockApi(nil, func(string, v1.Range) (model.Value, v1.Warnings, error) {
+				return test.result, nil, nil
+			})
+			res, err := QuerySeries(ctx, NewPrometheusProvider(m, logger, time.Minute), "expr", test.duration, time.Minute)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, res, len(test.expected))
+			for i := range test.expected {
+				require.True(t, test.expected[i].Timestamp.Equal(res[i].Timestamp))
+				require.Equal(t, test.expected[i].Value, res[i].Value)
+			}
+		})
+	}
+}
+
+func TestQuerySeriesOfWrappedProviders(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	m := mock.NewMockApi(nil, func(string, v1.Range) (model.Value, v1.Warnings, error) {
+		return model.Matrix{{Values: []model.SamplePair{{Value: 1}}}}, nil, nil
+	})
+	policy := QueryPolicy{}
+
+	p := NewQueryCache().Wrap(NewResilientProvider(NewPrometheusProvider(m, logger, time.Minute), policy, logger))
+	res, err := QuerySeries(ctx, p, "expr", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	p = NewQueryCache().Wrap(NewResilientProvider(NewScyllaAPIProvider(nil, logger), policy, logger))
+	_, err = QuerySeries(ctx, p, "expr", time.Hour, time.Minute)
+	require.Equal(t, ErrSeriesNotSupported, err)
+}
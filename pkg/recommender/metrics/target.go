@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+)
+
+// Target describes the rack the queries are performed for.
+// Providers which are not driven by a query language of their own use it to scope their queries.
+type Target struct {
+	Cluster *scyllav1.ScyllaCluster
+	Rack    *scyllav1.RackSpec
+}
+
+type targetKey struct{}
+
+// WithTarget returns a copy of ctx carrying the given target.
+func WithTarget(ctx context.Context, target Target) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+// TargetFromContext returns the target carried by ctx, if any.
+func TargetFromContext(ctx context.Context) (Target, bool) {
+	target, ok := ctx.Value(targetKey{}).(Target)
+	return target, ok && target.Cluster != nil
+}
+
+// key uniquely identifies the target.
+func (t Target) key() string {
+	if t.Cluster == nil {
+		return ""
+	}
+	key := t.Cluster.Namespace + "/" + t.Cluster.Name + "/" + t.Cluster.Spec.Datacenter.Name
+	if t.Rack != nil {
+		key += "/" + t.Rack.Name
+	}
+	return key
+}
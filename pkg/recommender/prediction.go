@@ -0,0 +1,244 @@
+package recommender
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultPredictionHistory = 14 * 24 * time.Hour
+	defaultPredictionSeason  = 24 * time.Hour
+	defaultPredictionStep    = 5 * time.Minute
+	defaultPredictionHorizon = time.Hour
+)
+
+// predictionSettings are the settings of predictive scaling with the defaults applied.
+type predictionSettings struct {
+	method                         v1alpha1.ForecastMethod
+	history, season, step, horizon time.Duration
+}
+
+func newPredictionSettings(prediction *v1alpha1.PredictiveScaling) predictionSettings {
+	s := predictionSettings{
+		method:  prediction.Method,
+		history: defaultPredictionHistory,
+		season:  defaultPredictionSeason,
+		step:    defaultPredictionStep,
+		horizon: defaultPredictionHorizon,
+	}
+	if s.method == "" {
+		s.method = v1alpha1.ForecastMethodHoltWinters
+	}
+	for _, d := range []struct {
+		value *metav1.Duration
+		dest  *time.Duration
+	}{
+		{prediction.History, &s.history},
+		{prediction.Season, &s.season},
+		{prediction.Step, &s.step},
+		{prediction.Horizon, &s.horizon},
+	} {
+		if d.value != nil {
+			*d.dest = d.value.Duration
+		}
+	}
+	return s
+}
+
+func validatePrediction(prediction *v1alpha1.PredictiveScaling) error {
+	if prediction == nil {
+		return nil
+	}
+
+	if prediction.Expression == "" {
+		return errors.New("expression has to be specified")
+	}
+	if _, err := renderExpression(prediction.Expression, ExpressionData{}); err != nil {
+		return errors.Wrap(err, "expression")
+	}
+	if prediction.TargetValue <= 0 {
+		return errors.New("target value has to be positive")
+	}
+	if prediction.Mode != v1alpha1.ScalingModeHorizontal && prediction.Mode != v1alpha1.ScalingModeVertical {
+		return errors.Errorf("unknown mode \"%s\"", prediction.Mode)
+	}
+
+	s := newPredictionSettings(prediction)
+	if s.method != v1alpha1.ForecastMethodHoltWinters && s.method != v1alpha1.ForecastMethodSeasonalNaive {
+		return errors.Errorf("unknown method \"%s\"", s.method)
+	}
+	if s.step <= 0 {
+		return errors.New("step has to be positive")
+	}
+	if s.season < s.step {
+		return errors.New("season can't be shorter than step")
+	}
+	if s.history < 2*s.season {
+		return errors.New("history has to cover at least two seasons")
+	}
+	if s.horizon <= 0 {
+		return errors.New("horizon has to be positive")
+	}
+
+	return nil
+}
+
+// forecastKey identifies the forecast of a rack's expression in the results cache.
+type forecastKey struct {
+	rack       string
+	expression string
+	settings   predictionSettings
+}
+
+// forecast forecasts the prediction's expression, rendered for the query target carried by ctx, holding a query slot.
+// If the history of the expression doesn't cover two seasons yet, nil is returned. The forecast is fitted at most
+// once per step, since the history gains at most one point in the meantime; until then the cached one is returned.
+func (r *recommender) forecast(ctx context.Context, provider metrics.Provider, prediction *v1alpha1.PredictiveScaling, now time.Time) (*v1alpha1.RackForecast, error) {
+	target, _ := metrics.TargetFromContext(ctx)
+	expression, err := renderExpression(prediction.Expression, newExpressionData(target))
+	if err != nil {
+		return nil, err
+	}
+
+	s := newPredictionSettings(prediction)
+	var rack string
+	if target.Rack != nil {
+		rack = rackKey(target.Cluster, target.Rack.Name)
+	}
+	key := forecastKey{rack: rack, expression: expression, settings: s}
+	if cached, ok := r.results.get(key, now); ok {
+		f := cached.(*v1alpha1.RackForecast)
+		if f == nil {
+			return nil, nil
+		}
+		res := *f
+		return &res, nil
+	}
+
+	f, err := r.fitForecast(ctx, provider, expression, s, now)
+	if err != nil {
+		return nil, err
+	}
+	r.results.put(key, f, now.Add(s.step))
+	if f == nil {
+		return nil, nil
+	}
+	res := *f
+	return &res, nil
+}
+
+// fitForecast queries the history of the expression and fits the forecast to it.
+func (r *recommender) fitForecast(ctx context.Context, provider metrics.Provider, expression string, s predictionSettings, now time.Time) (*v1alpha1.RackForecast, error) {
+	target, _ := metrics.TargetFromContext(ctx)
+	r.querySlots <- struct{}{}
+	points, err := metrics.QuerySeries(ctx, provider, expression, s.history, s.step)
+	<-r.querySlots
+	if err != nil {
+		return nil, err
+	}
+
+	start, series := resampleSeries(points, now.Add(-s.history), s.step)
+	season := int(s.season / s.step)
+	if len(series) < 2*season {
+		r.logger.Debug(ctx, "prediction history doesn't cover two seasons", "points", len(series), "season", season)
+		return nil, nil
+	}
+
+	horizon := int((s.horizon + s.step - 1) / s.step)
+	var f *seasonalForecast
+	if s.method == v1alpha1.ForecastMethodSeasonalNaive {
+		f, err = forecastSeasonalNaive(series, season, horizon)
+	} else {
+		f, err = forecastHoltWinters(series, season, horizon)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	peak := 0
+	for h := range f.values {
+		if f.values[h] > f.values[peak] {
+			peak = h
+		}
+	}
+
+	res := &v1alpha1.RackForecast{
+		Peak:     f.values[peak],
+		PeakTime: metav1.NewTime(start.Add(time.Duration(len(series)+peak) * s.step)),
+		Error:    f.err,
+	}
+	if target.Cluster != nil {
+		res.Datacenter = target.Cluster.Spec.Datacenter.Name
+	}
+	if target.Rack != nil {
+		res.Rack = target.Rack.Name
+	}
+	return res, nil
+}
+
+// resampleSeries places the points on a grid of the given step beginning at start. The grid starts with the first
+// point and the points missing from it repeat the preceding value. It returns the time of the grid's first point
+// along with the values.
+func resampleSeries(points []metrics.SeriesPoint, start time.Time, step time.Duration) (time.Time, []float64) {
+	var values []float64
+	var filled []bool
+	for _, p := range points {
+		if p.Timestamp.Before(start) {
+			continue
+		}
+		i := int((p.Timestamp.Sub(start) + step/2) / step)
+		for len(values) <= i {
+			values = append(values, 0)
+			filled = append(filled, false)
+		}
+		values[i], filled[i] = p.Value, true
+	}
+
+	first := 0
+	for first < len(filled) && !filled[first] {
+		first++
+	}
+	values, filled = values[first:], filled[first:]
+	for i := 1; i < len(values); i++ {
+		if !filled[i] {
+			values[i] = values[i-1]
+		}
+	}
+
+	return start.Add(time.Duration(first) * step), values
+}
+
+// predictionFactor returns the factor the rack has to be scaled by for the forecast peak to meet the target value.
+// It's 0 if there is no forecast.
+func predictionFactor(prediction *v1alpha1.PredictiveScaling, forecast *v1alpha1.RackForecast) float64 {
+	if prediction == nil || forecast == nil {
+		return 0
+	}
+	return forecast.Peak / prediction.TargetValue
+}
+
+// PredictedMembers returns the number of members needed to handle the load scaled by factor,
+// rounded up and clamped by min and max.
+func PredictedMembers(current int32, min, max *int32, factor float64) int32 {
+	val := int32(math.MaxInt32)
+	if scaled := math.Ceil(factor * float64(current)); scaled < math.MaxInt32 {
+		val = int32(scaled)
+	}
+
+	if max != nil {
+		val = util.MinInt32(val, *max)
+	}
+
+	if min != nil {
+		val = util.MaxInt32(val, *min)
+	}
+
+	return val
+}
@@ -0,0 +1,101 @@
+package recommender
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// providerCache keeps metrics.Provider instances keyed by the metrics source they were created for,
+// so that SCAs sharing a source share a provider as well.
+type providerCache struct {
+	client          client.Client
+	logger          log.Logger
+	defaultProvider metrics.Provider
+	factory         *metrics.Factory
+
+	mu        sync.Mutex
+	providers map[string]metrics.Provider
+	used      map[string]struct{}
+}
+
+func newProviderCache(c client.Client, defaultProvider metrics.Provider, factory *metrics.Factory, logger log.Logger) *providerCache {
+	return &providerCache{
+		client:          c,
+		logger:          logger,
+		defaultProvider: defaultProvider,
+		factory:         factory,
+		providers:       make(map[string]metrics.Provider),
+		used:            make(map[string]struct{}),
+	}
+}
+
+// sourceKey identifies a metrics source. The credentials' resource version is a part of the key,
+// so that rotating the credentials results in a new provider.
+type sourceKey struct {
+	Namespace                  string                 `json:"namespace"`
+	Source                     v1alpha1.MetricsSource `json:"source"`
+	CredentialsResourceVersion string                 `json:"credentialsResourceVersion,omitempty"`
+}
+
+// get returns the provider for the metrics source of the given SCA, creating it if necessary.
+// If the SCA does not specify a metrics source, the default provider is returned.
+func (pc *providerCache) get(ctx context.Context, sca *v1alpha1.ScyllaClusterAutoscaler) (metrics.Provider, error) {
+	source := sca.Spec.MetricsSource
+	if source == nil {
+		if pc.defaultProvider == nil {
+			return nil, errors.New("no metrics source specified and no default metrics source available")
+		}
+		return pc.defaultProvider, nil
+	}
+
+	key := sourceKey{Namespace: sca.Namespace, Source: *source}
+	if source.CredentialsSecretRef != nil {
+		secret, err := metrics.FetchCredentialsSecret(ctx, pc.client, sca.Namespace, source.CredentialsSecretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		key.CredentialsResourceVersion = secret.ResourceVersion
+	}
+
+	checksum, err := util.NewChecksum(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "metrics source checksum")
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.used[checksum] = struct{}{}
+	if p, ok := pc.providers[checksum]; ok {
+		return p, nil
+	}
+
+	pc.logger.Info(ctx, "creating metrics provider", "sca", sca.Name, "namespace", sca.Namespace)
+	p, err := pc.factory.New(ctx, sca.Namespace, source)
+	if err != nil {
+		return nil, err
+	}
+	pc.providers[checksum] = p
+
+	return p, nil
+}
+
+// prune drops the providers which were not requested since the previous call.
+func (pc *providerCache) prune() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	for key := range pc.providers {
+		if _, ok := pc.used[key]; !ok {
+			delete(pc.providers, key)
+		}
+	}
+	pc.used = make(map[string]struct{})
+}
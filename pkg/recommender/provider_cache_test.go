@@ -0,0 +1,125 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	mockprometheusapi "github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProviderCache(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"1"]}]}}`)
+	}))
+	defer srv.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-credentials",
+			Namespace: "test-sca-ns",
+		},
+		Data: map[string][]byte{
+			"token": []byte("secret-token"),
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+	factory := &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}
+
+	newSca := func(name string, source *v1alpha1.MetricsSource) *v1alpha1.ScyllaClusterAutoscaler {
+		return &v1alpha1.ScyllaClusterAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-sca-ns"},
+			Spec:       v1alpha1.ScyllaClusterAutoscalerSpec{MetricsSource: source},
+		}
+	}
+	urlSource := &v1alpha1.MetricsSource{URL: srv.URL}
+	credentialsSource := &v1alpha1.MetricsSource{
+		URL:                  srv.URL,
+		CredentialsSecretRef: &corev1.LocalObjectReference{Name: secret.Name},
+	}
+
+	t.Run("default provider is used when SCA doesn't specify a metrics source", func(t *testing.T) {
+		defaultProvider := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(nil, nil), logger, time.Minute)
+		pc := newProviderCache(c, defaultProvider, factory, logger)
+
+		p, err := pc.get(ctx, newSca("sca", nil))
+		require.NoError(t, err)
+		require.True(t, p == defaultProvider)
+	})
+
+	t.Run("error when neither metrics source nor default provider is available", func(t *testing.T) {
+		pc := newProviderCache(c, nil, factory, logger)
+
+		_, err := pc.get(ctx, newSca("sca", nil))
+		require.Error(t, err)
+	})
+
+	t.Run("SCAs sharing a metrics source share a provider", func(t *testing.T) {
+		pc := newProviderCache(c, nil, factory, logger)
+
+		p1, err := pc.get(ctx, newSca("sca-1", urlSource))
+		require.NoError(t, err)
+		p2, err := pc.get(ctx, newSca("sca-2", urlSource))
+		require.NoError(t, err)
+		p3, err := pc.get(ctx, newSca("sca-3", credentialsSource))
+		require.NoError(t, err)
+		require.True(t, p1 == p2)
+		require.False(t, p1 == p3)
+
+		res, err := p1.Query(ctx, "up")
+		require.NoError(t, err)
+		require.True(t, res)
+	})
+
+	t.Run("credentials are passed to the metrics source", func(t *testing.T) {
+		pc := newProviderCache(c, nil, factory, logger)
+
+		p, err := pc.get(ctx, newSca("sca", credentialsSource))
+		require.NoError(t, err)
+		_, err = p.Query(ctx, "up")
+		require.NoError(t, err)
+		require.Equal(t, "Bearer secret-token", authorization)
+	})
+
+	t.Run("missing credentials secret", func(t *testing.T) {
+		pc := newProviderCache(c, nil, factory, logger)
+
+		_, err := pc.get(ctx, newSca("sca", &v1alpha1.MetricsSource{
+			URL:                  srv.URL,
+			CredentialsSecretRef: &corev1.LocalObjectReference{Name: "missing"},
+		}))
+		require.Error(t, err)
+	})
+
+	t.Run("unused providers are pruned", func(t *testing.T) {
+		pc := newProviderCache(c, nil, factory, logger)
+
+		_, err := pc.get(ctx, newSca("sca", urlSource))
+		require.NoError(t, err)
+		pc.prune()
+		require.Len(t, pc.providers, 1)
+		pc.prune()
+		require.Len(t, pc.providers, 0)
+	})
+}
@@ -2,16 +2,21 @@ package recommender
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/scylladb/go-log"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/expression"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
 	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -19,19 +24,50 @@ import (
 
 type Recommender interface {
 	RunOnce(ctx context.Context) error
+
+	// RunForAlerts prepares recommendations for the SCAs with Alertmanager metrics sources
+	// whose rules reference any of the given alerts belonging to their targets.
+	RunForAlerts(ctx context.Context, alerts []metrics.FiredAlert) error
+}
+
+// Options tune the concurrency of the recommender.
+type Options struct {
+	// Workers is the number of SCAs evaluated concurrently. Defaults to 1.
+	Workers int
+
+	// QueryWorkers is the number of metrics queries performed concurrently. Defaults to 1.
+	QueryWorkers int
 }
 
 type recommender struct {
-	client          client.Client
-	logger          log.Logger
-	metricsProvider metrics.Provider
+	client     client.Client
+	logger     log.Logger
+	providers  *providerCache
+	workers    int
+	querySlots chan struct{}
+	behaviors  *behaviorHistory
+	results    *resultCache
 }
 
-func New(c client.Client, provider metrics.Provider, logger log.Logger) Recommender {
+// New creates a Recommender. provider is the default metrics provider used for SCAs which don't specify
+// their own metrics source, it may be nil. factory creates the providers for the SCAs' metrics sources.
+func New(c client.Client, provider metrics.Provider, factory *metrics.Factory, opts Options, logger log.Logger) Recommender {
+	workers, queryWorkers := opts.Workers, opts.QueryWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if queryWorkers < 1 {
+		queryWorkers = 1
+	}
+
 	return &recommender{
-		client:          c,
-		logger:          logger,
-		metricsProvider: provider,
+		client:     c,
+		logger:     logger,
+		providers:  newProviderCache(c, provider, factory, logger),
+		workers:    workers,
+		querySlots: make(chan struct{}, queryWorkers),
+		behaviors:  newBehaviorHistory(),
+		results:    newResultCache(),
 	}
 }
 
@@ -40,32 +76,232 @@ func (r *recommender) RunOnce(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "fetch SCAs")
 	}
+	defer r.providers.prune()
+	defer r.behaviors.prune()
+	defer r.results.prune()
+
+	r.recommendAll(ctx, scas.Items)
+
+	return nil
+}
 
+func (r *recommender) RunForAlerts(ctx context.Context, alerts []metrics.FiredAlert) error {
+	scas, err := r.fetchSCAs(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch SCAs")
+	}
+
+	var matching []v1alpha1.ScyllaClusterAutoscaler
 	for _, sca := range scas.Items {
-		targetRef := sca.Spec.TargetRef
-		sc, err := r.fetchScyllaCluster(ctx, targetRef.Name, targetRef.Namespace)
-		if err != nil {
-			r.logger.Error(ctx, "fetch target", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
-			r.updateSCAStatus(ctx, &sca, v1alpha1.UpdateStatusTargetFetchFail, nil)
-			continue
+		var alertNames []string
+		for _, a := range alerts {
+			if a.TargetsCluster(sca.Spec.TargetRef.Namespace, sca.Spec.TargetRef.Name) {
+				alertNames = append(alertNames, a.Name)
+			}
 		}
-
-		if !isScyllaClusterReady(sc) {
-			r.logger.Debug(ctx, "target readiness check", "sca", sca.Name, "namespace", sca.Namespace)
-			r.updateSCAStatus(ctx, &sca, v1alpha1.UpdateStatusTargetNotReady, nil)
-			continue
+		if referencesAlerts(&sca, alertNames) {
+			r.logger.Info(ctx, "alert fired", "sca", sca.Name, "namespace", sca.Namespace)
+			matching = append(matching, sca)
 		}
+	}
+	r.recommendAll(ctx, matching)
 
-		recommendations, err := r.getScyllaClusterRecommendations(ctx, sc, sca.Spec.ScalingPolicy)
-		status := v1alpha1.UpdateStatusOk
-		if err != nil {
-			r.logger.Error(ctx, "prepare recommendations", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
-			status = v1alpha1.UpdateStatusRecommendationsFail
+	return nil
+}
+
+// recommendAll prepares recommendations for the given SCAs using a bounded pool of workers.
+// Identical queries are performed only once.
+func (r *recommender) recommendAll(ctx context.Context, scas []v1alpha1.ScyllaClusterAutoscaler) {
+	cache := metrics.NewQueryCache()
+	workers := make(chan struct{}, r.workers)
+	var wg sync.WaitGroup
+	for i := range scas {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(sca *v1alpha1.ScyllaClusterAutoscaler) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			r.recommend(ctx, sca, cache)
+		}(&scas[i])
+	}
+	wg.Wait()
+}
+
+// referencesAlerts checks whether any of the SCA's rules is evaluated against any of the given alerts.
+func referencesAlerts(sca *v1alpha1.ScyllaClusterAutoscaler, alertNames []string) bool {
+	if sca.Spec.MetricsSource == nil || sca.Spec.MetricsSource.Type != v1alpha1.MetricsSourceTypeAlertmanager ||
+		sca.Spec.ScalingPolicy == nil {
+		return false
+	}
+
+	names := make(map[string]struct{}, len(alertNames))
+	for _, name := range alertNames {
+		names[name] = struct{}{}
+	}
+
+	for _, dc := range sca.Spec.ScalingPolicy.Datacenters {
+		for _, rack := range dc.RackScalingPolicies {
+			for i := range rack.ScalingRules {
+				condition, err := ruleCondition(&rack.ScalingRules[i])
+				if err != nil {
+					continue
+				}
+				for _, expr := range conditionExpressions(condition) {
+					// Alert names are not expected to be templated, so the values of variables don't matter.
+					rendered, err := renderExpression(expr, ExpressionData{})
+					if err != nil {
+						continue
+					}
+					e, err := expression.Parse(rendered)
+					if err != nil {
+						continue
+					}
+					for _, ref := range e.References() {
+						if _, ok := names[ref.Name]; ok {
+							return true
+						}
+					}
+				}
+			}
 		}
-		r.updateSCAStatus(ctx, &sca, status, recommendations)
 	}
 
-	return nil
+	return false
+}
+
+func (r *recommender) recommend(ctx context.Context, sca *v1alpha1.ScyllaClusterAutoscaler, cache *metrics.QueryCache) {
+	targetRef := sca.Spec.TargetRef
+	sc, err := r.fetchScyllaCluster(ctx, targetRef.Name, targetRef.Namespace)
+	if err != nil {
+		r.logger.Error(ctx, "fetch target", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+		r.updateSCAStatus(ctx, sca, v1alpha1.UpdateStatusTargetFetchFail, nil)
+		return
+	}
+	r.behaviors.touch(sc)
+
+	// The warm-ups are recorded even while the target isn't evaluated, so that they start as soon as the racks are ready.
+	// Failing to evaluate it doesn't stop the evaluation, since the racks it couldn't check are kept from being evaluated.
+	warmUp, err := newWarmUp(ctx, r.client, sca, sc, time.Now())
+	if err != nil {
+		r.logger.Error(ctx, "evaluate warm-up", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+	}
+	sca.Status.WarmUps = warmUp.started
+
+	pause := util.PauseFromAnnotations(time.Now(), sca, sc)
+	if pause.Paused {
+		r.pause(ctx, sca, pause)
+		return
+	}
+	setPausedCondition(sca, pause)
+
+	readiness, err := util.EvaluateTargetReadiness(ctx, r.client, sc)
+	if err != nil {
+		r.logger.Error(ctx, "evaluate target readiness", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+		r.updateSCAStatus(ctx, sca, v1alpha1.UpdateStatusTargetFetchFail, nil)
+		return
+	}
+	setTargetReadyCondition(sca, readiness)
+	if !readiness.Ready {
+		r.logger.Debug(ctx, "target readiness check", "sca", sca.Name, "namespace", sca.Namespace,
+			"reason", readiness.Reason, "rack", readiness.Rack)
+		r.updateSCAStatus(ctx, sca, v1alpha1.UpdateStatusTargetNotReady, nil)
+		return
+	}
+
+	provider, err := r.providers.get(ctx, sca)
+	if err != nil {
+		r.logger.Error(ctx, "get metrics provider", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+		r.updateSCAStatus(ctx, sca, v1alpha1.UpdateStatusMetricsSourceFail, nil)
+		return
+	}
+
+	if hc, ok := provider.(metrics.HealthChecker); ok && !hc.Healthy() {
+		r.suspend(ctx, sca)
+		return
+	}
+
+	recommendations, forecasts, err := r.getScyllaClusterRecommendations(ctx, cache.Wrap(provider), sc, sca.Spec.ScalingPolicy, pause, warmUp)
+	if errors.Is(err, metrics.ErrSourceUnhealthy) {
+		r.suspend(ctx, sca)
+		return
+	}
+	setWarmingUpCondition(sca, warmUp)
+
+	status := v1alpha1.UpdateStatusOk
+	if err != nil {
+		r.logger.Error(ctx, "prepare recommendations", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+		status = v1alpha1.UpdateStatusRecommendationsFail
+	} else if err = setPendingRecommendation(sca, sc, recommendations, time.Now()); err != nil {
+		r.logger.Error(ctx, "publish recommendations for approval", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+		status = v1alpha1.UpdateStatusRecommendationsFail
+	} else {
+		sca.Status.Forecasts = forecasts
+	}
+	meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.MetricsSourceHealthyCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: sca.Generation,
+		Reason:             "Healthy",
+		Message:            "Metrics source is healthy.",
+	})
+	r.updateSCAStatus(ctx, sca, status, recommendations)
+}
+
+// suspend records that the evaluation of the SCA's rules is suspended because of its metrics source being unhealthy.
+// The previous recommendations and update status are left intact.
+func (r *recommender) suspend(ctx context.Context, sca *v1alpha1.ScyllaClusterAutoscaler) {
+	r.logger.Info(ctx, "metrics source unhealthy, evaluation suspended", "sca", sca.Name, "namespace", sca.Namespace)
+	meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.MetricsSourceHealthyCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: sca.Generation,
+		Reason:             "CircuitOpen",
+		Message:            "Queries to the metrics source keep failing, evaluation is suspended.",
+	})
+
+	if err := r.client.Status().Update(ctx, sca); err != nil {
+		r.logger.Error(ctx, "SCA status update", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+	}
+}
+
+// pause records that autoscaling of the SCA's target is paused with the pause annotations.
+// The previous recommendations and update status are left intact.
+func (r *recommender) pause(ctx context.Context, sca *v1alpha1.ScyllaClusterAutoscaler, pause *util.Pause) {
+	r.logger.Debug(ctx, "autoscaling paused", "sca", sca.Name, "namespace", sca.Namespace)
+	setPausedCondition(sca, pause)
+
+	if err := r.client.Status().Update(ctx, sca); err != nil {
+		r.logger.Error(ctx, "SCA status update", "sca", sca.Name, "namespace", sca.Namespace, "error", err)
+	}
+}
+
+// setPausedCondition reports the pause in the SCA's status.
+func setPausedCondition(sca *v1alpha1.ScyllaClusterAutoscaler, pause *util.Pause) {
+	sca.Status.PausedRacks = pause.PausedRacks()
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.PausedCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: sca.Generation,
+		Reason:             "NotPaused",
+		Message:            "Autoscaling is not paused.",
+	}
+	switch {
+	case pause.Paused && pause.Until.IsZero():
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Paused"
+		condition.Message = "Autoscaling is paused."
+	case pause.Paused:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Paused"
+		condition.Message = fmt.Sprintf("Autoscaling is paused until %s.", pause.Until.UTC().Format(time.RFC3339))
+	case len(pause.Racks) > 0:
+		condition.Reason = "RacksPaused"
+		condition.Message = "Autoscaling of some racks is paused."
+	}
+	meta.SetStatusCondition(&sca.Status.Conditions, condition)
 }
 
 func (r *recommender) updateSCAStatus(ctx context.Context, sca *v1alpha1.ScyllaClusterAutoscaler, status v1alpha1.UpdateStatus, recommendations *v1alpha1.ScyllaClusterRecommendations) {
@@ -101,153 +337,371 @@ func (r *recommender) fetchScyllaCluster(ctx context.Context, name, namespace st
 	return sc, nil
 }
 
-func isScyllaClusterReady(sc *scyllav1.ScyllaCluster) bool {
-	for _, rack := range sc.Spec.Datacenter.Racks {
-		rackStatus, found := sc.Status.Racks[rack.Name]
-		if !found || rack.Members != rackStatus.ReadyMembers {
-			return false
-		}
+// setTargetReadyCondition reports the readiness of the SCA's target in its status.
+func setTargetReadyCondition(sca *v1alpha1.ScyllaClusterAutoscaler, readiness *util.TargetReadiness) {
+	status := metav1.ConditionFalse
+	if readiness.Ready {
+		status = metav1.ConditionTrue
 	}
-
-	return true
+	meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.TargetReadyCondition,
+		Status:             status,
+		ObservedGeneration: sca.Generation,
+		Reason:             readiness.Reason,
+		Message:            readiness.Message,
+	})
 }
 
-func (r *recommender) getScyllaClusterRecommendations(ctx context.Context, sc *scyllav1.ScyllaCluster, scalingPolicy *v1alpha1.ScalingPolicy) (*v1alpha1.ScyllaClusterRecommendations, error) {
+func (r *recommender) getScyllaClusterRecommendations(ctx context.Context, provider metrics.Provider, sc *scyllav1.ScyllaCluster, scalingPolicy *v1alpha1.ScalingPolicy, pause *util.Pause, warmUp *warmUp) (*v1alpha1.ScyllaClusterRecommendations, []v1alpha1.RackForecast, error) {
 	var datacenterRecommendations []v1alpha1.DatacenterRecommendations
+	var forecasts []v1alpha1.RackForecast
 	datacenter := sc.Spec.Datacenter
+	ctx = metrics.WithTarget(ctx, metrics.Target{Cluster: sc})
 	for _, datacenterScalingPolicy := range scalingPolicy.Datacenters {
 		if datacenterScalingPolicy.Name != datacenter.Name {
-			return nil, errors.Errorf("datacenter \"%s\" not found", datacenterScalingPolicy.Name)
+			return nil, nil, errors.Errorf("datacenter \"%s\" not found", datacenterScalingPolicy.Name)
 		}
 
-		recommendations, err := r.getDatacenterRecommendations(ctx, &datacenter, &datacenterScalingPolicy)
+		recommendations, datacenterForecasts, err := r.getDatacenterRecommendations(ctx, provider, &datacenter, &datacenterScalingPolicy, pause, warmUp)
 		if err != nil {
-			return nil, errors.Wrapf(err, "datacenter \"%s\"", datacenter.Name)
+			return nil, nil, errors.Wrapf(err, "datacenter \"%s\"", datacenter.Name)
 		}
 		if recommendations != nil {
 			datacenterRecommendations = append(datacenterRecommendations, *recommendations)
 		}
+		forecasts = append(forecasts, datacenterForecasts...)
 	}
 
 	if len(datacenterRecommendations) > 0 {
-		return &v1alpha1.ScyllaClusterRecommendations{DatacenterRecommendations: datacenterRecommendations}, nil
+		return &v1alpha1.ScyllaClusterRecommendations{DatacenterRecommendations: datacenterRecommendations}, forecasts, nil
 	}
 
-	return nil, nil
+	return nil, forecasts, nil
 }
 
-func (r *recommender) getDatacenterRecommendations(ctx context.Context, datacenter *scyllav1.DatacenterSpec, scalingPolicy *v1alpha1.DatacenterScalingPolicy) (*v1alpha1.DatacenterRecommendations, error) {
+func (r *recommender) getDatacenterRecommendations(ctx context.Context, provider metrics.Provider, datacenter *scyllav1.DatacenterSpec, scalingPolicy *v1alpha1.DatacenterScalingPolicy, pause *util.Pause, warmUp *warmUp) (*v1alpha1.DatacenterRecommendations, []v1alpha1.RackForecast, error) {
+	rackPolicies, err := effectiveRackPolicies(datacenter, scalingPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var rackRecommendations []v1alpha1.RackRecommendations
-	for _, rackScalingPolicy := range scalingPolicy.RackScalingPolicies {
-		var rack scyllav1.RackSpec
-
-		found := false
-		for i := range datacenter.Racks {
-			if datacenter.Racks[i].Name == rackScalingPolicy.Name {
-				rack = datacenter.Racks[i]
-				found = true
-				break
-			}
+	var forecasts []v1alpha1.RackForecast
+	for _, rp := range rackPolicies {
+		if pause.RackPaused(rp.rack.Name) {
+			continue
 		}
-
-		if !found {
-			return nil, errors.Errorf("rack \"%s\" not found", rackScalingPolicy.Name)
+		if warmUp.rackWarmingUp(rp.rack.Name, rp.policy.WarmUp) {
+			r.logger.Debug(ctx, "rack warming up, evaluation skipped", "rack", rp.rack.Name)
+			continue
 		}
 
-		recommendations, err := r.getRackRecommendations(ctx, &rack, &rackScalingPolicy)
+		target, _ := metrics.TargetFromContext(ctx)
+		target.Rack = rp.rack
+		recommendations, forecast, err := r.getRackRecommendations(metrics.WithTarget(ctx, target), provider, rp.rack, rp.policy)
 		if err != nil {
-			return nil, errors.Wrapf(err, "rack \"%s\"", rack.Name)
+			return nil, nil, errors.Wrapf(err, "rack \"%s\"", rp.rack.Name)
 		}
 		if recommendations != nil {
 			rackRecommendations = append(rackRecommendations, *recommendations)
 		}
+		if forecast != nil {
+			forecasts = append(forecasts, *forecast)
+		}
 	}
 
 	if len(rackRecommendations) > 0 {
-		return &v1alpha1.DatacenterRecommendations{Name: datacenter.Name, RackRecommendations: rackRecommendations}, nil
+		return &v1alpha1.DatacenterRecommendations{Name: datacenter.Name, RackRecommendations: rackRecommendations}, forecasts, nil
 	}
 
-	return nil, nil
+	return nil, forecasts, nil
+}
+
+type ruleResult struct {
+	res bool
+	err error
 }
 
-func (r *recommender) getRackRecommendations(ctx context.Context, rack *scyllav1.RackSpec, scalingPolicy *v1alpha1.RackScalingPolicy) (*v1alpha1.RackRecommendations, error) {
+// evaluateRules evaluates the rules concurrently, limited by the number of query workers.
+// The rules' expressions are rendered for the query target carried by ctx first.
+// The results are in the order of the rules.
+func (r *recommender) evaluateRules(ctx context.Context, provider metrics.Provider, rules []v1alpha1.ScalingRule) []ruleResult {
+	target, _ := metrics.TargetFromContext(ctx)
+	data := newExpressionData(target)
+
+	results := make([]ruleResult, len(rules))
+	var wg sync.WaitGroup
+	for i := range rules {
+		wg.Add(1)
+		go func(rule *v1alpha1.ScalingRule, result *ruleResult) {
+			defer wg.Done()
+			r.querySlots <- struct{}{}
+			defer func() {
+				<-r.querySlots
+			}()
+
+			condition, err := ruleCondition(rule)
+			if err != nil {
+				result.err = err
+				return
+			}
+
+			result.res, result.err = evaluateCondition(ctx, provider, condition, data)
+		}(&rules[i], &results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (r *recommender) getRackRecommendations(ctx context.Context, provider metrics.Provider, rack *scyllav1.RackSpec, scalingPolicy *v1alpha1.RackScalingPolicy) (*v1alpha1.RackRecommendations, *v1alpha1.RackForecast, error) {
 	if scalingPolicy == nil {
-		return nil, errors.New("scaling policy not defined")
+		return nil, nil, errors.New("scaling policy not defined")
 	} else if rack == nil {
-		return nil, errors.New("rack spec not defined")
+		return nil, nil, errors.New("rack spec not defined")
+	}
+
+	scalingPolicy, scheduled, err := applySchedules(scalingPolicy, time.Now())
+	if err != nil {
+		return nil, nil, err
 	}
-	var err error
-	var priority int32 = math.MaxInt32
-	members := rack.Members
-	resources := rack.Resources
 
-	applied := false
-	for _, rule := range scalingPolicy.ScalingRules {
-		if rule.Priority >= priority {
-			continue // TODO solve conflicting priorities, i.e. two rules with equal priorities???
+	var forecast *v1alpha1.RackForecast
+	if scalingPolicy.Prediction != nil {
+		if forecast, err = r.forecast(ctx, provider, scalingPolicy.Prediction, time.Now()); err != nil {
+			return nil, nil, errors.Wrap(err, "prediction")
 		}
+	}
+	// The forecast only scales the rack up, ahead of the peak.
+	var predictedHorizontal, predictedVertical bool
+	forecastFactor := predictionFactor(scalingPolicy.Prediction, forecast)
+	if forecastFactor > 1 {
+		predictedHorizontal = scalingPolicy.Prediction.Mode == v1alpha1.ScalingModeHorizontal
+		predictedVertical = scalingPolicy.Prediction.Mode == v1alpha1.ScalingModeVertical
+	}
+
+	results := r.evaluateRules(ctx, provider, scalingPolicy.ScalingRules)
 
-		var res bool
-		if rule.For != nil {
-			var step *time.Duration = nil
-			if rule.Step != nil {
-				step = &rule.Step.Duration
+	var horizontal, vertical *v1alpha1.ScalingRule
+	if scalingPolicy.Strategy == v1alpha1.RackScalingStrategyHybrid {
+		chosen, err := chooseRule(scalingPolicy.ScalingRules, results, anyMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if chosen != nil {
+			if chosen.Formula != "" {
+				return nil, nil, errors.Errorf("rule \"%s\": formula can't be used with the %s strategy", chosen.Name, scalingPolicy.Strategy)
 			}
-			res, err = r.metricsProvider.RangedQuery(ctx, rule.Expression, rule.For.Duration, step)
-		} else {
-			res, err = r.metricsProvider.Query(ctx, rule.Expression)
+			if hybridScalesMembers(rack, scalingPolicy, chosen.ScalingFactor) {
+				horizontal = chosen
+			} else {
+				vertical = chosen
+			}
+		}
+	} else {
+		if horizontal, err = chooseRule(scalingPolicy.ScalingRules, results, modeOf(v1alpha1.ScalingModeHorizontal)); err != nil {
+			return nil, nil, err
 		}
+		if vertical, err = chooseRule(scalingPolicy.ScalingRules, results, modeOf(v1alpha1.ScalingModeVertical)); err != nil {
+			return nil, nil, err
+		}
+	}
 
+	members := rack.Members
+	var minMembers, maxMembers *int32 = nil, nil
+	if scalingPolicy.MemberPolicy != nil {
+		maxMembers = scalingPolicy.MemberPolicy.MaxAllowed
+		minMembers = scalingPolicy.MemberPolicy.MinAllowed
+	}
+	switch {
+	case horizontal != nil && horizontal.Formula != "":
+		value, err := r.evaluateRuleFormula(ctx, provider, rack, scalingPolicy, horizontal)
 		if err != nil {
-			return nil, errors.Wrapf(err, "rule \"%s\"", rule.Name)
+			return nil, nil, err
 		}
+		members = MembersFromFormula(value, minMembers, maxMembers)
+	case horizontal != nil:
+		members = CalculateMembers(rack.Members, minMembers, maxMembers, horizontal.ScalingFactor)
+	case scheduled != nil:
+		members = scheduled.scheduledMembers(rack.Members, minMembers, maxMembers)
+	}
+	if predictedHorizontal {
+		members = util.MaxInt32(members, PredictedMembers(rack.Members, minMembers, maxMembers, forecastFactor))
+	}
+	members = r.applyBehavior(ctx, rack, scalingPolicy, members)
 
-		if !res {
-			continue
+	resources := *rack.Resources.DeepCopy()
+	rightsized := false
+	if scalingPolicy.Rightsizing != nil {
+		if rightsized, err = r.rightsize(ctx, provider, scalingPolicy.Rightsizing, scalingPolicy.ResourcePolicy, &resources); err != nil {
+			return nil, nil, errors.Wrap(err, "rightsizing")
+		}
+	}
+	// Rules, schedules and predictions scale the rightsized CPU, which has to be requested. Schedules without
+	// a CPU target only clamp the CPU to their bounds, so they leave racks without CPU requests as they are.
+	_, cpuRequested := resources.Requests[corev1.ResourceCPU]
+	if vertical != nil || predictedVertical || (scheduled != nil && (scheduled.cpu != nil || cpuRequested)) {
+		if !cpuRequested {
+			return nil, nil, errors.Errorf("cpu requests undefined")
 		}
 
-		if rule.ScalingMode == v1alpha1.ScalingModeHorizontal {
-			var min, max *int32 = nil, nil
-			if scalingPolicy.MemberPolicy != nil {
-				max = scalingPolicy.MemberPolicy.MaxAllowed
-				min = scalingPolicy.MemberPolicy.MinAllowed
+		var min, max *resource.Quantity = nil, nil
+		controlledValues := v1alpha1.RackControlledValuesRequestsAndLimits
+		if scalingPolicy.ResourcePolicy != nil {
+			min = scalingPolicy.ResourcePolicy.MinAllowedCpu
+			max = scalingPolicy.ResourcePolicy.MaxAllowedCpu
+			if scalingPolicy.ResourcePolicy.RackControlledValues != "" {
+				controlledValues = scalingPolicy.ResourcePolicy.RackControlledValues
 			}
+		}
 
-			members = CalculateMembers(rack.Members, min, max, rule.ScalingFactor)
-			resources = rack.Resources
-		} else {
-			if rack.Resources.Requests == nil || rack.Resources.Requests.Cpu() == nil {
-				return nil, errors.Errorf("cpu requests undefined")
+		var scale func(current *resource.Quantity) resource.Quantity
+		switch {
+		case vertical != nil && vertical.Formula != "":
+			value, err := r.evaluateRuleFormula(ctx, provider, rack, scalingPolicy, vertical)
+			if err != nil {
+				return nil, nil, err
 			}
-
-			var min, max *resource.Quantity = nil, nil
-			if scalingPolicy.ResourcePolicy != nil {
-				min = scalingPolicy.ResourcePolicy.MinAllowedCpu
-				max = scalingPolicy.ResourcePolicy.MaxAllowedCpu
+			scale = func(*resource.Quantity) resource.Quantity {
+				return CPUFromFormula(value, min, max)
+			}
+		case vertical != nil:
+			scale = func(current *resource.Quantity) resource.Quantity {
+				return CalculateCPU(current, min, max, vertical.ScalingFactor)
 			}
-			resources.Requests[corev1.ResourceCPU] = CalculateCPU(rack.Resources.Requests.Cpu(), min, max, rule.ScalingFactor)
+		case scheduled != nil:
+			scale = func(current *resource.Quantity) resource.Quantity {
+				return scheduled.scheduledCPU(current, min, max)
+			}
+		default:
+			scale = func(current *resource.Quantity) resource.Quantity {
+				return current.DeepCopy()
+			}
+		}
+		if predictedVertical {
+			scaleWithoutPrediction := scale
+			scale = func(current *resource.Quantity) resource.Quantity {
+				return util.MaxQuantity(scaleWithoutPrediction(current), CalculateCPU(current, min, max, forecastFactor))
+			}
+		}
+		resources.Requests[corev1.ResourceCPU] = scale(resources.Requests.Cpu())
 
-			if rack.Resources.Limits != nil && rack.Resources.Limits.Cpu() != nil {
-				if scalingPolicy.ResourcePolicy.RackControlledValues == v1alpha1.RackControlledValuesRequestsAndLimits {
-					resources.Limits[corev1.ResourceCPU] = CalculateCPU(rack.Resources.Limits.Cpu(), min, max, rule.ScalingFactor)
-				} else {
-					resources.Requests[corev1.ResourceCPU] = util.MinQuantity(resources.Requests[corev1.ResourceCPU], resources.Limits[corev1.ResourceCPU])
-				}
+		if _, ok := resources.Limits[corev1.ResourceCPU]; ok {
+			if controlledValues == v1alpha1.RackControlledValuesRequestsAndLimits {
+				resources.Limits[corev1.ResourceCPU] = scale(resources.Limits.Cpu())
+			} else {
+				resources.Requests[corev1.ResourceCPU] = util.MinQuantity(resources.Requests[corev1.ResourceCPU], resources.Limits[corev1.ResourceCPU])
 			}
-			members = rack.Members
 		}
+	}
+
+	if horizontal == nil && vertical == nil && scheduled == nil && !predictedHorizontal && !predictedVertical && !rightsized {
+		return nil, forecast, nil
+	}
+
+	return &v1alpha1.RackRecommendations{Name: rack.Name, Members: &members, Resources: &resources}, forecast, nil
+}
+
+// ruleFilter selects the rules competing with each other.
+type ruleFilter func(rule *v1alpha1.ScalingRule) bool
+
+func anyMode(*v1alpha1.ScalingRule) bool {
+	return true
+}
 
-		priority = rule.Priority
-		applied = true
+func modeOf(mode v1alpha1.ScalingMode) ruleFilter {
+	return func(rule *v1alpha1.ScalingRule) bool {
+		return rule.ScalingMode == mode
 	}
+}
 
-	if applied {
-		return &v1alpha1.RackRecommendations{Name: rack.Name, Members: &members, Resources: &resources}, nil
+// chooseRule returns the triggered rule selected by the filter with the lowest priority.
+// Rules of equal priority are resolved by their order in the policy. An error of a rule which would be chosen
+// over the triggered one, had it been triggered, is returned instead.
+func chooseRule(rules []v1alpha1.ScalingRule, results []ruleResult, filter ruleFilter) (*v1alpha1.ScalingRule, error) {
+	order := make([]int, 0, len(rules))
+	for i := range rules {
+		if filter(&rules[i]) {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return rules[order[a]].Priority < rules[order[b]].Priority
+	})
+
+	for _, i := range order {
+		if results[i].err != nil {
+			return nil, errors.Wrapf(results[i].err, "rule \"%s\"", rules[i].Name)
+		}
+		if results[i].res {
+			return &rules[i], nil
+		}
 	}
 
 	return nil, nil
 }
 
+// hybridScalesMembers tells whether a rule with the given factor scales the rack horizontally under the hybrid strategy.
+// Scaling up is vertical until the CPU requests reach MaxAllowedCpu, and scaling down is horizontal until the members
+// reach MinAllowed, which defaults to a single member.
+func hybridScalesMembers(rack *scyllav1.RackSpec, scalingPolicy *v1alpha1.RackScalingPolicy, factor float64) bool {
+	switch {
+	case factor > 1:
+		if scalingPolicy.ResourcePolicy == nil || scalingPolicy.ResourcePolicy.MaxAllowedCpu == nil {
+			return false
+		}
+		cpu, ok := rack.Resources.Requests[corev1.ResourceCPU]
+		return ok && cpu.Cmp(*scalingPolicy.ResourcePolicy.MaxAllowedCpu) >= 0
+	case factor < 1:
+		var min int32 = 1
+		if scalingPolicy.MemberPolicy != nil && scalingPolicy.MemberPolicy.MinAllowed != nil {
+			min = *scalingPolicy.MemberPolicy.MinAllowed
+		}
+		return rack.Members > min
+	default:
+		return false
+	}
+}
+
+// evaluateRuleFormula evaluates the formula of the chosen rule, holding a query slot.
+func (r *recommender) evaluateRuleFormula(ctx context.Context, provider metrics.Provider, rack *scyllav1.RackSpec, scalingPolicy *v1alpha1.RackScalingPolicy, rule *v1alpha1.ScalingRule) (float64, error) {
+	target, _ := metrics.TargetFromContext(ctx)
+	r.querySlots <- struct{}{}
+	defer func() {
+		<-r.querySlots
+	}()
+
+	value, err := evaluateFormula(ctx, provider, rule, newExpressionData(target), formulaVariables(rack, rule.ScalingMode, scalingPolicy))
+	if err != nil {
+		return 0, errors.Wrapf(err, "rule \"%s\"", rule.Name)
+	}
+	return value, nil
+}
+
+// applyBehavior applies the rack's scaling behavior to the recommended number of members.
+// It has to be called on every evaluation of the rack, so that the behavior accounts for the evaluations
+// resulting in no change.
+func (r *recommender) applyBehavior(ctx context.Context, rack *scyllav1.RackSpec, scalingPolicy *v1alpha1.RackScalingPolicy, members int32) int32 {
+	if scalingPolicy.Behavior == nil {
+		return members
+	}
+
+	target, _ := metrics.TargetFromContext(ctx)
+	behaved := r.behaviors.apply(rackKey(target.Cluster, rack.Name), time.Now(), rack.Members, members, scalingPolicy.Behavior)
+	if behaved == members || scalingPolicy.MemberPolicy == nil {
+		return behaved
+	}
+
+	// Recommendations kept in the stabilization window may exceed the bounds, if they changed since.
+	if max := scalingPolicy.MemberPolicy.MaxAllowed; max != nil {
+		behaved = util.MinInt32(behaved, *max)
+	}
+	if min := scalingPolicy.MemberPolicy.MinAllowed; min != nil {
+		behaved = util.MaxInt32(behaved, *min)
+	}
+	return behaved
+}
+
 func CalculateMembers(current int32, min, max *int32, factor float64) int32 {
 	var val int32
 	// if scaled current will overflow int32
@@ -2,6 +2,8 @@ package recommender
 
 import (
 	"context"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
 	"github.com/scylladb/go-log"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
@@ -11,12 +13,17 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
 	"testing"
 	"time"
 )
@@ -213,6 +220,7 @@ func TestRunOnce(t *testing.T) {
 		statusTargetFetchFail     = v1alpha1.UpdateStatusTargetFetchFail
 		statusTargetNotReady      = v1alpha1.UpdateStatusTargetNotReady
 		statusRecommendationsFail = v1alpha1.UpdateStatusRecommendationsFail
+		statusMetricsSourceFail   = v1alpha1.UpdateStatusMetricsSourceFail
 	)
 	ctx := log.WithNewTraceID(context.Background())
 	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
@@ -224,7 +232,7 @@ func TestRunOnce(t *testing.T) {
 	c := clientBuilder.Build()
 	m := mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), mockprometheusapi.SimpleRangedQueryFunction())
 	pp := metrics.NewPrometheusProvider(m, logger, time.Minute)
-	r := New(c, pp, logger)
+	r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{Workers: 2, QueryWorkers: 4}, logger)
 
 	tests := []struct {
 		name                    string
@@ -234,7 +242,7 @@ func TestRunOnce(t *testing.T) {
 		expectedStatus          *v1alpha1.UpdateStatus
 	}{
 		{
-			name: "Recommend scaling members because of better priority",
+			name: "Recommends scaling both members and cpu",
 			sc: newSingleDcSc(scName, scNamespace, dcName,
 				[]scyllav1.RackSpec{
 					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
@@ -252,11 +260,11 @@ func TestRunOnce(t *testing.T) {
 					v1alpha1.RackControlledValuesRequestsAndLimits)),
 			expectedRecommendations: newSingleDcSCRecommendations(
 				dcName,
-				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor2),
+				*newRackRecommendations(rackName, stringMulFloat64(baseCpu, factor4), stringMulFloat64(baseCpu, factor4), memory, baseMembers*factor2),
 			),
 		},
 		{
-			name: "Recommends scaling cpu because of better priority",
+			name: "Recommends scaling cpu because of better priority among vertical rules",
 			sc: newSingleDcSc(scName, scNamespace, dcName,
 				[]scyllav1.RackSpec{
 					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
@@ -267,8 +275,9 @@ func TestRunOnce(t *testing.T) {
 			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
 				newRackScalingPolicy(rackName,
 					[]v1alpha1.ScalingRule{
-						*newScalingRule(ruleName, priority2, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
+						*newScalingRule(ruleName, priority2, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, factor2),
 						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, factor4),
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnFalse, nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
 					},
 					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
 					v1alpha1.RackControlledValuesRequestsAndLimits)),
@@ -278,7 +287,308 @@ func TestRunOnce(t *testing.T) {
 			),
 		},
 		{
-			name: "Recommends scaling cpu because of better priority, but with duration for, duration step",
+			name: "Rules of equal priority are resolved by their order",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority2, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor6),
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor4),
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits)),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor4),
+			),
+		},
+		{
+			name: "Error of a rule with worse priority than the triggered one is ignored",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority2, mockprometheusapi.IncorrectQueryExpr, nil, nil, v1alpha1.ScalingModeHorizontal, factor6),
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits)),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor2),
+			),
+		},
+		{
+			name: "Rule expressions are rendered for the rack",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1,
+							`{{ if and (eq .Cluster "`+scName+`") (eq .Rack "`+rackName+`") (eq .Members 3) }}`+mockprometheusapi.QueryWillReturnTrue+`{{ else }}`+mockprometheusapi.QueryWillReturnFalse+`{{ end }}`,
+							nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits)),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor2),
+			),
+		},
+		{
+			name: "Rule conditions are evaluated as a tree",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*setCondition(&v1alpha1.RuleCondition{
+							AllOf: []v1alpha1.RuleCondition{
+								{Expression: mockprometheusapi.QueryWillReturnTrue, For: duration5},
+								{Not: &v1alpha1.RuleCondition{Expression: mockprometheusapi.QueryWillReturnTrue}},
+							},
+						}, newScalingRule(ruleName, priority1, "", nil, nil, v1alpha1.ScalingModeVertical, factor4)),
+						*setCondition(&v1alpha1.RuleCondition{
+							AnyOf: []v1alpha1.RuleCondition{
+								{Expression: mockprometheusapi.QueryWillReturnFalse},
+								{Expression: mockprometheusapi.QueryWillReturnTrue, For: duration5, Step: duration10},
+							},
+						}, newScalingRule(ruleName, priority2, "", nil, nil, v1alpha1.ScalingModeHorizontal, factor2)),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits)),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor2),
+			),
+		},
+		{
+			name: "Recommends members computed by the formula",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*setFormula("max(current + 2.0, minAllowed)", nil,
+							newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 0)),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits)),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers+2),
+			),
+		},
+		{
+			name: "Recommends cpu computed by the formula",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*setFormula("current * 2.0", nil,
+							newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, 0)),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits)),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, stringMulFloat64(baseCpu, 2), stringMulFloat64(baseCpu, 2), memory, baseMembers),
+			),
+		},
+		{
+			name: "Recommended members are limited by the scaling behavior",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setBehavior(&v1alpha1.RackScalingBehavior{
+					ScaleUp: &v1alpha1.ScalingBehaviorRules{
+						Policies: []v1alpha1.ScalingRatePolicy{
+							{Type: v1alpha1.ScalingRatePolicyTypeMembers, Value: 2, Period: metav1.Duration{Duration: time.Hour}},
+						},
+					},
+				}, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 10),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers+2),
+			),
+		},
+		{
+			name: "Hybrid strategy scales cpu up until the cap",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setStrategy(v1alpha1.RackScalingStrategyHybrid, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, stringMulFloat64(baseCpu, factor2), stringMulFloat64(baseCpu, factor2), memory, baseMembers),
+			),
+		},
+		{
+			name: "Hybrid strategy scales members up at the cpu cap",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, maxAllowedCpu.String(), maxAllowedCpu.String(), memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setStrategy(v1alpha1.RackScalingStrategyHybrid, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, factor2),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, maxAllowedCpu.String(), maxAllowedCpu.String(), memory, baseMembers*factor2),
+			),
+		},
+		{
+			name: "Hybrid strategy scales members down until the minimum",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setStrategy(v1alpha1.RackScalingStrategyHybrid, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, 0.5),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, minAllowedMembers),
+			),
+		},
+		{
+			name: "Hybrid strategy scales cpu down at the minimal members",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, minAllowedMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(minAllowedMembers, minAllowedMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setStrategy(v1alpha1.RackScalingStrategyHybrid, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 0.5),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, stringMulFloat64(baseCpu, 0.5), stringMulFloat64(baseCpu, 0.5), memory, minAllowedMembers),
+			),
+		},
+		{
+			name: "Recommends members scheduled for the active window",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setSchedules([]v1alpha1.ScalingSchedule{
+					{Name: "always", Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}, Members: util.Int32ptr(baseMembers * factor2)},
+				}, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnFalse, nil, nil, v1alpha1.ScalingModeHorizontal, factor4),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor2),
+			),
+		},
+		{
+			name: "Triggered rule takes precedence over the scheduled members",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+				setSchedules([]v1alpha1.ScalingSchedule{
+					{Name: "always", Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}, Members: util.Int32ptr(baseMembers * factor2)},
+				}, newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor4),
+					},
+					minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+					v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedRecommendations: newSingleDcSCRecommendations(
+				dcName,
+				*newRackRecommendations(rackName, baseCpu, baseCpu, memory, baseMembers*factor4),
+			),
+		},
+		{
+			name: "Recommends scaling both members and cpu, but with duration for, duration step",
 			sc: newSingleDcSc(scName, scNamespace, dcName,
 				[]scyllav1.RackSpec{
 					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
@@ -296,7 +606,7 @@ func TestRunOnce(t *testing.T) {
 					v1alpha1.RackControlledValuesRequestsAndLimits)),
 			expectedRecommendations: newSingleDcSCRecommendations(
 				dcName,
-				*newRackRecommendations(rackName, stringMulFloat64(baseCpu, factor4), stringMulFloat64(baseCpu, factor4), memory, baseMembers),
+				*newRackRecommendations(rackName, stringMulFloat64(baseCpu, factor4), stringMulFloat64(baseCpu, factor4), memory, baseMembers*factor2),
 			),
 		},
 		{
@@ -459,6 +769,25 @@ func TestRunOnce(t *testing.T) {
 					v1alpha1.RackControlledValuesRequestsAndLimits)),
 			expectedStatus: &statusTargetNotReady,
 		},
+		{
+			name: "Metrics source cannot be set up",
+			sc: newSingleDcSc(scName, scNamespace, dcName,
+				[]scyllav1.RackSpec{
+					*getRackSpec(rackName, baseMembers, baseCpu, baseCpu, memory, memory),
+				},
+				map[string]scyllav1.RackStatus{
+					rackName: *getRackStatus(baseMembers, baseMembers),
+				}),
+			sca: setMetricsSource(&v1alpha1.MetricsSource{},
+				newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName,
+					newRackScalingPolicy(rackName,
+						[]v1alpha1.ScalingRule{
+							*newScalingRule(ruleName, priority1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, factor2),
+						},
+						minAllowedMembers, maxAllowedMembers, minAllowedCpu, maxAllowedCpu,
+						v1alpha1.RackControlledValuesRequestsAndLimits))),
+			expectedStatus: &statusMetricsSourceFail,
+		},
 	}
 
 	for _, test := range tests {
@@ -466,6 +795,9 @@ func TestRunOnce(t *testing.T) {
 			if test.sc != nil {
 				err := c.Create(ctx, test.sc)
 				require.NoError(t, err, "Couldn't create scylla cluster. Message: '%s'", err)
+				for _, sts := range getStatefulSets(test.sc) {
+					require.NoError(t, c.Create(ctx, sts))
+				}
 			}
 			if test.sca != nil {
 				err := c.Create(ctx, test.sca)
@@ -485,6 +817,12 @@ func TestRunOnce(t *testing.T) {
 
 			if test.expectedStatus != nil {
 				require.Equal(t, test.expectedStatus, sca.Status.UpdateStatus)
+				if *test.expectedStatus == statusTargetNotReady {
+					condition := meta.FindStatusCondition(sca.Status.Conditions, v1alpha1.TargetReadyCondition)
+					require.NotNil(t, condition)
+					require.Equal(t, metav1.ConditionFalse, condition.Status)
+					require.Equal(t, util.MembersNotReadyReason, condition.Reason)
+				}
 			} else {
 				require.NoError(t, err, "Run Once returned error. Message: '%s'", err)
 				if !scsRecommendationsEquivalent(
@@ -499,6 +837,9 @@ func TestRunOnce(t *testing.T) {
 			if test.sc != nil {
 				err = c.Delete(ctx, test.sc)
 				require.NoError(t, err, "Couldn't delete scylla cluster. Message: '%s'", err)
+				for _, sts := range getStatefulSets(test.sc) {
+					require.NoError(t, c.Delete(ctx, sts))
+				}
 			}
 			if test.sca != nil {
 				err = c.Delete(ctx, test.sca)
@@ -507,3 +848,255 @@ func TestRunOnce(t *testing.T) {
 		})
 	}
 }
+
+func TestReferencesAlerts(t *testing.T) {
+	newSca := func(sourceType v1alpha1.MetricsSourceType, expression string) *v1alpha1.ScyllaClusterAutoscaler {
+		sca := newSingleDcSca("sca", "sca-ns", "sc", "sc-ns", "dc",
+			newRackScalingPolicy("rack",
+				[]v1alpha1.ScalingRule{
+					*newScalingRule("rule", 1, expression, nil, nil, v1alpha1.ScalingModeHorizontal, 2),
+				},
+				1, 10, resource.MustParse("1"), resource.MustParse("10"),
+				v1alpha1.RackControlledValuesRequestsAndLimits))
+		return setMetricsSource(&v1alpha1.MetricsSource{Type: sourceType}, sca)
+	}
+
+	tests := []struct {
+		name     string
+		sca      *v1alpha1.ScyllaClusterAutoscaler
+		expected bool
+	}{
+		{
+			name:     "rule references a fired alert",
+			sca:      newSca(v1alpha1.MetricsSourceTypeAlertmanager, `ScyllaOverload{cluster="sc"} and not ScyllaNodeDown`),
+			expected: true,
+		},
+		{
+			name:     "rule doesn't reference fired alerts",
+			sca:      newSca(v1alpha1.MetricsSourceTypeAlertmanager, `ScyllaNodeDown`),
+			expected: false,
+		},
+		{
+			name: "rule condition references a fired alert",
+			sca: func() *v1alpha1.ScyllaClusterAutoscaler {
+				sca := newSca(v1alpha1.MetricsSourceTypeAlertmanager, "")
+				rule := &sca.Spec.ScalingPolicy.Datacenters[0].RackScalingPolicies[0].ScalingRules[0]
+				setCondition(&v1alpha1.RuleCondition{
+					AllOf: []v1alpha1.RuleCondition{
+						{Expression: `ScyllaNodeDown`},
+						{Not: &v1alpha1.RuleCondition{Expression: `ScyllaDiskFull`}},
+					},
+				}, rule)
+				return sca
+			}(),
+			expected: true,
+		},
+		{
+			name:     "metrics source is not Alertmanager",
+			sca:      newSca(v1alpha1.MetricsSourceTypePrometheus, `ScyllaOverload`),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, referencesAlerts(test.sca, []string{"ScyllaOverload", "ScyllaDiskFull"}))
+		})
+	}
+}
+
+func TestRunForAlertsScopesAlertsToTargets(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	var objects []client.Object
+	var scas []*v1alpha1.ScyllaClusterAutoscaler
+	for _, name := range []string{"sc-a", "sc-b"} {
+		sc := newSingleDcSc(name, "sc-ns", dcName,
+			[]scyllav1.RackSpec{*getRackSpec(rackName, 3, "5", "5", "1Gi", "1Gi")},
+			map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)})
+		sca := newSingleDcSca(name+"-sca", "sca-ns", sc.Name, sc.Namespace, dcName,
+			newRackScalingPolicy(rackName,
+				[]v1alpha1.ScalingRule{
+					*newScalingRule("rule_name", 1, `ScyllaOverload`, nil, nil, v1alpha1.ScalingModeHorizontal, 2),
+				},
+				1, 100, resource.MustParse("1"), resource.MustParse("100"),
+				v1alpha1.RackControlledValuesRequestsAndLimits))
+		setMetricsSource(&v1alpha1.MetricsSource{Type: v1alpha1.MetricsSourceTypeAlertmanager}, sca)
+		objects = append(append(objects, getStatefulSets(sc)...), sc, sca)
+		scas = append(scas, sca)
+	}
+
+	receiver, err := metrics.NewAlertReceiver(logger, "token")
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/alerts", strings.NewReader(`{"alerts":[
+		{"status":"firing","labels":{"alertname":"ScyllaOverload","namespace":"sc-ns","cluster":"sc-a"},"fingerprint":"a"}
+	]}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	pp := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), nil), logger, time.Minute)
+	r := New(c, pp, &metrics.Factory{Client: c, AlertReceiver: receiver, Logger: logger, DefaultStep: time.Minute}, Options{}, logger)
+
+	// Only the SCA of the alert's cluster is triggered by it.
+	require.NoError(t, r.RunForAlerts(ctx, receiver.PopFired()))
+	res := &v1alpha1.ScyllaClusterAutoscaler{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: scas[0].Namespace, Name: scas[0].Name}, res))
+	require.Equal(t, v1alpha1.UpdateStatusOk, *res.Status.UpdateStatus)
+	require.NotNil(t, res.Status.Recommendations)
+	require.Equal(t, int32(6), *res.Status.Recommendations.DatacenterRecommendations[0].RackRecommendations[0].Members)
+	res = &v1alpha1.ScyllaClusterAutoscaler{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: scas[1].Namespace, Name: scas[1].Name}, res))
+	require.Nil(t, res.Status.UpdateStatus)
+
+	// When evaluated, the SCA of the other cluster isn't affected by the alert.
+	require.NoError(t, r.RunOnce(ctx))
+	res = &v1alpha1.ScyllaClusterAutoscaler{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: scas[1].Namespace, Name: scas[1].Name}, res))
+	require.Equal(t, v1alpha1.UpdateStatusOk, *res.Status.UpdateStatus)
+	require.Nil(t, res.Status.Recommendations)
+}
+
+func TestRunOnceSuspendsEvaluationOfUnhealthyMetricsSource(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+
+	sc := newSingleDcSc("test-sc", "test-sc-ns", dcName,
+		[]scyllav1.RackSpec{*getRackSpec(rackName, 3, "5", "5", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)})
+	sca := newSingleDcSca("test-sca", "test-sca-ns", sc.Name, sc.Namespace, dcName,
+		newRackScalingPolicy(rackName,
+			[]v1alpha1.ScalingRule{
+				*newScalingRule("rule_name", 1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 2),
+			},
+			1, 100, resource.MustParse("1"), resource.MustParse("100"),
+			v1alpha1.RackControlledValuesRequestsAndLimits))
+	previousStatus := v1alpha1.UpdateStatusOk
+	sca.Status.UpdateStatus = &previousStatus
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(getStatefulSets(sc), sc, sca)...).Build()
+	failing := mockprometheusapi.NewMockApi(
+		func(string, time.Time) (model.Value, v1.Warnings, error) {
+			return nil, nil, &v1.Error{Type: v1.ErrServer, Msg: "server error: 503"}
+		}, nil)
+	pp := metrics.NewResilientProvider(metrics.NewPrometheusProvider(failing, logger, time.Minute), metrics.QueryPolicy{
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+	}, logger)
+	r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{Workers: 2, QueryWorkers: 4}, logger)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, r.RunOnce(ctx))
+
+		res := &v1alpha1.ScyllaClusterAutoscaler{}
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+		require.Equal(t, previousStatus, *res.Status.UpdateStatus)
+		require.True(t, meta.IsStatusConditionFalse(res.Status.Conditions, v1alpha1.MetricsSourceHealthyCondition))
+	}
+}
+
+func TestRunOnceHonorsPauseAnnotations(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, _ := log.NewProduction(log.Config{
+		Level: atom,
+	})
+	previousRecommendations := &v1alpha1.ScyllaClusterRecommendations{
+		DatacenterRecommendations: []v1alpha1.DatacenterRecommendations{
+			{
+				Name: dcName,
+				RackRecommendations: []v1alpha1.RackRecommendations{
+					{Name: rackName, Members: util.Int32ptr(5)},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		scAnnotations       map[string]string
+		scaAnnotations      map[string]string
+		expectedMembers     *int32
+		expectedPausedRacks []string
+		expectedPaused      bool
+	}{
+		{
+			name:            "SCA paused",
+			scaAnnotations:  map[string]string{v1alpha1.PausedAnnotation: "true"},
+			expectedMembers: util.Int32ptr(5),
+			expectedPaused:  true,
+		},
+		{
+			name:            "cluster paused until later",
+			scAnnotations:   map[string]string{v1alpha1.PausedAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339)},
+			expectedMembers: util.Int32ptr(5),
+			expectedPaused:  true,
+		},
+		{
+			name:                "rack paused",
+			scAnnotations:       map[string]string{v1alpha1.PausedRacksAnnotation: rackName},
+			expectedPausedRacks: []string{rackName},
+		},
+		{
+			name:            "pause expired",
+			scaAnnotations:  map[string]string{v1alpha1.PausedAnnotation: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+			expectedMembers: util.Int32ptr(6),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc := newSingleDcSc("test-sc", "test-sc-ns", dcName,
+				[]scyllav1.RackSpec{*getRackSpec(rackName, 3, "5", "5", "1Gi", "1Gi")},
+				map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)})
+			sc.Annotations = test.scAnnotations
+			sca := newSingleDcSca("test-sca", "test-sca-ns", sc.Name, sc.Namespace, dcName,
+				newRackScalingPolicy(rackName,
+					[]v1alpha1.ScalingRule{
+						*newScalingRule("rule_name", 1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 2),
+					},
+					1, 100, resource.MustParse("1"), resource.MustParse("100"),
+					v1alpha1.RackControlledValuesRequestsAndLimits))
+			sca.Annotations = test.scaAnnotations
+			sca.Status.Recommendations = previousRecommendations
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(getStatefulSets(sc), sc, sca)...).Build()
+			m := mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), mockprometheusapi.SimpleRangedQueryFunction())
+			pp := metrics.NewPrometheusProvider(m, logger, time.Minute)
+			r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{Workers: 2, QueryWorkers: 4}, logger)
+			require.NoError(t, r.RunOnce(ctx))
+
+			res := &v1alpha1.ScyllaClusterAutoscaler{}
+			require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+			if test.expectedMembers == nil {
+				require.Nil(t, res.Status.Recommendations)
+			} else {
+				require.NotNil(t, res.Status.Recommendations)
+				require.Equal(t, test.expectedMembers, res.Status.Recommendations.DatacenterRecommendations[0].RackRecommendations[0].Members)
+			}
+			require.Equal(t, test.expectedPausedRacks, res.Status.PausedRacks)
+			require.Equal(t, test.expectedPaused, meta.IsStatusConditionTrue(res.Status.Conditions, v1alpha1.PausedCondition))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package recommender
+
+import (
+	"sync"
+	"time"
+)
+
+// resultCache keeps the results of the racks' evaluations which are too expensive to be repeated every time
+// the racks are evaluated, e.g. forecasts fitted to weeks of history, until they expire.
+// Keys have to be comparable, and should be of a type distinct for every kind of result.
+type resultCache struct {
+	mu      sync.Mutex
+	results map[interface{}]cachedResult
+	used    map[interface{}]struct{}
+}
+
+type cachedResult struct {
+	value   interface{}
+	expires time.Time
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		results: make(map[interface{}]cachedResult),
+		used:    make(map[interface{}]struct{}),
+	}
+}
+
+// get returns the result stored under the key, unless it expired by now.
+func (rc *resultCache) get(key interface{}, now time.Time) (interface{}, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	res, ok := rc.results[key]
+	if !ok || !now.Before(res.expires) {
+		return nil, false
+	}
+	rc.used[key] = struct{}{}
+	return res.value, true
+}
+
+// put stores the result under the key until it expires.
+func (rc *resultCache) put(key, value interface{}, expires time.Time) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.results[key] = cachedResult{value: value, expires: expires}
+	rc.used[key] = struct{}{}
+}
+
+// prune drops the results which were not requested since the previous call, e.g. of deleted SCAs or removed racks.
+func (rc *resultCache) prune() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for key := range rc.results {
+		if _, ok := rc.used[key]; !ok {
+			delete(rc.results, key)
+		}
+	}
+	rc.used = make(map[interface{}]struct{})
+}
@@ -0,0 +1,272 @@
+package recommender
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultRightsizingWindow              = 8 * 24 * time.Hour
+	defaultRightsizingStep                = 5 * time.Minute
+	defaultRightsizingCpuPercentile       = 90
+	defaultRightsizingMemoryPercentile    = 95
+	defaultRightsizingSafetyMarginPercent = 15
+	defaultRightsizingTolerancePercent    = 10
+
+	scyllaContainerSelector = `namespace="{{ .Namespace }}", pod=~"{{ .Cluster }}-{{ .Datacenter }}-{{ .Rack }}-[0-9]+", container="scylla"`
+
+	// DefaultRightsizingCpuExpression is the CPU usage of the busiest member's Scylla container, in cores.
+	DefaultRightsizingCpuExpression = `max(rate(container_cpu_usage_seconds_total{` + scyllaContainerSelector + `}[5m]))`
+
+	// DefaultRightsizingMemoryExpression is the working set of the busiest member's Scylla container, in bytes.
+	DefaultRightsizingMemoryExpression = `max(container_memory_working_set_bytes{` + scyllaContainerSelector + `})`
+)
+
+// rightsizingSettings are the settings of rightsizing with the defaults applied.
+type rightsizingSettings struct {
+	window, step                    time.Duration
+	cpuPercentile, memoryPercentile float64
+	safetyMargin, tolerance         float64
+	cpuExpression, memoryExpression string
+}
+
+func newRightsizingSettings(rightsizing *v1alpha1.RackRightsizing) rightsizingSettings {
+	s := rightsizingSettings{
+		window:           defaultRightsizingWindow,
+		step:             defaultRightsizingStep,
+		cpuPercentile:    defaultRightsizingCpuPercentile,
+		memoryPercentile: defaultRightsizingMemoryPercentile,
+		safetyMargin:     defaultRightsizingSafetyMarginPercent,
+		tolerance:        defaultRightsizingTolerancePercent,
+		cpuExpression:    DefaultRightsizingCpuExpression,
+		memoryExpression: DefaultRightsizingMemoryExpression,
+	}
+	for _, d := range []struct {
+		value *metav1.Duration
+		dest  *time.Duration
+	}{
+		{rightsizing.Window, &s.window},
+		{rightsizing.Step, &s.step},
+	} {
+		if d.value != nil {
+			*d.dest = d.value.Duration
+		}
+	}
+	for _, p := range []struct {
+		value *int32
+		dest  *float64
+	}{
+		{rightsizing.CpuPercentile, &s.cpuPercentile},
+		{rightsizing.MemoryPercentile, &s.memoryPercentile},
+		{rightsizing.SafetyMarginPercent, &s.safetyMargin},
+		{rightsizing.TolerancePercent, &s.tolerance},
+	} {
+		if p.value != nil {
+			*p.dest = float64(*p.value)
+		}
+	}
+	if rightsizing.CpuExpression != "" {
+		s.cpuExpression = rightsizing.CpuExpression
+	}
+	if rightsizing.MemoryExpression != "" {
+		s.memoryExpression = rightsizing.MemoryExpression
+	}
+	return s
+}
+
+func validateRightsizing(rightsizing *v1alpha1.RackRightsizing) error {
+	if rightsizing == nil {
+		return nil
+	}
+
+	s := newRightsizingSettings(rightsizing)
+	if s.step <= 0 {
+		return errors.New("step has to be positive")
+	}
+	if s.window < s.step {
+		return errors.New("window can't be shorter than step")
+	}
+	for _, p := range []float64{s.cpuPercentile, s.memoryPercentile} {
+		if p < 1 || p > 100 {
+			return errors.New("percentiles have to be between 1 and 100")
+		}
+	}
+	if s.safetyMargin < 0 || s.tolerance < 0 {
+		return errors.New("safety margin and tolerance can't be negative")
+	}
+	if _, err := renderExpression(s.cpuExpression, ExpressionData{}); err != nil {
+		return errors.Wrap(err, "cpu expression")
+	}
+	if _, err := renderExpression(s.memoryExpression, ExpressionData{}); err != nil {
+		return errors.Wrap(err, "memory expression")
+	}
+
+	return nil
+}
+
+// rightsize sets the CPU and memory requests of resources from the percentiles of the rack's usage,
+// within the bounds of the resource policy. Limits are kept proportional to the requests, unless only
+// the requests are controlled. It reports whether any of the resources changed.
+func (r *recommender) rightsize(ctx context.Context, provider metrics.Provider, rightsizing *v1alpha1.RackRightsizing,
+	resourcePolicy *v1alpha1.RackResourcePolicy, resources *corev1.ResourceRequirements) (bool, error) {
+	s := newRightsizingSettings(rightsizing)
+	controlledValues := v1alpha1.RackControlledValuesRequestsAndLimits
+	var minCpu, maxCpu, minMemory, maxMemory *resource.Quantity
+	if resourcePolicy != nil {
+		minCpu, maxCpu = resourcePolicy.MinAllowedCpu, resourcePolicy.MaxAllowedCpu
+		minMemory, maxMemory = resourcePolicy.MinAllowedMemory, resourcePolicy.MaxAllowedMemory
+		if resourcePolicy.RackControlledValues != "" {
+			controlledValues = resourcePolicy.RackControlledValues
+		}
+	}
+
+	changed := false
+	for _, res := range []struct {
+		name       corev1.ResourceName
+		expression string
+		percentile float64
+		min, max   *resource.Quantity
+		quantity   func(value float64) resource.Quantity
+	}{
+		{corev1.ResourceCPU, s.cpuExpression, s.cpuPercentile, minCpu, maxCpu, cpuQuantity},
+		{corev1.ResourceMemory, s.memoryExpression, s.memoryPercentile, minMemory, maxMemory, memoryQuantity},
+	} {
+		usage, err := r.usagePercentile(ctx, provider, res.expression, res.percentile, s, time.Now())
+		if err != nil {
+			return false, errors.Wrapf(err, "%s usage", res.name)
+		}
+
+		sized := res.quantity(usage * (1 + s.safetyMargin/100))
+		if res.max != nil {
+			sized = util.MinQuantity(sized, *res.max)
+		}
+		if res.min != nil {
+			sized = util.MaxQuantity(sized, *res.min)
+		}
+
+		if resizeRequests(resources, res.name, sized, res.quantity, s.tolerance, controlledValues) {
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// usageKey identifies the percentile of a rack's usage in the results cache.
+type usageKey struct {
+	rack         string
+	expression   string
+	window, step time.Duration
+	percentile   float64
+}
+
+// usagePercentile returns the percentile of the values of the usage expression, rendered for the query target
+// carried by ctx. The usage is queried at most once per step, since its history gains at most one point
+// in the meantime; until then the cached percentile is returned.
+func (r *recommender) usagePercentile(ctx context.Context, provider metrics.Provider, expression string, p float64,
+	s rightsizingSettings, now time.Time) (float64, error) {
+	target, _ := metrics.TargetFromContext(ctx)
+	expression, err := renderExpression(expression, newExpressionData(target))
+	if err != nil {
+		return 0, err
+	}
+
+	var rack string
+	if target.Rack != nil {
+		rack = rackKey(target.Cluster, target.Rack.Name)
+	}
+	key := usageKey{rack: rack, expression: expression, window: s.window, step: s.step, percentile: p}
+	if cached, ok := r.results.get(key, now); ok {
+		return cached.(float64), nil
+	}
+
+	usage, err := r.queryUsage(ctx, provider, expression, s.window, s.step)
+	if err != nil {
+		return 0, err
+	}
+	value := percentile(usage, p)
+	r.results.put(key, value, now.Add(s.step))
+	return value, nil
+}
+
+// queryUsage queries the values of the rendered usage expression, holding a query slot.
+func (r *recommender) queryUsage(ctx context.Context, provider metrics.Provider, expression string, window, step time.Duration) ([]float64, error) {
+	r.querySlots <- struct{}{}
+	points, err := metrics.QuerySeries(ctx, provider, expression, window, step)
+	<-r.querySlots
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, errors.New("no usage history")
+	}
+
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		values = append(values, p.Value)
+	}
+	return values, nil
+}
+
+// resizeRequests sets the requests of the resource to sized, unless they differ by no more than tolerance percent.
+// Limits are scaled by the same factor, rounded with quantity, if they're controlled, and requests are capped by them
+// otherwise. It reports whether the requests changed.
+func resizeRequests(resources *corev1.ResourceRequirements, name corev1.ResourceName, sized resource.Quantity,
+	quantity func(value float64) resource.Quantity, tolerance float64, controlledValues v1alpha1.RackControlledValues) bool {
+	current, ok := resources.Requests[name]
+	if ok && !current.IsZero() {
+		diff := math.Abs(float64(sized.MilliValue()-current.MilliValue())) / float64(current.MilliValue())
+		if diff*100 <= tolerance {
+			return false
+		}
+	}
+
+	limit, limited := resources.Limits[name]
+	if limited {
+		if controlledValues == v1alpha1.RackControlledValuesRequestsAndLimits && ok && !current.IsZero() {
+			factor := float64(limit.MilliValue()) / float64(current.MilliValue())
+			resources.Limits[name] = quantity(factor * float64(sized.MilliValue()) / 1000)
+		} else {
+			sized = util.MinQuantity(sized, limit)
+		}
+	}
+
+	if resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	resources.Requests[name] = sized
+	return !ok || !current.Equal(sized)
+}
+
+// cpuQuantity returns the CPU quantity of the given number of cores, rounded up to millicores.
+func cpuQuantity(cores float64) resource.Quantity {
+	return *resource.NewMilliQuantity(int64(math.Ceil(cores*1000)), resource.DecimalSI)
+}
+
+// memoryQuantity returns the memory quantity of the given number of bytes, rounded up to mebibytes.
+func memoryQuantity(bytes float64) resource.Quantity {
+	const mebibyte = 1 << 20
+	return *resource.NewQuantity(int64(math.Ceil(bytes/mebibyte))*mebibyte, resource.BinarySI)
+}
+
+// percentile returns the p-th percentile of the values, by the nearest-rank method.
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,289 @@
+package recommender
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	mockprometheusapi "github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+
+	require.Equal(t, 9.0, percentile(values, 90))
+	require.Equal(t, 10.0, percentile(values, 95))
+	require.Equal(t, 5.0, percentile(values, 50))
+	require.Equal(t, 1.0, percentile(values, 1))
+	require.Equal(t, []float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}, values, "values are not modified")
+}
+
+func TestResizeRequests(t *testing.T) {
+	tests := []struct {
+		name             string
+		resources        corev1.ResourceRequirements
+		sized            string
+		controlledValues v1alpha1.RackControlledValues
+		expected         corev1.ResourceRequirements
+		expectedChanged  bool
+	}{
+		{
+			name: "limits kept proportional",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			sized:            "3",
+			controlledValues: v1alpha1.RackControlledValuesRequestsAndLimits,
+			expected: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("6")},
+			},
+			expectedChanged: true,
+		},
+		{
+			name: "requests capped by uncontrolled limits",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			sized:            "5",
+			controlledValues: v1alpha1.RackControlledValuesRequests,
+			expected: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			expectedChanged: true,
+		},
+		{
+			name: "change within tolerance",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+			sized:            "2100m",
+			controlledValues: v1alpha1.RackControlledValuesRequestsAndLimits,
+			expected: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+		},
+		{
+			name:             "no requests",
+			sized:            "1",
+			controlledValues: v1alpha1.RackControlledValuesRequestsAndLimits,
+			expected: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			},
+			expectedChanged: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := resizeRequests(&test.resources, corev1.ResourceCPU, resource.MustParse(test.sized), cpuQuantity, 10, test.controlledValues)
+			require.Equal(t, test.expectedChanged, changed)
+			for _, list := range []struct{ expected, actual corev1.ResourceList }{
+				{test.expected.Requests, test.resources.Requests},
+				{test.expected.Limits, test.resources.Limits},
+			} {
+				require.Len(t, list.actual, len(list.expected))
+				for name, quantity := range list.expected {
+					actual := list.actual[name]
+					require.Zero(t, quantity.Cmp(actual), "expected %s, got %s", quantity.String(), actual.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunOnceRightsizesRacks(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+		mebibyte = 1 << 20
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	// CPU usage of 0.1 to 2 cores and memory usage of 100Mi to 2000Mi, so that the 90th percentile of CPU is 1.8 cores
+	// and the 95th percentile of memory is 1900Mi.
+	usage := func(unit float64) model.Matrix {
+		s := &model.SampleStream{}
+		now := time.Now()
+		for i := 1; i <= 20; i++ {
+			s.Values = append(s.Values, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(now.Add(time.Duration(i-20) * time.Hour).UnixNano()),
+				Value:     model.SampleValue(float64(i) * unit),
+			})
+		}
+		return model.Matrix{s}
+	}
+
+	tests := []struct {
+		name                  string
+		cpu, memory           string
+		controlledValues      v1alpha1.RackControlledValues
+		maxAllowedCpu         string
+		maxAllowedMemory      *resource.Quantity
+		rules                 []v1alpha1.ScalingRule
+		expectedRequests      corev1.ResourceList
+		expectedLimits        corev1.ResourceList
+		expectedNoRecommended bool
+	}{
+		{
+			name:             "sized for the percentiles with safety margin",
+			cpu:              "5",
+			memory:           "1Gi",
+			controlledValues: v1alpha1.RackControlledValuesRequestsAndLimits,
+			maxAllowedCpu:    "100",
+			expectedRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2070m"), corev1.ResourceMemory: resource.MustParse("2185Mi")},
+			expectedLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2070m"), corev1.ResourceMemory: resource.MustParse("2185Mi")},
+		},
+		{
+			name:             "sized within the resource policy",
+			cpu:              "5",
+			memory:           "1Gi",
+			controlledValues: v1alpha1.RackControlledValuesRequestsAndLimits,
+			maxAllowedCpu:    "2",
+			maxAllowedMemory: resource.NewQuantity(2048*mebibyte, resource.BinarySI),
+			expectedRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+			expectedLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+		},
+		{
+			name:             "only requests controlled",
+			cpu:              "5",
+			memory:           "1Gi",
+			controlledValues: v1alpha1.RackControlledValuesRequests,
+			maxAllowedCpu:    "100",
+			expectedRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2070m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			expectedLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("5"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+		{
+			name:             "vertical rule scales the rightsized cpu",
+			cpu:              "5",
+			memory:           "1Gi",
+			controlledValues: v1alpha1.RackControlledValuesRequestsAndLimits,
+			maxAllowedCpu:    "100",
+			rules: []v1alpha1.ScalingRule{
+				*newScalingRule("rule", 1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, 2),
+			},
+			expectedRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4140m"), corev1.ResourceMemory: resource.MustParse("2185Mi")},
+			expectedLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4140m"), corev1.ResourceMemory: resource.MustParse("2185Mi")},
+		},
+		{
+			name:                  "sized requests within tolerance",
+			cpu:                   "2",
+			memory:                "2185Mi",
+			controlledValues:      v1alpha1.RackControlledValuesRequestsAndLimits,
+			maxAllowedCpu:         "100",
+			expectedNoRecommended: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc := newSingleDcSc("test-sc", "test-sc-ns", dcName,
+				[]scyllav1.RackSpec{*getRackSpec(rackName, 3, test.cpu, test.cpu, test.memory, test.memory)},
+				map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)})
+			rackPolicy := newRackScalingPolicy(rackName, test.rules, 1, 100, resource.MustParse("1"), resource.MustParse(test.maxAllowedCpu), test.controlledValues)
+			rackPolicy.ResourcePolicy.MaxAllowedMemory = test.maxAllowedMemory
+			rackPolicy.Rightsizing = &v1alpha1.RackRightsizing{}
+			sca := newSingleDcSca("test-sca", "test-sca-ns", sc.Name, sc.Namespace, dcName, rackPolicy)
+
+			var queries []string
+			qr := func(query string, r v1.Range) (model.Value, v1.Warnings, error) {
+				queries = append(queries, query)
+				if strings.Contains(query, "cpu") {
+					return usage(0.1), nil, nil
+				}
+				return usage(100 * mebibyte), nil, nil
+			}
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(getStatefulSets(sc), sc, sca)...).Build()
+			pp := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), qr), logger, time.Minute)
+			r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{}, logger)
+			require.NoError(t, r.RunOnce(ctx))
+
+			require.Len(t, queries, 2)
+			for _, query := range queries {
+				require.Contains(t, query, `namespace="test-sc-ns", pod=~"test-sc-dc_name-rack_name-[0-9]+", container="scylla"`)
+			}
+
+			res := &v1alpha1.ScyllaClusterAutoscaler{}
+			require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+			require.Equal(t, v1alpha1.UpdateStatusOk, *res.Status.UpdateStatus)
+			if test.expectedNoRecommended {
+				require.Nil(t, res.Status.Recommendations)
+				return
+			}
+
+			require.NotNil(t, res.Status.Recommendations)
+			rec := res.Status.Recommendations.DatacenterRecommendations[0].RackRecommendations[0]
+			require.Equal(t, int32(3), *rec.Members)
+			for _, list := range []struct{ expected, actual corev1.ResourceList }{
+				{test.expectedRequests, rec.Resources.Requests},
+				{test.expectedLimits, rec.Resources.Limits},
+			} {
+				for name, quantity := range list.expected {
+					actual := list.actual[name]
+					require.Zero(t, quantity.Cmp(actual), "%s: expected %s, got %s", name, quantity.String(), actual.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUsageIsQueriedOncePerStep(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	now := time.Now()
+	queries := 0
+	qr := func(query string, r v1.Range) (model.Value, v1.Warnings, error) {
+		queries++
+		s := &model.SampleStream{}
+		for i := 1; i <= 10; i++ {
+			s.Values = append(s.Values, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(now.Add(time.Duration(i-10) * time.Hour).UnixNano()),
+				Value:     model.SampleValue(i),
+			})
+		}
+		return model.Matrix{s}, nil, nil
+	}
+	pp := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(nil, qr), logger, time.Minute)
+	r := New(nil, pp, nil, Options{}, logger).(*recommender)
+
+	sc := newSingleDcSc("test-sc", "test-sc-ns", "dc_name",
+		[]scyllav1.RackSpec{*getRackSpec("rack_name", 3, "5", "5", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{"rack_name": *getRackStatus(3, 3)})
+	ctx = metrics.WithTarget(ctx, metrics.Target{Cluster: sc, Rack: &sc.Spec.Datacenter.Racks[0]})
+	s := newRightsizingSettings(&v1alpha1.RackRightsizing{})
+
+	for _, test := range []struct {
+		now             time.Time
+		percentile      float64
+		expected        float64
+		expectedQueries int
+	}{
+		{now: now, percentile: 90, expected: 9, expectedQueries: 1},
+		{now: now.Add(time.Minute), percentile: 90, expected: 9, expectedQueries: 1},
+		{now: now.Add(time.Minute), percentile: 50, expected: 5, expectedQueries: 2},
+		{now: now.Add(s.step), percentile: 90, expected: 9, expectedQueries: 3},
+	} {
+		value, err := r.usagePercentile(ctx, pp, DefaultRightsizingCpuExpression, test.percentile, s, test.now)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, value)
+		require.Equal(t, test.expectedQueries, queries)
+	}
+}
@@ -0,0 +1,174 @@
+package recommender
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+)
+
+// rackPolicy is the effective scaling policy of a rack.
+type rackPolicy struct {
+	rack   *scyllav1.RackSpec
+	policy *v1alpha1.RackScalingPolicy
+}
+
+// effectiveRackPolicies resolves the effective scaling policy of every rack of the datacenter.
+// The default policy (see v1alpha1.RackNameWildcard) applies to every rack; policies of specific racks are merged
+// on top of it. Racks with neither a default nor a specific policy are not autoscaled.
+func effectiveRackPolicies(datacenter *scyllav1.DatacenterSpec, scalingPolicy *v1alpha1.DatacenterScalingPolicy) ([]rackPolicy, error) {
+	var defaultPolicy *v1alpha1.RackScalingPolicy
+	policies := make(map[string]*v1alpha1.RackScalingPolicy, len(scalingPolicy.RackScalingPolicies))
+	for i := range scalingPolicy.RackScalingPolicies {
+		policy := &scalingPolicy.RackScalingPolicies[i]
+		if policy.Name == v1alpha1.RackNameWildcard {
+			defaultPolicy = policy
+			continue
+		}
+
+		found := false
+		for _, rack := range datacenter.Racks {
+			if rack.Name == policy.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("rack \"%s\" not found", policy.Name)
+		}
+		policies[policy.Name] = policy
+	}
+
+	var res []rackPolicy
+	for i := range datacenter.Racks {
+		rack := &datacenter.Racks[i]
+		policy, ok := policies[rack.Name]
+		switch {
+		case ok && defaultPolicy != nil:
+			policy = mergeRackScalingPolicies(defaultPolicy, policy)
+		case defaultPolicy != nil:
+			policy = mergeRackScalingPolicies(defaultPolicy, &v1alpha1.RackScalingPolicy{Name: rack.Name})
+		case !ok:
+			continue
+		}
+		res = append(res, rackPolicy{rack: rack, policy: policy})
+	}
+
+	return res, nil
+}
+
+// mergeRackScalingPolicies merges override on top of base. Fields set in override take precedence,
+// and its rules replace the base rules of the same name, or are appended otherwise.
+// Neither of the policies is modified.
+func mergeRackScalingPolicies(base, override *v1alpha1.RackScalingPolicy) *v1alpha1.RackScalingPolicy {
+	res := &v1alpha1.RackScalingPolicy{Name: override.Name}
+
+	if base.MemberPolicy != nil || override.MemberPolicy != nil {
+		res.MemberPolicy = &v1alpha1.RackMemberPolicy{}
+		if base.MemberPolicy != nil {
+			*res.MemberPolicy = *base.MemberPolicy
+		}
+		if override.MemberPolicy != nil {
+			if override.MemberPolicy.MinAllowed != nil {
+				res.MemberPolicy.MinAllowed = override.MemberPolicy.MinAllowed
+			}
+			if override.MemberPolicy.MaxAllowed != nil {
+				res.MemberPolicy.MaxAllowed = override.MemberPolicy.MaxAllowed
+			}
+		}
+	}
+
+	if base.ResourcePolicy != nil || override.ResourcePolicy != nil {
+		res.ResourcePolicy = &v1alpha1.RackResourcePolicy{}
+		if base.ResourcePolicy != nil {
+			*res.ResourcePolicy = *base.ResourcePolicy
+		}
+		if override.ResourcePolicy != nil {
+			if override.ResourcePolicy.MinAllowedCpu != nil {
+				res.ResourcePolicy.MinAllowedCpu = override.ResourcePolicy.MinAllowedCpu
+			}
+			if override.ResourcePolicy.MaxAllowedCpu != nil {
+				res.ResourcePolicy.MaxAllowedCpu = override.ResourcePolicy.MaxAllowedCpu
+			}
+			if override.ResourcePolicy.MinAllowedMemory != nil {
+				res.ResourcePolicy.MinAllowedMemory = override.ResourcePolicy.MinAllowedMemory
+			}
+			if override.ResourcePolicy.MaxAllowedMemory != nil {
+				res.ResourcePolicy.MaxAllowedMemory = override.ResourcePolicy.MaxAllowedMemory
+			}
+			if override.ResourcePolicy.RackControlledValues != "" {
+				res.ResourcePolicy.RackControlledValues = override.ResourcePolicy.RackControlledValues
+			}
+		}
+	}
+
+	if base.Behavior != nil || override.Behavior != nil {
+		res.Behavior = &v1alpha1.RackScalingBehavior{}
+		if base.Behavior != nil {
+			*res.Behavior = *base.Behavior
+		}
+		if override.Behavior != nil {
+			if override.Behavior.ScaleUp != nil {
+				res.Behavior.ScaleUp = override.Behavior.ScaleUp
+			}
+			if override.Behavior.ScaleDown != nil {
+				res.Behavior.ScaleDown = override.Behavior.ScaleDown
+			}
+		}
+	}
+
+	res.Prediction = base.Prediction
+	if override.Prediction != nil {
+		res.Prediction = override.Prediction
+	}
+
+	res.Rightsizing = base.Rightsizing
+	if override.Rightsizing != nil {
+		res.Rightsizing = override.Rightsizing
+	}
+
+	res.WarmUp = base.WarmUp
+	if override.WarmUp != nil {
+		res.WarmUp = override.WarmUp
+	}
+
+	res.Strategy = base.Strategy
+	if override.Strategy != "" {
+		res.Strategy = override.Strategy
+	}
+
+	res.ScalingRules = make([]v1alpha1.ScalingRule, 0, len(base.ScalingRules)+len(override.ScalingRules))
+	res.ScalingRules = append(res.ScalingRules, base.ScalingRules...)
+	for _, rule := range override.ScalingRules {
+		replaced := false
+		for i := range res.ScalingRules {
+			if res.ScalingRules[i].Name == rule.Name {
+				res.ScalingRules[i] = rule
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			res.ScalingRules = append(res.ScalingRules, rule)
+		}
+	}
+
+	if len(base.Schedules) > 0 || len(override.Schedules) > 0 {
+		res.Schedules = make([]v1alpha1.ScalingSchedule, 0, len(base.Schedules)+len(override.Schedules))
+		res.Schedules = append(res.Schedules, base.Schedules...)
+		for _, schedule := range override.Schedules {
+			replaced := false
+			for i := range res.Schedules {
+				if res.Schedules[i].Name == schedule.Name {
+					res.Schedules[i] = schedule
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				res.Schedules = append(res.Schedules, schedule)
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,144 @@
+package recommender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEffectiveRackPolicies(t *testing.T) {
+	minCpu, maxCpu, overrideMaxCpu := resource.MustParse("1"), resource.MustParse("10"), resource.MustParse("20")
+	overrideMaxMemory := resource.MustParse("64Gi")
+	scaleUp := &v1alpha1.ScalingBehaviorRules{StabilizationWindow: &metav1.Duration{Duration: time.Minute}}
+	scaleDown := &v1alpha1.ScalingBehaviorRules{StabilizationWindow: &metav1.Duration{Duration: 5 * time.Minute}}
+	overrideScaleDown := &v1alpha1.ScalingBehaviorRules{SelectPolicy: v1alpha1.ScalingPolicySelectDisabled}
+	datacenter := &scyllav1.DatacenterSpec{
+		Name: "dc",
+		Racks: []scyllav1.RackSpec{
+			{Name: "rack-a"},
+			{Name: "rack-b"},
+		},
+	}
+	defaultPolicy := v1alpha1.RackScalingPolicy{
+		Name:         v1alpha1.RackNameWildcard,
+		MemberPolicy: &v1alpha1.RackMemberPolicy{MinAllowed: util.Int32ptr(1), MaxAllowed: util.Int32ptr(5)},
+		ResourcePolicy: &v1alpha1.RackResourcePolicy{
+			MinAllowedCpu:        &minCpu,
+			MaxAllowedCpu:        &maxCpu,
+			RackControlledValues: v1alpha1.RackControlledValuesRequests,
+		},
+		ScalingRules: []v1alpha1.ScalingRule{
+			{Name: "up", Priority: 1, Expression: "up", ScalingMode: v1alpha1.ScalingModeHorizontal, ScalingFactor: 2},
+			{Name: "down", Priority: 1, Expression: "down", ScalingMode: v1alpha1.ScalingModeHorizontal, ScalingFactor: 0.5},
+		},
+		Behavior: &v1alpha1.RackScalingBehavior{ScaleUp: scaleUp, ScaleDown: scaleDown},
+		Strategy: v1alpha1.RackScalingStrategyHybrid,
+		Schedules: []v1alpha1.ScalingSchedule{
+			{Name: "weekdays", Schedule: "0 8 * * 1-5", Duration: metav1.Duration{Duration: 12 * time.Hour}, MinAllowed: util.Int32ptr(3)},
+		},
+		Prediction: &v1alpha1.PredictiveScaling{Expression: "load", TargetValue: 70, Mode: v1alpha1.ScalingModeHorizontal},
+		WarmUp:     &metav1.Duration{Duration: 15 * time.Minute},
+	}
+	rackAPolicy := v1alpha1.RackScalingPolicy{
+		Name:           "rack-a",
+		MemberPolicy:   &v1alpha1.RackMemberPolicy{MaxAllowed: util.Int32ptr(10)},
+		ResourcePolicy: &v1alpha1.RackResourcePolicy{MaxAllowedCpu: &overrideMaxCpu, MaxAllowedMemory: &overrideMaxMemory},
+		ScalingRules: []v1alpha1.ScalingRule{
+			{Name: "up", Priority: 1, Expression: "rack-a up", ScalingMode: v1alpha1.ScalingModeHorizontal, ScalingFactor: 3},
+			{Name: "vertical", Priority: 2, Expression: "vertical", ScalingMode: v1alpha1.ScalingModeVertical, ScalingFactor: 2},
+		},
+		Behavior: &v1alpha1.RackScalingBehavior{ScaleDown: overrideScaleDown},
+		Schedules: []v1alpha1.ScalingSchedule{
+			{Name: "weekdays", Schedule: "0 7 * * 1-5", Duration: metav1.Duration{Duration: 13 * time.Hour}, MinAllowed: util.Int32ptr(6)},
+			{Name: "weekends", Schedule: "0 10 * * 0,6", Duration: metav1.Duration{Duration: 8 * time.Hour}, Members: util.Int32ptr(4)},
+		},
+		Rightsizing: &v1alpha1.RackRightsizing{CpuPercentile: util.Int32ptr(95)},
+	}
+
+	tests := []struct {
+		name          string
+		policies      []v1alpha1.RackScalingPolicy
+		expected      map[string]*v1alpha1.RackScalingPolicy
+		errorExpected bool
+	}{
+		{
+			name:     "specific policies only",
+			policies: []v1alpha1.RackScalingPolicy{rackAPolicy},
+			expected: map[string]*v1alpha1.RackScalingPolicy{"rack-a": &rackAPolicy},
+		},
+		{
+			name:     "default policy applies to every rack",
+			policies: []v1alpha1.RackScalingPolicy{defaultPolicy},
+			expected: map[string]*v1alpha1.RackScalingPolicy{
+				"rack-a": {Name: "rack-a", MemberPolicy: defaultPolicy.MemberPolicy, ResourcePolicy: defaultPolicy.ResourcePolicy, ScalingRules: defaultPolicy.ScalingRules, Behavior: defaultPolicy.Behavior, Strategy: defaultPolicy.Strategy, Schedules: defaultPolicy.Schedules, Prediction: defaultPolicy.Prediction, WarmUp: defaultPolicy.WarmUp},
+				"rack-b": {Name: "rack-b", MemberPolicy: defaultPolicy.MemberPolicy, ResourcePolicy: defaultPolicy.ResourcePolicy, ScalingRules: defaultPolicy.ScalingRules, Behavior: defaultPolicy.Behavior, Strategy: defaultPolicy.Strategy, Schedules: defaultPolicy.Schedules, Prediction: defaultPolicy.Prediction, WarmUp: defaultPolicy.WarmUp},
+			},
+		},
+		{
+			name:     "specific policy is merged on top of the default one",
+			policies: []v1alpha1.RackScalingPolicy{rackAPolicy, defaultPolicy},
+			expected: map[string]*v1alpha1.RackScalingPolicy{
+				"rack-a": {
+					Name:         "rack-a",
+					MemberPolicy: &v1alpha1.RackMemberPolicy{MinAllowed: util.Int32ptr(1), MaxAllowed: util.Int32ptr(10)},
+					ResourcePolicy: &v1alpha1.RackResourcePolicy{
+						MinAllowedCpu:        &minCpu,
+						MaxAllowedCpu:        &overrideMaxCpu,
+						MaxAllowedMemory:     &overrideMaxMemory,
+						RackControlledValues: v1alpha1.RackControlledValuesRequests,
+					},
+					ScalingRules: []v1alpha1.ScalingRule{
+						rackAPolicy.ScalingRules[0],
+						defaultPolicy.ScalingRules[1],
+						rackAPolicy.ScalingRules[1],
+					},
+					Behavior: &v1alpha1.RackScalingBehavior{ScaleUp: scaleUp, ScaleDown: overrideScaleDown},
+					Strategy: v1alpha1.RackScalingStrategyHybrid,
+					Schedules: []v1alpha1.ScalingSchedule{
+						rackAPolicy.Schedules[0],
+						rackAPolicy.Schedules[1],
+					},
+					Prediction:  defaultPolicy.Prediction,
+					Rightsizing: rackAPolicy.Rightsizing,
+					WarmUp:      defaultPolicy.WarmUp,
+				},
+				"rack-b": {Name: "rack-b", MemberPolicy: defaultPolicy.MemberPolicy, ResourcePolicy: defaultPolicy.ResourcePolicy, ScalingRules: defaultPolicy.ScalingRules, Behavior: defaultPolicy.Behavior, Strategy: defaultPolicy.Strategy, Schedules: defaultPolicy.Schedules, Prediction: defaultPolicy.Prediction, WarmUp: defaultPolicy.WarmUp},
+			},
+		},
+		{
+			name:          "policy of a nonexistent rack",
+			policies:      []v1alpha1.RackScalingPolicy{defaultPolicy, {Name: "rack-c"}},
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := effectiveRackPolicies(datacenter, &v1alpha1.DatacenterScalingPolicy{Name: "dc", RackScalingPolicies: test.policies})
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			policies := make(map[string]*v1alpha1.RackScalingPolicy, len(res))
+			for _, rp := range res {
+				require.Equal(t, rp.rack.Name, rp.policy.Name)
+				policies[rp.rack.Name] = rp.policy
+			}
+			require.Equal(t, test.expected, policies)
+		})
+	}
+
+	require.Equal(t, "up", defaultPolicy.ScalingRules[0].Expression, "default policy is not modified")
+	require.Equal(t, int32(1), *defaultPolicy.MemberPolicy.MinAllowed, "default policy is not modified")
+	require.Equal(t, int32(5), *defaultPolicy.MemberPolicy.MaxAllowed, "default policy is not modified")
+	require.Equal(t, scaleDown, defaultPolicy.Behavior.ScaleDown, "default policy is not modified")
+	require.Equal(t, "0 8 * * 1-5", defaultPolicy.Schedules[0].Schedule, "default policy is not modified")
+}
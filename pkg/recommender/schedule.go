@@ -0,0 +1,127 @@
+package recommender
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// scheduledTargets are the targets set by the rack's active schedules.
+type scheduledTargets struct {
+	members *int32
+	cpu     *resource.Quantity
+}
+
+// scheduledMembers returns the scheduled number of members, or the current one if there is no target,
+// clamped by min and max.
+func (t *scheduledTargets) scheduledMembers(current int32, min, max *int32) int32 {
+	val := current
+	if t.members != nil {
+		val = *t.members
+	}
+
+	if max != nil {
+		val = util.MinInt32(val, *max)
+	}
+
+	if min != nil {
+		val = util.MaxInt32(val, *min)
+	}
+
+	return val
+}
+
+// scheduledCPU returns the scheduled CPU, or the current one if there is no target, clamped by min and max.
+func (t *scheduledTargets) scheduledCPU(current *resource.Quantity, min, max *resource.Quantity) resource.Quantity {
+	val := current.DeepCopy()
+	if t.cpu != nil {
+		val = t.cpu.DeepCopy()
+	}
+
+	if max != nil {
+		val = util.MinQuantity(val, *max)
+	}
+
+	if min != nil {
+		val = util.MaxQuantity(val, *min)
+	}
+
+	return val
+}
+
+// scheduleActive tells whether now falls within a window of the schedule,
+// i.e. whether the schedule started a window less than its duration ago.
+func scheduleActive(s *v1alpha1.ScalingSchedule, now time.Time) (bool, error) {
+	_, active, err := util.CronWindowEnd(s.Schedule, s.TimeZone, s.Duration.Duration, now)
+	return active, err
+}
+
+// applySchedules returns the policy with the bounds overridden by the active schedules, along with their targets.
+// Later schedules take precedence over the earlier ones. If no schedule is active, the policy is returned as is
+// and the targets are nil.
+func applySchedules(scalingPolicy *v1alpha1.RackScalingPolicy, now time.Time) (*v1alpha1.RackScalingPolicy, *scheduledTargets, error) {
+	var targets *scheduledTargets
+	res := scalingPolicy
+	for i := range scalingPolicy.Schedules {
+		s := &scalingPolicy.Schedules[i]
+		active, err := scheduleActive(s, now)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "schedule \"%s\"", s.Name)
+		}
+		if !active {
+			continue
+		}
+
+		if targets == nil {
+			targets = &scheduledTargets{}
+			policy := *scalingPolicy
+			policy.MemberPolicy = &v1alpha1.RackMemberPolicy{}
+			if scalingPolicy.MemberPolicy != nil {
+				*policy.MemberPolicy = *scalingPolicy.MemberPolicy
+			}
+			policy.ResourcePolicy = &v1alpha1.RackResourcePolicy{}
+			if scalingPolicy.ResourcePolicy != nil {
+				*policy.ResourcePolicy = *scalingPolicy.ResourcePolicy
+			}
+			res = &policy
+		}
+
+		if s.Members != nil {
+			targets.members = s.Members
+		}
+		if s.Cpu != nil {
+			targets.cpu = s.Cpu
+		}
+		if s.MinAllowed != nil {
+			res.MemberPolicy.MinAllowed = s.MinAllowed
+		}
+		if s.MaxAllowed != nil {
+			res.MemberPolicy.MaxAllowed = s.MaxAllowed
+		}
+		if s.MinAllowedCpu != nil {
+			res.ResourcePolicy.MinAllowedCpu = s.MinAllowedCpu
+		}
+		if s.MaxAllowedCpu != nil {
+			res.ResourcePolicy.MaxAllowedCpu = s.MaxAllowedCpu
+		}
+	}
+
+	return res, targets, nil
+}
+
+func validateSchedule(s *v1alpha1.ScalingSchedule) error {
+	if _, _, err := util.ParseCronWindow(s.Schedule, s.TimeZone); err != nil {
+		return err
+	}
+	if s.Duration.Duration <= 0 {
+		return errors.New("duration has to be positive")
+	}
+	if s.Members == nil && s.Cpu == nil && s.MinAllowed == nil && s.MaxAllowed == nil &&
+		s.MinAllowedCpu == nil && s.MaxAllowedCpu == nil {
+		return errors.New("at least one of members, cpu and the bounds has to be specified")
+	}
+	return nil
+}
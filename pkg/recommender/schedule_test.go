@@ -0,0 +1,238 @@
+package recommender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	mockprometheusapi "github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestScheduleActive(t *testing.T) {
+	weekdays := &v1alpha1.ScalingSchedule{
+		Name:     "weekdays",
+		Schedule: "0 8 * * 1-5",
+		Duration: metav1.Duration{Duration: 12 * time.Hour},
+		TimeZone: "Europe/Warsaw",
+	}
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		schedule *v1alpha1.ScalingSchedule
+		now      time.Time
+		expected bool
+	}{
+		{
+			name:     "window start",
+			schedule: weekdays,
+			now:      time.Date(2021, 6, 7, 8, 0, 0, 0, warsaw),
+			expected: true,
+		},
+		{
+			name:     "within window",
+			schedule: weekdays,
+			now:      time.Date(2021, 6, 11, 19, 59, 0, 0, warsaw),
+			expected: true,
+		},
+		{
+			name:     "window end",
+			schedule: weekdays,
+			now:      time.Date(2021, 6, 7, 20, 0, 0, 0, warsaw),
+			expected: false,
+		},
+		{
+			name:     "before window",
+			schedule: weekdays,
+			now:      time.Date(2021, 6, 7, 7, 59, 0, 0, warsaw),
+			expected: false,
+		},
+		{
+			name:     "weekend",
+			schedule: weekdays,
+			now:      time.Date(2021, 6, 12, 12, 0, 0, 0, warsaw),
+			expected: false,
+		},
+		{
+			name:     "time zone",
+			schedule: weekdays,
+			now:      time.Date(2021, 6, 7, 6, 30, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name: "window spanning midnight",
+			schedule: &v1alpha1.ScalingSchedule{
+				Name:     "nightly",
+				Schedule: "0 22 * * *",
+				Duration: metav1.Duration{Duration: 4 * time.Hour},
+			},
+			now:      time.Date(2021, 6, 8, 1, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := scheduleActive(test.schedule, test.now)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestApplySchedules(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	always := func(name string) v1alpha1.ScalingSchedule {
+		return v1alpha1.ScalingSchedule{Name: name, Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}}
+	}
+	never := v1alpha1.ScalingSchedule{Name: "never", Schedule: "0 0 1 1 *", Duration: metav1.Duration{Duration: time.Hour}, Members: util.Int32ptr(100)}
+	cpu := resource.MustParse("4")
+
+	first := always("first")
+	first.MinAllowed = util.Int32ptr(6)
+	first.Members = util.Int32ptr(8)
+	second := always("second")
+	second.Members = util.Int32ptr(10)
+	second.Cpu = &cpu
+	second.MaxAllowedCpu = &cpu
+
+	policy := &v1alpha1.RackScalingPolicy{
+		Name:         "rack",
+		MemberPolicy: &v1alpha1.RackMemberPolicy{MinAllowed: util.Int32ptr(1), MaxAllowed: util.Int32ptr(20)},
+		Schedules:    []v1alpha1.ScalingSchedule{first, never, second},
+	}
+
+	res, targets, err := applySchedules(policy, now)
+	require.NoError(t, err)
+	require.Equal(t, &scheduledTargets{members: util.Int32ptr(10), cpu: &cpu}, targets)
+	require.Equal(t, &v1alpha1.RackMemberPolicy{MinAllowed: util.Int32ptr(6), MaxAllowed: util.Int32ptr(20)}, res.MemberPolicy)
+	require.Equal(t, &v1alpha1.RackResourcePolicy{MaxAllowedCpu: &cpu}, res.ResourcePolicy)
+	require.Equal(t, int32(1), *policy.MemberPolicy.MinAllowed, "policy is not modified")
+	require.Nil(t, policy.ResourcePolicy, "policy is not modified")
+
+	inactive := &v1alpha1.RackScalingPolicy{Name: "rack", Schedules: []v1alpha1.ScalingSchedule{never}}
+	res, targets, err = applySchedules(inactive, now)
+	require.NoError(t, err)
+	require.Nil(t, targets)
+	require.Equal(t, inactive, res)
+
+	malformed := always("malformed")
+	malformed.Schedule = "every day"
+	_, _, err = applySchedules(&v1alpha1.RackScalingPolicy{Name: "rack", Schedules: []v1alpha1.ScalingSchedule{malformed}}, now)
+	require.Error(t, err)
+}
+
+func TestScheduledTargets(t *testing.T) {
+	min, max := util.Int32ptr(2), util.Int32ptr(10)
+	minCpu, maxCpu := resource.MustParse("1"), resource.MustParse("8")
+	current := resource.MustParse("500m")
+	target := resource.MustParse("4")
+
+	require.Equal(t, int32(2), (&scheduledTargets{}).scheduledMembers(1, min, max))
+	require.Equal(t, int32(5), (&scheduledTargets{}).scheduledMembers(5, min, max))
+	require.Equal(t, int32(10), (&scheduledTargets{members: util.Int32ptr(12)}).scheduledMembers(5, min, max))
+
+	require.True(t, minCpu.Equal((&scheduledTargets{}).scheduledCPU(&current, &minCpu, &maxCpu)))
+	require.True(t, target.Equal((&scheduledTargets{cpu: &target}).scheduledCPU(&current, &minCpu, &maxCpu)))
+}
+
+func TestGetRackRecommendationsOfRacksWithoutCpu(t *testing.T) {
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+	pp := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), nil), logger, time.Minute)
+	r := New(nil, pp, nil, Options{}, logger).(*recommender)
+
+	withoutCpu := func(rack *scyllav1.RackSpec) *scyllav1.RackSpec {
+		delete(rack.Resources.Requests, corev1.ResourceCPU)
+		delete(rack.Resources.Limits, corev1.ResourceCPU)
+		return rack
+	}
+	always := func(members *int32, cpu *resource.Quantity) []v1alpha1.ScalingSchedule {
+		return []v1alpha1.ScalingSchedule{
+			{Name: "always", Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}, Members: members, Cpu: cpu},
+		}
+	}
+	newPolicy := func(rules []v1alpha1.ScalingRule) *v1alpha1.RackScalingPolicy {
+		return newRackScalingPolicy("rack_name", rules, 1, 100, resource.MustParse("1"), resource.MustParse("100"),
+			v1alpha1.RackControlledValuesRequestsAndLimits)
+	}
+	verticalRule := []v1alpha1.ScalingRule{
+		*newScalingRule("rule_name", 1, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeVertical, 2),
+	}
+	cpu := resource.MustParse("10")
+
+	tests := []struct {
+		name          string
+		rack          *scyllav1.RackSpec
+		policy        *v1alpha1.RackScalingPolicy
+		expected      *v1alpha1.RackRecommendations
+		errorExpected bool
+	}{
+		{
+			name:   "scheduled members of rack without cpu requests",
+			rack:   withoutCpu(getRackSpec("rack_name", 3, "5", "5", "1Gi", "1Gi")),
+			policy: setSchedules(always(util.Int32ptr(6), nil), newPolicy(nil)),
+			expected: &v1alpha1.RackRecommendations{
+				Name:    "rack_name",
+				Members: util.Int32ptr(6),
+				Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+				},
+			},
+		},
+		{
+			name:          "scheduled cpu of rack without cpu requests",
+			rack:          withoutCpu(getRackSpec("rack_name", 3, "5", "5", "1Gi", "1Gi")),
+			policy:        setSchedules(always(nil, &cpu), newPolicy(nil)),
+			errorExpected: true,
+		},
+		{
+			name:          "vertical rule of rack without cpu requests",
+			rack:          withoutCpu(getRackSpec("rack_name", 3, "5", "5", "1Gi", "1Gi")),
+			policy:        newPolicy(verticalRule),
+			errorExpected: true,
+		},
+		{
+			name:   "vertical rule of rack without cpu limits",
+			rack:   getRackSpec("rack_name", 3, "5", "", "1Gi", ""),
+			policy: newPolicy(verticalRule),
+			expected: &v1alpha1.RackRecommendations{
+				Name:    "rack_name",
+				Members: util.Int32ptr(3),
+				Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("10"),
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec, _, err := r.getRackRecommendations(ctx, pp, test.rack, test.policy)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, *test.expected.Members, *rec.Members)
+			require.True(t, equality.Semantic.DeepEqual(test.expected.Resources, rec.Resources), "got %v", rec.Resources)
+		})
+	}
+}
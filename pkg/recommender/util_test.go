@@ -5,9 +5,12 @@ import (
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
 	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/scylladb/scylla-operator/pkg/naming"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
 )
 
@@ -55,6 +58,11 @@ func newSingleDcSca(scaName, scaNamespace, scName, scNamespace, dcName string, r
 	}
 }
 
+func setMetricsSource(source *v1alpha1.MetricsSource, sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	sca.Spec.MetricsSource = source
+	return sca
+}
+
 func newSingleDcSc(scName, scNamespace, dcName string, racksSpec []scyllav1.RackSpec,
 	racksStatus map[string]scyllav1.RackStatus) *scyllav1.ScyllaCluster {
 
@@ -75,6 +83,23 @@ func newSingleDcSc(scName, scNamespace, dcName string, racksSpec []scyllav1.Rack
 	}
 }
 
+// getStatefulSets returns the StatefulSets of the cluster's racks, with as many ready pods as the racks' ready members.
+func getStatefulSets(sc *scyllav1.ScyllaCluster) []client.Object {
+	var res []client.Object
+	for _, rack := range sc.Spec.Datacenter.Racks {
+		res = append(res, &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: naming.StatefulSetNameForRack(rack, sc), Namespace: sc.Namespace},
+			Spec:       appsv1.StatefulSetSpec{Replicas: util.Int32ptr(rack.Members)},
+			Status: appsv1.StatefulSetStatus{
+				Replicas:        rack.Members,
+				ReadyReplicas:   sc.Status.Racks[rack.Name].ReadyMembers,
+				UpdatedReplicas: rack.Members,
+			},
+		})
+	}
+	return res
+}
+
 func scsRecommendationsEquivalent(rec1, rec2 *v1alpha1.ScyllaClusterRecommendations) bool {
 	for _, dcRec1 := range rec1.DatacenterRecommendations {
 		dcRec2 := findDc(dcRec1.Name, rec2.DatacenterRecommendations)
@@ -236,6 +261,32 @@ func newScalingRule(name string, priority int32, expression string, durFor, durS
 	}
 }
 
+func setCondition(condition *v1alpha1.RuleCondition, rule *v1alpha1.ScalingRule) *v1alpha1.ScalingRule {
+	rule.Condition = condition
+	return rule
+}
+
+func setBehavior(behavior *v1alpha1.RackScalingBehavior, policy *v1alpha1.RackScalingPolicy) *v1alpha1.RackScalingPolicy {
+	policy.Behavior = behavior
+	return policy
+}
+
+func setStrategy(strategy v1alpha1.RackScalingStrategy, policy *v1alpha1.RackScalingPolicy) *v1alpha1.RackScalingPolicy {
+	policy.Strategy = strategy
+	return policy
+}
+
+func setSchedules(schedules []v1alpha1.ScalingSchedule, policy *v1alpha1.RackScalingPolicy) *v1alpha1.RackScalingPolicy {
+	policy.Schedules = schedules
+	return policy
+}
+
+func setFormula(formula string, metrics []v1alpha1.RuleMetric, rule *v1alpha1.ScalingRule) *v1alpha1.ScalingRule {
+	rule.Formula = formula
+	rule.Metrics = metrics
+	return rule
+}
+
 func stringMulFloat64(s string, f2 float64) string {
 	f1, err := strconv.ParseFloat(s, 64)
 	if err != nil {
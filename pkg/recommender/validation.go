@@ -0,0 +1,140 @@
+package recommender
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+)
+
+// ValidateScalingPolicy checks the scaling rules of the policy, so that invalid ones can be rejected
+// before they are evaluated.
+func ValidateScalingPolicy(scalingPolicy *v1alpha1.ScalingPolicy) error {
+	if scalingPolicy == nil {
+		return nil
+	}
+
+	for _, dc := range scalingPolicy.Datacenters {
+		if err := validateEffectiveRackPolicies(&dc); err != nil {
+			return errors.Wrapf(err, "datacenter \"%s\"", dc.Name)
+		}
+		for _, rack := range dc.RackScalingPolicies {
+			if err := validateBehavior(rack.Behavior); err != nil {
+				return errors.Wrapf(err, "datacenter \"%s\", rack \"%s\", behavior", dc.Name, rack.Name)
+			}
+			if err := validatePrediction(rack.Prediction); err != nil {
+				return errors.Wrapf(err, "datacenter \"%s\", rack \"%s\", prediction", dc.Name, rack.Name)
+			}
+			if err := validateRightsizing(rack.Rightsizing); err != nil {
+				return errors.Wrapf(err, "datacenter \"%s\", rack \"%s\", rightsizing", dc.Name, rack.Name)
+			}
+			if rack.WarmUp != nil && rack.WarmUp.Duration < 0 {
+				return errors.Errorf("datacenter \"%s\", rack \"%s\": warm-up can't be negative", dc.Name, rack.Name)
+			}
+			for i := range rack.Schedules {
+				schedule := &rack.Schedules[i]
+				if err := validateSchedule(schedule); err != nil {
+					return errors.Wrapf(err, "datacenter \"%s\", rack \"%s\", schedule \"%s\"", dc.Name, rack.Name, schedule.Name)
+				}
+			}
+			for i := range rack.ScalingRules {
+				rule := &rack.ScalingRules[i]
+				if err := validateScalingRule(rule); err != nil {
+					return errors.Wrapf(err, "datacenter \"%s\", rack \"%s\", rule \"%s\"", dc.Name, rack.Name, rule.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateMetricsSource checks that the metrics source of an SCA in the given namespace only references objects
+// of the SCA's namespace, so that SCAs can't read the services or credentials of other namespaces.
+func ValidateMetricsSource(namespace string, source *v1alpha1.MetricsSource) error {
+	if source == nil {
+		return nil
+	}
+
+	if source.ServiceRef != nil && source.ServiceRef.Namespace != "" && source.ServiceRef.Namespace != namespace {
+		return errors.Errorf("metrics source: service \"%s\" has to be in the SCA's namespace \"%s\", not \"%s\"",
+			source.ServiceRef.Name, namespace, source.ServiceRef.Namespace)
+	}
+
+	return nil
+}
+
+// validateEffectiveRackPolicies checks the constraints spanning multiple fields of the rack policies
+// against their effective policies, i.e. the policies of specific racks merged on top of the default one.
+// The default policy applies on its own to the racks without a specific policy.
+func validateEffectiveRackPolicies(dc *v1alpha1.DatacenterScalingPolicy) error {
+	var defaultPolicy *v1alpha1.RackScalingPolicy
+	for i := range dc.RackScalingPolicies {
+		if dc.RackScalingPolicies[i].Name == v1alpha1.RackNameWildcard {
+			defaultPolicy = &dc.RackScalingPolicies[i]
+		}
+	}
+
+	for i := range dc.RackScalingPolicies {
+		policy := &dc.RackScalingPolicies[i]
+		if defaultPolicy != nil && policy != defaultPolicy {
+			policy = mergeRackScalingPolicies(defaultPolicy, policy)
+		}
+		for _, rule := range policy.ScalingRules {
+			if policy.Strategy == v1alpha1.RackScalingStrategyHybrid && rule.Formula != "" {
+				return errors.Errorf("rack \"%s\", rule \"%s\": formula can't be used with the %s strategy",
+					policy.Name, rule.Name, policy.Strategy)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateScalingRule(rule *v1alpha1.ScalingRule) error {
+	condition, err := ruleCondition(rule)
+	if err != nil {
+		return err
+	}
+	if err := validateConditionTree(condition); err != nil {
+		return errors.Wrap(err, "condition")
+	}
+
+	if rule.Formula == "" {
+		if len(rule.Metrics) > 0 {
+			return errors.New("metrics can only be specified along with formula")
+		}
+		if rule.ScalingFactor <= 0 {
+			return errors.New("either a positive factor or formula has to be specified")
+		}
+		return nil
+	}
+
+	if rule.ScalingFactor != 0 {
+		return errors.New("factor can't be specified along with formula")
+	}
+	return validateFormula(rule.Formula, rule.Metrics)
+}
+
+func validateBehavior(behavior *v1alpha1.RackScalingBehavior) error {
+	if behavior == nil {
+		return nil
+	}
+
+	for _, rules := range []*v1alpha1.ScalingBehaviorRules{behavior.ScaleUp, behavior.ScaleDown} {
+		if rules == nil {
+			continue
+		}
+		if rules.StabilizationWindow != nil && rules.StabilizationWindow.Duration < 0 {
+			return errors.New("stabilization window can't be negative")
+		}
+		for _, p := range rules.Policies {
+			if p.Period.Duration <= 0 {
+				return errors.New("policy period has to be positive")
+			}
+			if p.Value <= 0 {
+				return errors.New("policy value has to be positive")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package recommender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateScalingPolicy(t *testing.T) {
+	newPolicy := func(rule *v1alpha1.ScalingRule) *v1alpha1.ScalingPolicy {
+		sca := newSingleDcSca("sca", "sca-ns", "sc", "sc-ns", "dc",
+			newRackScalingPolicy("rack", []v1alpha1.ScalingRule{*rule},
+				1, 10, resource.MustParse("1"), resource.MustParse("10"),
+				v1alpha1.RackControlledValuesRequestsAndLimits))
+		return sca.Spec.ScalingPolicy
+	}
+	usage := []v1alpha1.RuleMetric{{Name: "usage", Expression: "up"}}
+
+	tests := []struct {
+		name          string
+		scalingPolicy *v1alpha1.ScalingPolicy
+		errorExpected bool
+	}{
+		{
+			name:          "no scaling policy",
+			scalingPolicy: nil,
+		},
+		{
+			name:          "factor",
+			scalingPolicy: newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2)),
+		},
+		{
+			name: "formula",
+			scalingPolicy: newPolicy(setFormula("ceil(current * usage / 0.6)", usage,
+				newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0))),
+		},
+		{
+			name: "condition",
+			scalingPolicy: newPolicy(setCondition(&v1alpha1.RuleCondition{
+				AnyOf: []v1alpha1.RuleCondition{{Expression: "up"}, {Not: &v1alpha1.RuleCondition{Expression: "down"}}},
+			}, newScalingRule("rule", 1, "", nil, nil, v1alpha1.ScalingModeHorizontal, 2))),
+		},
+		{
+			name: "behavior with non-positive period",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Behavior = &v1alpha1.RackScalingBehavior{
+					ScaleUp: &v1alpha1.ScalingBehaviorRules{
+						Policies: []v1alpha1.ScalingRatePolicy{{Type: v1alpha1.ScalingRatePolicyTypeMembers, Value: 1}},
+					},
+				}
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name:          "neither factor nor formula",
+			scalingPolicy: newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0)),
+			errorExpected: true,
+		},
+		{
+			name: "factor and formula",
+			scalingPolicy: newPolicy(setFormula("current + 1.0", nil,
+				newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))),
+			errorExpected: true,
+		},
+		{
+			name: "metrics without formula",
+			scalingPolicy: newPolicy(setFormula("", usage,
+				newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))),
+			errorExpected: true,
+		},
+		{
+			name: "formula referencing undefined variable",
+			scalingPolicy: newPolicy(setFormula("current * usage", nil,
+				newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0))),
+			errorExpected: true,
+		},
+		{
+			name: "formula with the hybrid strategy",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(setFormula("current + 1.0", nil,
+					newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0)))
+				sp.Datacenters[0].RackScalingPolicies[0].Strategy = v1alpha1.RackScalingStrategyHybrid
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "formula of a rack with the hybrid strategy of the default policy",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(setFormula("current + 1.0", nil,
+					newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0)))
+				sp.Datacenters[0].RackScalingPolicies = append(sp.Datacenters[0].RackScalingPolicies, v1alpha1.RackScalingPolicy{
+					Name:     v1alpha1.RackNameWildcard,
+					Strategy: v1alpha1.RackScalingStrategyHybrid,
+				})
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "formula of the default policy overridden by a rack with the hybrid strategy",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Strategy = v1alpha1.RackScalingStrategyHybrid
+				sp.Datacenters[0].RackScalingPolicies = append(sp.Datacenters[0].RackScalingPolicies, v1alpha1.RackScalingPolicy{
+					Name: v1alpha1.RackNameWildcard,
+					ScalingRules: []v1alpha1.ScalingRule{*setFormula("current + 1.0", nil,
+						newScalingRule("default-rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0))},
+				})
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "formula of the default policy replaced by a rack with the hybrid strategy",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Strategy = v1alpha1.RackScalingStrategyHybrid
+				sp.Datacenters[0].RackScalingPolicies = append(sp.Datacenters[0].RackScalingPolicies, v1alpha1.RackScalingPolicy{
+					Name: v1alpha1.RackNameWildcard,
+					ScalingRules: []v1alpha1.ScalingRule{*setFormula("current + 1.0", nil,
+						newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 0))},
+				})
+				return sp
+			}(),
+		},
+		{
+			name: "malformed schedule",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Schedules = []v1alpha1.ScalingSchedule{
+					{Name: "schedule", Schedule: "0 8 * * 1-5", TimeZone: "Mars/Olympus_Mons", Duration: metav1.Duration{Duration: time.Hour}, MinAllowed: util.Int32ptr(6)},
+				}
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "prediction",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Prediction = &v1alpha1.PredictiveScaling{
+					Expression:  `avg(scylla_reactor_utilization{scylla_rack="{{ .Rack }}"})`,
+					TargetValue: 70,
+					Mode:        v1alpha1.ScalingModeVertical,
+				}
+				return sp
+			}(),
+		},
+		{
+			name: "prediction with history shorter than two seasons",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Prediction = &v1alpha1.PredictiveScaling{
+					Expression:  "load",
+					TargetValue: 70,
+					Mode:        v1alpha1.ScalingModeHorizontal,
+					History:     &metav1.Duration{Duration: 36 * time.Hour},
+				}
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "prediction with template referencing unknown variable",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Prediction = &v1alpha1.PredictiveScaling{
+					Expression:  `load{scylla_host="{{ .Host }}"}`,
+					TargetValue: 70,
+					Mode:        v1alpha1.ScalingModeHorizontal,
+				}
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "rightsizing without rules",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].ScalingRules = nil
+				sp.Datacenters[0].RackScalingPolicies[0].Rightsizing = &v1alpha1.RackRightsizing{}
+				return sp
+			}(),
+		},
+		{
+			name: "rightsizing with percentile above 100",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].Rightsizing = &v1alpha1.RackRightsizing{MemoryPercentile: util.Int32ptr(101)}
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "negative warm-up",
+			scalingPolicy: func() *v1alpha1.ScalingPolicy {
+				sp := newPolicy(newScalingRule("rule", 1, "up", nil, nil, v1alpha1.ScalingModeHorizontal, 2))
+				sp.Datacenters[0].RackScalingPolicies[0].WarmUp = &metav1.Duration{Duration: -time.Minute}
+				return sp
+			}(),
+			errorExpected: true,
+		},
+		{
+			name: "malformed nested condition",
+			scalingPolicy: newPolicy(setCondition(&v1alpha1.RuleCondition{
+				AllOf: []v1alpha1.RuleCondition{{Expression: "up"}, {Not: &v1alpha1.RuleCondition{}}},
+			}, newScalingRule("rule", 1, "", nil, nil, v1alpha1.ScalingModeHorizontal, 2))),
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateScalingPolicy(test.scalingPolicy)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
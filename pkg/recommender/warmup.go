@@ -0,0 +1,127 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// warmUp tells which racks are warming up after the changes applied to them, and records the ones found.
+type warmUp struct {
+	now time.Time
+	// changed are the racks changed by the last applied recommendations, which aren't ready yet.
+	changed map[string]struct{}
+	// started are the warm-ups of the racks changed by the last applied recommendations, which are ready again.
+	started []v1alpha1.RackWarmUp
+
+	// racks are the names of the racks found warming up, in the order of evaluation.
+	racks []string
+}
+
+// newWarmUp checks the readiness of the racks changed by the last applied recommendations. The warm-up of a rack
+// starts once it's found ready again, e.g. after the members added to it have joined the cluster, and is kept from
+// the SCA's status as long as the rack stays ready. Racks whose readiness can't be evaluated are considered not ready,
+// keeping their warm-ups, and the first of the errors is returned along with the otherwise complete warm-up.
+func newWarmUp(ctx context.Context, c client.Reader, sca *v1alpha1.ScyllaClusterAutoscaler, cluster *scyllav1.ScyllaCluster, now time.Time) (*warmUp, error) {
+	w := &warmUp{now: now, changed: make(map[string]struct{})}
+	lastApplied := sca.Status.LastApplied
+	if lastApplied == nil {
+		return w, nil
+	}
+
+	started := make(map[string]metav1.Time)
+	for _, wu := range sca.Status.WarmUps {
+		if !wu.Started.Before(lastApplied) {
+			started[wu.Rack] = wu.Started
+		}
+	}
+
+	var firstErr error
+	for _, name := range sca.Status.LastAppliedRacks {
+		rack := findRackSpec(cluster, name)
+		if rack == nil {
+			continue
+		}
+
+		readiness, err := util.EvaluateRackReadiness(ctx, c, cluster, rack)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			w.changed[name] = struct{}{}
+			if since, ok := started[name]; ok {
+				w.started = append(w.started, v1alpha1.RackWarmUp{Rack: name, Started: since})
+			}
+			continue
+		}
+		if !readiness.Ready {
+			w.changed[name] = struct{}{}
+			continue
+		}
+
+		since, ok := started[name]
+		if !ok {
+			since = metav1.NewTime(now.UTC())
+		}
+		w.started = append(w.started, v1alpha1.RackWarmUp{Rack: name, Started: since})
+	}
+
+	return w, firstErr
+}
+
+func findRackSpec(cluster *scyllav1.ScyllaCluster, name string) *scyllav1.RackSpec {
+	for i := range cluster.Spec.Datacenter.Racks {
+		if cluster.Spec.Datacenter.Racks[i].Name == name {
+			return &cluster.Spec.Datacenter.Racks[i]
+		}
+	}
+	return nil
+}
+
+// rackWarmingUp reports whether the rack is warming up with the given warm-up period, i.e. whether it was changed
+// by the last applied recommendations and either isn't ready yet or has been ready for less than the period.
+// Racks without a warm-up period never warm up.
+func (w *warmUp) rackWarmingUp(rack string, period *metav1.Duration) bool {
+	if period == nil {
+		return false
+	}
+
+	_, warmingUp := w.changed[rack]
+	for _, wu := range w.started {
+		if wu.Rack == rack && w.now.Before(wu.Started.Add(period.Duration)) {
+			warmingUp = true
+		}
+	}
+
+	if warmingUp {
+		w.racks = append(w.racks, rack)
+	}
+	return warmingUp
+}
+
+// setWarmingUpCondition reports the racks found warming up in the SCA's status.
+func setWarmingUpCondition(sca *v1alpha1.ScyllaClusterAutoscaler, w *warmUp) {
+	sca.Status.WarmingUpRacks = w.racks
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.WarmingUpCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: sca.Generation,
+		Reason:             "NotWarmingUp",
+		Message:            "No rack is warming up.",
+	}
+	if len(w.racks) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RacksWarmingUp"
+		condition.Message = fmt.Sprintf("Racks warming up after changes aren't evaluated: %s.", strings.Join(w.racks, ", "))
+	}
+	meta.SetStatusCondition(&sca.Status.Conditions, condition)
+}
@@ -0,0 +1,289 @@
+package recommender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scylladb/go-log"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics"
+	mockprometheusapi "github.com/scylladb/scylla-operator-autoscaler/pkg/recommender/metrics/mock"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRackWarmingUp(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	lastApplied := metav1.NewTime(now.Add(-time.Hour))
+	tenMinutes := &metav1.Duration{Duration: 10 * time.Minute}
+	readySince := func(d time.Duration) []v1alpha1.RackWarmUp {
+		return []v1alpha1.RackWarmUp{{Rack: "rack", Started: metav1.NewTime(now.Add(-d))}}
+	}
+
+	tests := []struct {
+		name              string
+		period            *metav1.Duration
+		lastApplied       *metav1.Time
+		lastAppliedRacks  []string
+		readyMembers      int32
+		rackConditions    []scyllav1.RackCondition
+		warmUps           []v1alpha1.RackWarmUp
+		expectedWarmingUp bool
+		expectedWarmUps   []v1alpha1.RackWarmUp
+	}{
+		{
+			name:              "ready within warm-up",
+			period:            tenMinutes,
+			lastApplied:       &lastApplied,
+			lastAppliedRacks:  []string{"rack"},
+			readyMembers:      3,
+			warmUps:           readySince(5 * time.Minute),
+			expectedWarmingUp: true,
+			expectedWarmUps:   readySince(5 * time.Minute),
+		},
+		{
+			name:             "ready before warm-up",
+			period:           tenMinutes,
+			lastApplied:      &lastApplied,
+			lastAppliedRacks: []string{"rack"},
+			readyMembers:     3,
+			warmUps:          readySince(15 * time.Minute),
+			expectedWarmUps:  readySince(15 * time.Minute),
+		},
+		{
+			name:              "still streaming after last applied",
+			period:            tenMinutes,
+			lastApplied:       &lastApplied,
+			lastAppliedRacks:  []string{"rack"},
+			readyMembers:      2,
+			warmUps:           readySince(15 * time.Minute),
+			expectedWarmingUp: true,
+		},
+		{
+			name:              "in condition after last applied",
+			period:            tenMinutes,
+			lastApplied:       &lastApplied,
+			lastAppliedRacks:  []string{"rack"},
+			readyMembers:      3,
+			rackConditions:    []scyllav1.RackCondition{{Type: scyllav1.RackConditionTypeMemberLeaving, Status: corev1.ConditionTrue}},
+			expectedWarmingUp: true,
+		},
+		{
+			name:              "ready again now",
+			period:            tenMinutes,
+			lastApplied:       &lastApplied,
+			lastAppliedRacks:  []string{"rack"},
+			readyMembers:      3,
+			expectedWarmingUp: true,
+			expectedWarmUps:   readySince(0),
+		},
+		{
+			name:              "ready before last applied",
+			period:            tenMinutes,
+			lastApplied:       &lastApplied,
+			lastAppliedRacks:  []string{"rack"},
+			readyMembers:      3,
+			warmUps:           readySince(2 * time.Hour),
+			expectedWarmingUp: true,
+			expectedWarmUps:   readySince(0),
+		},
+		{
+			name:             "other rack changed",
+			period:           tenMinutes,
+			lastApplied:      &lastApplied,
+			lastAppliedRacks: []string{"other-rack"},
+			readyMembers:     2,
+		},
+		{
+			name:             "no warm-up",
+			lastApplied:      &lastApplied,
+			lastAppliedRacks: []string{"rack"},
+			readyMembers:     2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rackStatus := getRackStatus(3, test.readyMembers)
+			rackStatus.Conditions = test.rackConditions
+			sc := newSingleDcSc("test-sc", "test-sc-ns", "dc_name",
+				[]scyllav1.RackSpec{*getRackSpec("rack", 3, "1", "1", "1Gi", "1Gi")},
+				map[string]scyllav1.RackStatus{"rack": *rackStatus})
+			sca := &v1alpha1.ScyllaClusterAutoscaler{Status: v1alpha1.ScyllaClusterAutoscalerStatus{
+				LastApplied:      test.lastApplied,
+				LastAppliedRacks: test.lastAppliedRacks,
+				WarmUps:          test.warmUps,
+			}}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(getStatefulSets(sc)...).Build()
+
+			w, err := newWarmUp(ctx, c, sca, sc, now)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedWarmingUp, w.rackWarmingUp("rack", test.period))
+			if test.expectedWarmingUp {
+				require.Equal(t, []string{"rack"}, w.racks)
+			} else {
+				require.Empty(t, w.racks)
+			}
+			require.Len(t, w.started, len(test.expectedWarmUps))
+			for i, expected := range test.expectedWarmUps {
+				require.Equal(t, expected.Rack, w.started[i].Rack)
+				require.WithinDuration(t, expected.Started.Time, w.started[i].Started.Time, time.Second)
+			}
+		})
+	}
+}
+
+// unavailableReader fails to read any object.
+type unavailableReader struct {
+	client.Reader
+}
+
+func (unavailableReader) Get(context.Context, client.ObjectKey, client.Object) error {
+	return errors.New("unavailable")
+}
+
+func TestNewWarmUpKeepsRacksOfUnknownReadiness(t *testing.T) {
+	now := time.Now()
+	lastApplied := metav1.NewTime(now.Add(-time.Hour))
+	warmUps := []v1alpha1.RackWarmUp{{Rack: "rack", Started: metav1.NewTime(now.Add(-5 * time.Minute))}}
+	sc := newSingleDcSc("test-sc", "test-sc-ns", "dc_name",
+		[]scyllav1.RackSpec{*getRackSpec("rack", 3, "1", "1", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{"rack": *getRackStatus(3, 3)})
+	sca := &v1alpha1.ScyllaClusterAutoscaler{Status: v1alpha1.ScyllaClusterAutoscalerStatus{
+		LastApplied:      &lastApplied,
+		LastAppliedRacks: []string{"rack"},
+		WarmUps:          warmUps,
+	}}
+
+	w, err := newWarmUp(context.Background(), unavailableReader{}, sca, sc, now)
+	require.Error(t, err)
+	require.NotNil(t, w)
+	// The rack is considered not ready, even past its warm-up, and its warm-up is kept.
+	require.True(t, w.rackWarmingUp("rack", &metav1.Duration{Duration: time.Minute}))
+	require.Equal(t, warmUps, w.started)
+}
+
+func TestRunOnceStartsWarmUpOnceRackIsReady(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+
+	// The rack's new member is still streaming data long after the recommendations were applied.
+	sc := newSingleDcSc("test-sc", "test-sc-ns", dcName,
+		[]scyllav1.RackSpec{*getRackSpec(rackName, 3, "1", "1", "1Gi", "1Gi")},
+		map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 2)})
+	rule := newScalingRule("rule", 0, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 2)
+	rackPolicy := newRackScalingPolicy(rackName, []v1alpha1.ScalingRule{*rule}, 1, 100, resource.MustParse("1"), resource.MustParse("10"),
+		v1alpha1.RackControlledValuesRequestsAndLimits)
+	rackPolicy.WarmUp = &metav1.Duration{Duration: 10 * time.Minute}
+	sca := newSingleDcSca("test-sca", "test-sca-ns", sc.Name, sc.Namespace, dcName, rackPolicy)
+	lastApplied := metav1.NewTime(time.Now().Add(-time.Hour))
+	sca.Status.LastApplied = &lastApplied
+	sca.Status.LastAppliedRacks = []string{rackName}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(getStatefulSets(sc), sc, sca)...).Build()
+	pp := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), nil), logger, time.Minute)
+	r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{}, logger)
+	require.NoError(t, r.RunOnce(ctx))
+
+	res := &v1alpha1.ScyllaClusterAutoscaler{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+	require.Equal(t, v1alpha1.UpdateStatusTargetNotReady, *res.Status.UpdateStatus)
+	require.Empty(t, res.Status.WarmUps)
+
+	// Once the member is ready, the rack warms up, although the recommendations were applied longer than warmUp ago.
+	ready := &scyllav1.ScyllaCluster{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sc.Namespace, Name: sc.Name}, ready))
+	ready.Status.Racks = map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)}
+	require.NoError(t, c.Status().Update(ctx, ready))
+	for _, obj := range getStatefulSets(ready) {
+		require.NoError(t, c.Status().Update(ctx, obj))
+	}
+	require.NoError(t, r.RunOnce(ctx))
+
+	res = &v1alpha1.ScyllaClusterAutoscaler{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+	require.Equal(t, v1alpha1.UpdateStatusOk, *res.Status.UpdateStatus)
+	require.True(t, meta.IsStatusConditionTrue(res.Status.Conditions, v1alpha1.WarmingUpCondition))
+	require.Equal(t, []string{rackName}, res.Status.WarmingUpRacks)
+	require.Nil(t, res.Status.Recommendations)
+	require.Len(t, res.Status.WarmUps, 1)
+	require.Equal(t, rackName, res.Status.WarmUps[0].Rack)
+	require.True(t, res.Status.WarmUps[0].Started.After(lastApplied.Time))
+}
+
+func TestRunOnceSkipsRacksWarmingUp(t *testing.T) {
+	const (
+		dcName   = "dc_name"
+		rackName = "rack_name"
+	)
+	ctx := log.WithNewTraceID(context.Background())
+	logger, _ := log.NewProduction(log.Config{Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})
+
+	tests := []struct {
+		name              string
+		lastApplied       time.Duration
+		expectedWarmingUp bool
+	}{
+		{
+			name:              "rack warming up",
+			lastApplied:       time.Minute,
+			expectedWarmingUp: true,
+		},
+		{
+			name:        "rack warmed up",
+			lastApplied: time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc := newSingleDcSc("test-sc", "test-sc-ns", dcName,
+				[]scyllav1.RackSpec{*getRackSpec(rackName, 3, "1", "1", "1Gi", "1Gi")},
+				map[string]scyllav1.RackStatus{rackName: *getRackStatus(3, 3)})
+			rule := newScalingRule("rule", 0, mockprometheusapi.QueryWillReturnTrue, nil, nil, v1alpha1.ScalingModeHorizontal, 2)
+			rackPolicy := newRackScalingPolicy(rackName, []v1alpha1.ScalingRule{*rule}, 1, 100, resource.MustParse("1"), resource.MustParse("10"),
+				v1alpha1.RackControlledValuesRequestsAndLimits)
+			rackPolicy.WarmUp = &metav1.Duration{Duration: 10 * time.Minute}
+			sca := newSingleDcSca("test-sca", "test-sca-ns", sc.Name, sc.Namespace, dcName, rackPolicy)
+			lastApplied := metav1.NewTime(time.Now().Add(-test.lastApplied))
+			sca.Status.LastApplied = &lastApplied
+			sca.Status.LastAppliedRacks = []string{rackName}
+			sca.Status.WarmUps = []v1alpha1.RackWarmUp{{Rack: rackName, Started: lastApplied}}
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(getStatefulSets(sc), sc, sca)...).Build()
+			pp := metrics.NewPrometheusProvider(mockprometheusapi.NewMockApi(mockprometheusapi.SimpleQueryFunction(), nil), logger, time.Minute)
+			r := New(c, pp, &metrics.Factory{Client: c, Logger: logger, DefaultStep: time.Minute}, Options{}, logger)
+			require.NoError(t, r.RunOnce(ctx))
+
+			res := &v1alpha1.ScyllaClusterAutoscaler{}
+			require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: sca.Namespace, Name: sca.Name}, res))
+			require.Equal(t, v1alpha1.UpdateStatusOk, *res.Status.UpdateStatus)
+			require.Equal(t, test.expectedWarmingUp, meta.IsStatusConditionTrue(res.Status.Conditions, v1alpha1.WarmingUpCondition))
+			if test.expectedWarmingUp {
+				require.Equal(t, []string{rackName}, res.Status.WarmingUpRacks)
+				require.Nil(t, res.Status.Recommendations)
+				return
+			}
+
+			require.Empty(t, res.Status.WarmingUpRacks)
+			require.NotNil(t, res.Status.Recommendations)
+			rec := res.Status.Recommendations.DatacenterRecommendations[0].RackRecommendations[0]
+			require.Equal(t, int32(6), *rec.Members)
+		})
+	}
+}
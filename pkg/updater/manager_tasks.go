@@ -0,0 +1,240 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduledTask is a Scylla Manager task of a ScyllaCluster, with its schedule resolved.
+type scheduledTask struct {
+	taskType v1alpha1.ManagerTaskType
+	name     string
+	start    time.Time
+	// interval between the runs, zero if the task runs once.
+	interval time.Duration
+}
+
+// scheduledTasks returns the tasks of the given types defined in the cluster's spec. The start dates reported
+// in the cluster's status take precedence over the ones of the spec, since only they are resolved if the start
+// is relative to the creation of the task, e.g. "now+1d". Tasks whose start date is unknown are left out.
+func scheduledTasks(cluster *scyllav1.ScyllaCluster, types []v1alpha1.ManagerTaskType) ([]scheduledTask, error) {
+	var res []scheduledTask
+	for _, taskType := range types {
+		var specs, statuses []scyllav1.SchedulerTaskSpec
+		switch taskType {
+		case v1alpha1.ManagerTaskTypeRepair:
+			for _, r := range cluster.Spec.Repairs {
+				specs = append(specs, r.SchedulerTaskSpec)
+			}
+			for _, r := range cluster.Status.Repairs {
+				statuses = append(statuses, r.SchedulerTaskSpec)
+			}
+		case v1alpha1.ManagerTaskTypeBackup:
+			for _, b := range cluster.Spec.Backups {
+				specs = append(specs, b.SchedulerTaskSpec)
+			}
+			for _, b := range cluster.Status.Backups {
+				statuses = append(statuses, b.SchedulerTaskSpec)
+			}
+		}
+
+		for _, spec := range specs {
+			start, ok := taskStart(spec, statuses)
+			if !ok {
+				continue
+			}
+			var interval time.Duration
+			if spec.Interval != nil {
+				var err error
+				if interval, err = parseManagerDuration(*spec.Interval); err != nil {
+					return nil, errors.Wrapf(err, "%s task \"%s\": interval", strings.ToLower(string(taskType)), spec.Name)
+				}
+			}
+			res = append(res, scheduledTask{taskType: taskType, name: spec.Name, start: start, interval: interval})
+		}
+	}
+
+	return res, nil
+}
+
+func taskStart(spec scyllav1.SchedulerTaskSpec, statuses []scyllav1.SchedulerTaskSpec) (time.Time, bool) {
+	dates := []*string{spec.StartDate}
+	for _, status := range statuses {
+		if status.Name == spec.Name {
+			dates = []*string{status.StartDate, spec.StartDate}
+		}
+	}
+	for _, date := range dates {
+		if date == nil {
+			continue
+		}
+		if start, err := time.Parse(time.RFC3339, *date); err == nil {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var managerDurationDays = regexp.MustCompile(`^(\d+)d`)
+
+// parseManagerDuration parses a duration in the format of Scylla Manager, which extends the one of Go with days,
+// e.g. "3d2h10m".
+func parseManagerDuration(s string) (time.Duration, error) {
+	var days time.Duration
+	if m := managerDurationDays.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, err
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		s = s[len(m[0]):]
+		if s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return days + d, nil
+}
+
+// heldUntil tells whether a run of the task holds the updates at now, i.e. whether now is within lead before
+// the run's start, or within expected after it, and when the run is expected to end.
+func (t *scheduledTask) heldUntil(now time.Time, lead, expected time.Duration) (time.Time, bool) {
+	// The latest run starting before the lead of now, and the one preceding it, which might still be running.
+	run := t.start
+	if t.interval > 0 && now.Add(lead).After(t.start) {
+		run = t.start.Add(now.Add(lead).Sub(t.start) / t.interval * t.interval)
+	}
+	for _, start := range []time.Time{run, run.Add(-t.interval)} {
+		if start.Before(t.start) {
+			continue
+		}
+		end := start.Add(expected)
+		if !now.Before(start.Add(-lead)) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// scheduledTaskHold returns the reason and the message of holding the updates at now, if a run of any
+// of the cluster's tasks is expected to be running or about to run.
+func scheduledTaskHold(hold *v1alpha1.ManagerTaskHold, cluster *scyllav1.ScyllaCluster, now time.Time) (string, string, bool, error) {
+	tasks, err := scheduledTasks(cluster, managerTaskTypes(hold))
+	if err != nil {
+		return "", "", false, err
+	}
+
+	lead := time.Duration(0)
+	if hold.Lead != nil {
+		lead = hold.Lead.Duration
+	}
+	for i := range tasks {
+		t := &tasks[i]
+		if end, held := t.heldUntil(now, lead, hold.ExpectedDuration.Duration); held {
+			return taskHoldReason(t.taskType), fmt.Sprintf("Updates are held by %s task \"%s\" until %s.",
+				strings.ToLower(string(t.taskType)), t.name, end.UTC().Format(time.RFC3339)), true, nil
+		}
+	}
+
+	return "", "", false, nil
+}
+
+func managerTaskTypes(hold *v1alpha1.ManagerTaskHold) []v1alpha1.ManagerTaskType {
+	if len(hold.TaskTypes) == 0 {
+		return []v1alpha1.ManagerTaskType{v1alpha1.ManagerTaskTypeRepair, v1alpha1.ManagerTaskTypeBackup}
+	}
+	return hold.TaskTypes
+}
+
+func taskHoldReason(taskType v1alpha1.ManagerTaskType) string {
+	return string(taskType) + "Task"
+}
+
+// managerTask is a task of a cluster, as listed by Scylla Manager.
+type managerTask struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Type           string     `json:"type"`
+	Status         string     `json:"status"`
+	NextActivation *time.Time `json:"next_activation"`
+}
+
+// managerClient lists the tasks of the clusters registered in Scylla Manager through its REST API.
+type managerClient struct {
+	url    string
+	client *http.Client
+}
+
+func newManagerClient(url string, timeout time.Duration) *managerClient {
+	return &managerClient{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *managerClient) tasks(ctx context.Context, clusterID, taskType string) ([]managerTask, error) {
+	u := fmt.Sprintf("%s/api/v1/cluster/%s/tasks?type=%s", c.url, url.PathEscape(clusterID), url.QueryEscape(taskType))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var tasks []managerTask
+	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+		return nil, errors.Wrap(err, "decode tasks")
+	}
+	return tasks, nil
+}
+
+// managerTaskHold returns the reason and the message of holding the updates at now, if any of the cluster's tasks
+// listed by Scylla Manager is running, or its next run starts within the lead.
+func managerTaskHold(ctx context.Context, c *managerClient, clusterID string, hold *v1alpha1.ManagerTaskHold, now time.Time) (string, string, bool, error) {
+	lead := time.Duration(0)
+	if hold.Lead != nil {
+		lead = hold.Lead.Duration
+	}
+
+	for _, taskType := range managerTaskTypes(hold) {
+		tasks, err := c.tasks(ctx, clusterID, strings.ToLower(string(taskType)))
+		if err != nil {
+			return "", "", false, errors.Wrapf(err, "list %s tasks", strings.ToLower(string(taskType)))
+		}
+		for _, t := range tasks {
+			name := t.Name
+			if name == "" {
+				name = t.ID
+			}
+			if t.Status == "RUNNING" || t.Status == "STOPPING" {
+				return taskHoldReason(taskType), fmt.Sprintf("Updates are held by %s task \"%s\", which is running.",
+					strings.ToLower(string(taskType)), name), true, nil
+			}
+			if t.NextActivation != nil && !t.NextActivation.Before(now) && t.NextActivation.Before(now.Add(lead)) {
+				return taskHoldReason(taskType), fmt.Sprintf("Updates are held by %s task \"%s\", which starts at %s.",
+					strings.ToLower(string(taskType)), name, t.NextActivation.UTC().Format(time.RFC3339)), true, nil
+			}
+		}
+	}
+
+	return "", "", false, nil
+}
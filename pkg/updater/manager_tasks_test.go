@@ -0,0 +1,227 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseManagerDuration(t *testing.T) {
+	tests := []struct {
+		value         string
+		expected      time.Duration
+		errorExpected bool
+	}{
+		{value: "0"},
+		{value: "7d", expected: 7 * 24 * time.Hour},
+		{value: "3d2h10m", expected: 74*time.Hour + 10*time.Minute},
+		{value: "12h", expected: 12 * time.Hour},
+		{value: "1w", errorExpected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			d, err := parseManagerDuration(test.value)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, d)
+		})
+	}
+}
+
+func TestScheduledTaskHold(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	date := func(d time.Duration) *string {
+		s := now.Add(d).Format(time.RFC3339)
+		return &s
+	}
+	str := func(s string) *string {
+		return &s
+	}
+	hold := &v1alpha1.ManagerTaskHold{
+		ExpectedDuration: metav1.Duration{Duration: 2 * time.Hour},
+		Lead:             &metav1.Duration{Duration: 30 * time.Minute},
+	}
+
+	tests := []struct {
+		name            string
+		repairs         []scyllav1.RepairTaskSpec
+		repairStatuses  []scyllav1.RepairTaskStatus
+		backups         []scyllav1.BackupTaskSpec
+		hold            *v1alpha1.ManagerTaskHold
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "one-off repair running",
+			repairs:         []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: date(-time.Hour)}}},
+			hold:            hold,
+			expectedReason:  "RepairTask",
+			expectedMessage: "Updates are held by repair task \"repair\" until 2021-06-07T13:00:00Z.",
+		},
+		{
+			name:    "one-off repair over",
+			repairs: []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: date(-3 * time.Hour)}}},
+			hold:    hold,
+		},
+		{
+			name:            "repair within lead",
+			repairs:         []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: date(20 * time.Minute)}}},
+			hold:            hold,
+			expectedReason:  "RepairTask",
+			expectedMessage: "Updates are held by repair task \"repair\" until 2021-06-07T14:20:00Z.",
+		},
+		{
+			name:    "repair beyond lead",
+			repairs: []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: date(time.Hour)}}},
+			hold:    hold,
+		},
+		{
+			name: "recurring backup running",
+			backups: []scyllav1.BackupTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{
+				Name: "backup", StartDate: date(-3*24*time.Hour - time.Hour), Interval: str("1d"),
+			}}},
+			hold:            hold,
+			expectedReason:  "BackupTask",
+			expectedMessage: "Updates are held by backup task \"backup\" until 2021-06-07T13:00:00Z.",
+		},
+		{
+			name: "recurring backup between runs",
+			backups: []scyllav1.BackupTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{
+				Name: "backup", StartDate: date(-3*24*time.Hour - 6*time.Hour), Interval: str("1d"),
+			}}},
+			hold: hold,
+		},
+		{
+			name: "frequent repair running longer than its interval",
+			repairs: []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{
+				Name: "repair", StartDate: date(-5*time.Hour - 30*time.Minute), Interval: str("3h"),
+			}}},
+			hold:            &v1alpha1.ManagerTaskHold{ExpectedDuration: metav1.Duration{Duration: 4 * time.Hour}},
+			expectedReason:  "RepairTask",
+			expectedMessage: "Updates are held by repair task \"repair\" until 2021-06-07T13:30:00Z.",
+		},
+		{
+			name: "backups not holding updates",
+			backups: []scyllav1.BackupTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{
+				Name: "backup", StartDate: date(-time.Hour),
+			}}},
+			hold: &v1alpha1.ManagerTaskHold{
+				TaskTypes:        []v1alpha1.ManagerTaskType{v1alpha1.ManagerTaskTypeRepair},
+				ExpectedDuration: metav1.Duration{Duration: 2 * time.Hour},
+			},
+		},
+		{
+			name:    "relative start resolved in status",
+			repairs: []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: str("now+1d")}}},
+			repairStatuses: []scyllav1.RepairTaskStatus{
+				{RepairTaskSpec: scyllav1.RepairTaskSpec{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: date(-time.Hour)}}},
+			},
+			hold:            hold,
+			expectedReason:  "RepairTask",
+			expectedMessage: "Updates are held by repair task \"repair\" until 2021-06-07T13:00:00Z.",
+		},
+		{
+			name:    "relative start not resolved",
+			repairs: []scyllav1.RepairTaskSpec{{SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "repair", StartDate: str("now")}}},
+			hold:    hold,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &scyllav1.ScyllaCluster{
+				Spec:   scyllav1.ClusterSpec{Repairs: test.repairs, Backups: test.backups},
+				Status: scyllav1.ClusterStatus{Repairs: test.repairStatuses},
+			}
+			reason, message, held, err := scheduledTaskHold(test.hold, cluster, now)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedReason != "", held)
+			require.Equal(t, test.expectedReason, reason)
+			require.Equal(t, test.expectedMessage, message)
+		})
+	}
+}
+
+func TestManagerTaskHold(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	inTenMinutes := now.Add(10 * time.Minute)
+	inADay := now.Add(24 * time.Hour)
+	hold := &v1alpha1.ManagerTaskHold{
+		ExpectedDuration: metav1.Duration{Duration: 2 * time.Hour},
+		Lead:             &metav1.Duration{Duration: 30 * time.Minute},
+	}
+
+	tests := []struct {
+		name            string
+		tasks           map[string][]managerTask
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name: "repair running",
+			tasks: map[string][]managerTask{
+				"repair": {{ID: "1", Name: "weekly", Type: "repair", Status: "RUNNING", NextActivation: &inADay}},
+			},
+			expectedReason:  "RepairTask",
+			expectedMessage: "Updates are held by repair task \"weekly\", which is running.",
+		},
+		{
+			name: "backup within lead",
+			tasks: map[string][]managerTask{
+				"backup": {{ID: "2", Type: "backup", Status: "DONE", NextActivation: &inTenMinutes}},
+			},
+			expectedReason:  "BackupTask",
+			expectedMessage: "Updates are held by backup task \"2\", which starts at 2021-06-07T12:10:00Z.",
+		},
+		{
+			name: "no task running or about to run",
+			tasks: map[string][]managerTask{
+				"repair": {{ID: "1", Name: "weekly", Type: "repair", Status: "DONE", NextActivation: &inADay}},
+				"backup": {{ID: "2", Name: "daily", Type: "backup", Status: "ERROR"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/api/v1/cluster/cluster-id/tasks", r.URL.Path)
+				tasks := test.tasks[r.URL.Query().Get("type")]
+				if tasks == nil {
+					tasks = []managerTask{}
+				}
+				require.NoError(t, json.NewEncoder(w).Encode(tasks))
+			}))
+			defer s.Close()
+
+			reason, message, held, err := managerTaskHold(context.Background(), newManagerClient(s.URL, time.Second), "cluster-id", hold, now)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedReason != "", held)
+			require.Equal(t, test.expectedReason, reason)
+			require.Equal(t, test.expectedMessage, message)
+		})
+	}
+}
+
+func TestManagerTaskHoldFailure(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	hold := &v1alpha1.ManagerTaskHold{ExpectedDuration: metav1.Duration{Duration: time.Hour}}
+	_, _, _, err := managerTaskHold(context.Background(), newManagerClient(s.URL, time.Second), "cluster-id", hold, time.Now())
+	require.Error(t, err)
+}
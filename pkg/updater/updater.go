@@ -2,30 +2,53 @@ package updater
 
 import (
 	"context"
+	"fmt"
+	"github.com/pkg/errors"
 	"github.com/scylladb/go-log"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
 	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
 	"time"
 )
 
+// scaledResources are the resources of the racks the recommendations are applied to.
+var scaledResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
 type Updater interface {
 	RunOnce(ctx context.Context) error
 }
 
+type Options struct {
+	// ScyllaManagerURL is the URL of the Scylla Manager REST API queried for the status of the targets' tasks.
+	// If empty, the tasks are assumed to run as scheduled in the targets' specs.
+	ScyllaManagerURL string
+
+	// ScyllaManagerTimeout is the timeout of the queries to Scylla Manager, no timeout if 0.
+	ScyllaManagerTimeout time.Duration
+}
+
 type updater struct {
-	client client.Client
-	logger log.Logger
+	client  client.Client
+	logger  log.Logger
+	manager *managerClient
 }
 
-func NewUpdater(c client.Client, logger log.Logger) Updater {
-	return &updater{
+func NewUpdater(c client.Client, opts Options, logger log.Logger) Updater {
+	u := &updater{
 		client: c,
 		logger: logger,
 	}
+	if opts.ScyllaManagerURL != "" {
+		u.manager = newManagerClient(opts.ScyllaManagerURL, opts.ScyllaManagerTimeout)
+	}
+	return u
 }
 
 func (u *updater) RunOnce(ctx context.Context) error {
@@ -37,6 +60,17 @@ func (u *updater) RunOnce(ctx context.Context) error {
 	filteredSCAs := filterSCAs(scas)
 	for idx := range filteredSCAs {
 		sca := &filteredSCAs[idx]
+		cluster, err := u.fetchScyllaCluster(ctx, sca.Spec.TargetRef.Name, sca.Spec.TargetRef.Namespace)
+		if err != nil {
+			return err
+		}
+		if held, err := u.holdUpdates(ctx, sca, cluster, time.Now()); err != nil {
+			u.logger.Error(ctx, "skipping update: check blackout windows, freeze periods and Scylla Manager tasks",
+				"sca", sca.Name, "namespace", sca.Namespace, "error", err)
+			continue
+		} else if held {
+			continue
+		}
 		if recommendationExpired(sca) {
 			u.logger.Info(ctx, "skipping update: sca's recommendation expired",
 				"sca", sca.Name, "namespace", sca.Namespace)
@@ -48,9 +82,11 @@ func (u *updater) RunOnce(ctx context.Context) error {
 			continue
 		}
 
-		cluster, err := u.fetchScyllaCluster(ctx, sca.Spec.TargetRef.Name, sca.Spec.TargetRef.Namespace)
-		if err != nil {
-			return err
+		pause := util.PauseFromAnnotations(time.Now(), sca, cluster)
+		if pause.Paused {
+			u.logger.Info(ctx, "skipping update: autoscaling paused",
+				"sca", sca.Name, "namespace", sca.Namespace)
+			continue
 		}
 		if equalChecksums, err := equalChecksums(cluster, sca); err != nil {
 			return err
@@ -59,9 +95,13 @@ func (u *updater) RunOnce(ctx context.Context) error {
 				"sca", sca.Name, "namespace", sca.Namespace)
 			continue
 		}
-		if !isScyllaClusterReady(cluster) {
+		readiness, err := util.EvaluateTargetReadiness(ctx, u.client, cluster)
+		if err != nil {
+			return err
+		}
+		if !readiness.Ready {
 			u.logger.Info(ctx, "skipping update: scylla cluster isn't ready",
-				"sca", sca.Name, "namespace", sca.Namespace)
+				"sca", sca.Name, "namespace", sca.Namespace, "reason", readiness.Reason, "rack", readiness.Rack)
 			continue
 		}
 
@@ -78,6 +118,22 @@ func (u *updater) RunOnce(ctx context.Context) error {
 			continue
 		}
 
+		approved, err := recommendationApproved(sca, time.Now())
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		horizontalActions := recentActions(sca.Status.RecentHorizontalActions, sca.Spec.UpdatePolicy.HorizontalActionBudget, now)
+		verticalActions := recentActions(sca.Status.RecentVerticalActions, sca.Spec.UpdatePolicy.VerticalActionBudget, now)
+		budgets := &exhaustedBudgets{
+			horizontal: budgetExhausted(horizontalActions, sca.Spec.UpdatePolicy.HorizontalActionBudget),
+			vertical:   budgetExhausted(verticalActions, sca.Spec.UpdatePolicy.VerticalActionBudget),
+		}
+
+		horizontal, vertical, awaitingApproval := false, false, false
+		var changedRacks []string
+		var unappliedRackRecs []v1alpha1.RackRecommendations
 		for j := range rackRecs {
 			rackRec := &rackRecs[j]
 			rack := findRack(rackRec.Name, cluster.Spec.Datacenter.Racks)
@@ -86,11 +142,71 @@ func (u *updater) RunOnce(ctx context.Context) error {
 					"rack", rackRec.Name, "cluster", cluster.Name, "data center", cluster.Spec.Datacenter.Name)
 				continue
 			}
+			if pause.RackPaused(rackRec.Name) {
+				u.logger.Info(ctx, "skipping rack update: autoscaling paused",
+					"sca", sca.Name, "namespace", sca.Namespace, "rack", rackRec.Name)
+				continue
+			}
+
+			permitted, unapplied, awaiting := splitRackRec(rack, rackRec, sca.Spec.UpdatePolicy, approved, budgets)
+			if unapplied.Members != nil || unapplied.Resources != nil {
+				unappliedRackRecs = append(unappliedRackRecs, unapplied)
+			}
+			awaitingApproval = awaitingApproval || awaiting
+
+			h, v := applyRackRec(rack, &permitted)
+			if h || v {
+				changedRacks = append(changedRacks, rack.Name)
+			}
+			horizontal = horizontal || h
+			vertical = vertical || v
+		}
+
+		var unappliedRecs *v1alpha1.ScyllaClusterRecommendations
+		if len(unappliedRackRecs) > 0 {
+			unappliedRecs = &v1alpha1.ScyllaClusterRecommendations{
+				DatacenterRecommendations: []v1alpha1.DatacenterRecommendations{
+					{Name: dataCenterName, RackRecommendations: unappliedRackRecs},
+				},
+			}
+		}
+		if budgets.heldHorizontal || budgets.heldVertical {
+			u.logger.Info(ctx, "holding back changes: action budget exhausted",
+				"sca", sca.Name, "namespace", sca.Namespace, "horizontal", budgets.heldHorizontal, "vertical", budgets.heldVertical)
+		}
+		budgetConditionChanged := setBudgetHoldCondition(sca, budgets, horizontalActions, verticalActions)
+		if unappliedRecs != nil && !horizontal && !vertical {
+			u.logger.Info(ctx, "skipping update: update modes or action budgets don't permit applying recommendation",
+				"sca", sca.Name, "namespace", sca.Namespace, "awaiting approval", awaitingApproval)
+			if budgetConditionChanged || !equality.Semantic.DeepEqual(sca.Status.UnappliedRecommendations, unappliedRecs) {
+				sca.Status.UnappliedRecommendations = unappliedRecs
+				if err = u.client.Status().Update(ctx, sca); err != nil {
+					return err
+				}
+			}
+			continue
+		}
 
-			applyRackRec(rack, rackRec)
+		if horizontal && sca.Spec.UpdatePolicy.HorizontalActionBudget != nil {
+			horizontalActions = append(horizontalActions, metav1.NewTime(now))
+		}
+		if vertical && sca.Spec.UpdatePolicy.VerticalActionBudget != nil {
+			verticalActions = append(verticalActions, metav1.NewTime(now))
+		}
+		sca.Status.RecentHorizontalActions = horizontalActions
+		sca.Status.RecentVerticalActions = verticalActions
+		sca.Status.UnappliedRecommendations = unappliedRecs
+		sca.Status.LastAppliedRacks = changedRacks
+
+		// Recommendations awaiting approval have to be looked at again, so they are not marked as applied.
+		appliedRecs := sca.Status.Recommendations
+		if awaitingApproval {
+			appliedRecs = nil
+		} else {
+			sca.Status.PendingRecommendation = nil
 		}
 
-		if err = u.updateScyllaCluster(ctx, cluster, sca.Status.Recommendations); err != nil {
+		if err = u.updateScyllaCluster(ctx, cluster, appliedRecs); err != nil {
 			return err
 		}
 		if err = u.updateSCAStatus(ctx, sca); err != nil {
@@ -104,7 +220,7 @@ func (u *updater) RunOnce(ctx context.Context) error {
 func filterSCAs(scas *v1alpha1.ScyllaClusterAutoscalerList) []v1alpha1.ScyllaClusterAutoscaler {
 	filteredSCAs := make([]v1alpha1.ScyllaClusterAutoscaler, 0)
 	for _, sca := range scas.Items {
-		if sca.Spec.UpdatePolicy != nil && sca.Spec.UpdatePolicy.UpdateMode == v1alpha1.UpdateModeAuto &&
+		if sca.Spec.UpdatePolicy != nil && util.UpdatesEnabled(sca.Spec.UpdatePolicy) &&
 			sca.Status.UpdateStatus != nil && *sca.Status.UpdateStatus == v1alpha1.UpdateStatusOk {
 			filteredSCAs = append(filteredSCAs, sca)
 		}
@@ -113,18 +229,231 @@ func filterSCAs(scas *v1alpha1.ScyllaClusterAutoscalerList) []v1alpha1.ScyllaClu
 	return filteredSCAs
 }
 
+// holdUpdates tells whether the SCA's updates are held at now by any of its blackout windows or freeze periods,
+// or the target's Scylla Manager tasks, and reflects it in the SCA's UpdatesHeld condition.
+func (u *updater) holdUpdates(ctx context.Context, sca *v1alpha1.ScyllaClusterAutoscaler, cluster *scyllav1.ScyllaCluster, now time.Time) (bool, error) {
+	reason, message, held, err := activeHold(sca.Spec.UpdatePolicy, now)
+	if err != nil {
+		return false, err
+	}
+	if !held {
+		if reason, message, held, err = u.activeTaskHold(ctx, sca.Spec.UpdatePolicy.ManagerTaskHold, cluster, now); err != nil {
+			return false, err
+		}
+	}
+
+	if !held {
+		// Holds by the action budgets are reflected once the recommendations are split.
+		if c := meta.FindStatusCondition(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition); c == nil ||
+			c.Status != metav1.ConditionTrue || budgetHoldReason(c.Reason) {
+			return false, nil
+		}
+		meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+			Type:               v1alpha1.UpdatesHeldCondition,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: sca.Generation,
+			Reason:             "NoActiveWindow",
+			Message:            "No blackout window, freeze period or Scylla Manager task is active.",
+		})
+		return false, u.client.Status().Update(ctx, sca)
+	}
+
+	u.logger.Info(ctx, "skipping update: updates held", "sca", sca.Name, "namespace", sca.Namespace, "reason", reason)
+	if c := meta.FindStatusCondition(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition); c != nil &&
+		c.Status == metav1.ConditionTrue && c.Reason == reason && c.Message == message {
+		return true, nil
+	}
+	meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.UpdatesHeldCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: sca.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	return true, u.client.Status().Update(ctx, sca)
+}
+
+// activeHold returns the reason and the message of holding the updates at now, if any of the policy's
+// freeze periods or blackout windows is active.
+func activeHold(updatePolicy *v1alpha1.UpdatePolicy, now time.Time) (string, string, bool, error) {
+	for _, p := range updatePolicy.FreezePeriods {
+		if !now.Before(p.Start.Time) && now.Before(p.End.Time) {
+			return "FreezePeriod", fmt.Sprintf("Updates are held by freeze period \"%s\" until %s.",
+				p.Name, p.End.UTC().Format(time.RFC3339)), true, nil
+		}
+	}
+
+	for _, w := range updatePolicy.BlackoutWindows {
+		end, active, err := util.CronWindowEnd(w.Schedule, w.TimeZone, w.Duration.Duration, now)
+		if err != nil {
+			return "", "", false, errors.Wrapf(err, "blackout window \"%s\"", w.Name)
+		}
+		if active {
+			return "BlackoutWindow", fmt.Sprintf("Updates are held by blackout window \"%s\" until %s.",
+				w.Name, end.UTC().Format(time.RFC3339)), true, nil
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// activeTaskHold returns the reason and the message of holding the updates at now, if any of the cluster's
+// Scylla Manager tasks is running or about to run. The status of the tasks is queried from Scylla Manager
+// if it's configured and the cluster is registered in it, and their schedules are relied on otherwise.
+func (u *updater) activeTaskHold(ctx context.Context, hold *v1alpha1.ManagerTaskHold, cluster *scyllav1.ScyllaCluster, now time.Time) (string, string, bool, error) {
+	if hold == nil {
+		return "", "", false, nil
+	}
+
+	if u.manager != nil && cluster.Status.ManagerID != nil {
+		reason, message, held, err := managerTaskHold(ctx, u.manager, *cluster.Status.ManagerID, hold, now)
+		if err == nil {
+			return reason, message, held, nil
+		}
+		u.logger.Error(ctx, "query Scylla Manager tasks, falling back to their schedules",
+			"cluster", cluster.Name, "namespace", cluster.Namespace, "error", err)
+	}
+
+	return scheduledTaskHold(hold, cluster, now)
+}
+
 func recommendationExpired(sca *v1alpha1.ScyllaClusterAutoscaler) bool {
 	recExpTime := sca.Spec.UpdatePolicy.RecommendationExpirationTime
 	return !sca.Status.LastUpdated.IsZero() && recExpTime != nil &&
 		time.Now().Sub(sca.Status.LastUpdated.Time) > recExpTime.Duration
 }
 
+// recommendationApproved tells whether the SCA's pending recommendation is approved with ApprovedRecommendationAnnotation,
+// hasn't expired, and still refers to the SCA's recommendations.
+func recommendationApproved(sca *v1alpha1.ScyllaClusterAutoscaler, now time.Time) (bool, error) {
+	pending := sca.Status.PendingRecommendation
+	if pending == nil || sca.Status.Recommendations == nil ||
+		sca.Annotations[v1alpha1.ApprovedRecommendationAnnotation] != pending.ID ||
+		util.PendingRecommendationExpired(pending, sca.Spec.UpdatePolicy.RecommendationExpirationTime, now) {
+		return false, nil
+	}
+
+	id, err := util.PendingRecommendationID(sca.Status.Recommendations, pending.PublishedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return id == pending.ID, nil
+}
+
 func updateCooldownExceeded(sca *v1alpha1.ScyllaClusterAutoscaler) bool {
 	updateCooldown := sca.Spec.UpdatePolicy.UpdateCooldown
 	return sca.Status.LastApplied.IsZero() || updateCooldown == nil ||
 		time.Now().Sub(sca.Status.LastApplied.Time) >= updateCooldown.Duration
 }
 
+// recentActions returns the actions performed within the budget's window.
+// If there is no budget, the actions aren't tracked.
+func recentActions(actions []metav1.Time, budget *v1alpha1.ActionBudget, now time.Time) []metav1.Time {
+	if budget == nil {
+		return nil
+	}
+
+	var recent []metav1.Time
+	for _, a := range actions {
+		if now.Sub(a.Time) < budget.Window.Duration {
+			recent = append(recent, a)
+		}
+	}
+
+	return recent
+}
+
+func budgetExhausted(recentActions []metav1.Time, budget *v1alpha1.ActionBudget) bool {
+	return budget != nil && int32(len(recentActions)) >= budget.MaxActions
+}
+
+// budgetAvailableAt returns the time an action is available again in the exhausted budget,
+// i.e. when enough of the oldest recent actions leave its window.
+func budgetAvailableAt(recentActions []metav1.Time, budget *v1alpha1.ActionBudget) time.Time {
+	actions := append([]metav1.Time(nil), recentActions...)
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Before(&actions[j])
+	})
+	return actions[int32(len(actions))-budget.MaxActions].Add(budget.Window.Duration)
+}
+
+const (
+	horizontalBudgetExhaustedReason = "HorizontalBudgetExhausted"
+	verticalBudgetExhaustedReason   = "VerticalBudgetExhausted"
+)
+
+// exhaustedBudgets tells which of the action budgets are exhausted, and which of them held back changes
+// of the recommendations.
+type exhaustedBudgets struct {
+	horizontal, vertical         bool
+	heldHorizontal, heldVertical bool
+}
+
+// holds tells whether the budget of the dimension is exhausted, and records that it held back a change if so.
+func (eb *exhaustedBudgets) holds(horizontal bool) bool {
+	if horizontal && eb.horizontal {
+		eb.heldHorizontal = true
+		return true
+	}
+	if !horizontal && eb.vertical {
+		eb.heldVertical = true
+		return true
+	}
+	return false
+}
+
+func budgetHoldReason(reason string) bool {
+	return reason == horizontalBudgetExhaustedReason || reason == verticalBudgetExhaustedReason
+}
+
+// setBudgetHoldCondition reflects the changes held back by the exhausted action budgets in the SCA's UpdatesHeld
+// condition, unless it's held by anything else, and reports whether the condition changed.
+func setBudgetHoldCondition(sca *v1alpha1.ScyllaClusterAutoscaler, budgets *exhaustedBudgets, horizontalActions, verticalActions []metav1.Time) bool {
+	var reason string
+	var messages []string
+	if budgets.heldHorizontal {
+		reason = horizontalBudgetExhaustedReason
+		messages = append(messages, fmt.Sprintf("Changes of members are held until %s by the horizontal action budget.",
+			budgetAvailableAt(horizontalActions, sca.Spec.UpdatePolicy.HorizontalActionBudget).UTC().Format(time.RFC3339)))
+	}
+	if budgets.heldVertical {
+		if reason == "" {
+			reason = verticalBudgetExhaustedReason
+		}
+		messages = append(messages, fmt.Sprintf("Changes of resources are held until %s by the vertical action budget.",
+			budgetAvailableAt(verticalActions, sca.Spec.UpdatePolicy.VerticalActionBudget).UTC().Format(time.RFC3339)))
+	}
+
+	c := meta.FindStatusCondition(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition)
+	if reason == "" {
+		if c == nil || c.Status != metav1.ConditionTrue || !budgetHoldReason(c.Reason) {
+			return false
+		}
+		meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+			Type:               v1alpha1.UpdatesHeldCondition,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: sca.Generation,
+			Reason:             "BudgetAvailable",
+			Message:            "No changes are held by the action budgets.",
+		})
+		return true
+	}
+
+	message := strings.Join(messages, " ")
+	if c != nil && c.Status == metav1.ConditionTrue && c.Reason == reason && c.Message == message {
+		return false
+	}
+	meta.SetStatusCondition(&sca.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.UpdatesHeldCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: sca.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	return true
+}
+
 func (u *updater) fetchScyllaCluster(ctx context.Context, name, namespace string) (*scyllav1.ScyllaCluster, error) {
 	cluster := &scyllav1.ScyllaCluster{}
 	if err := u.client.Get(ctx, client.ObjectKey{
@@ -139,7 +468,7 @@ func (u *updater) fetchScyllaCluster(ctx context.Context, name, namespace string
 
 func equalChecksums(cluster *scyllav1.ScyllaCluster, sca *v1alpha1.ScyllaClusterAutoscaler) (bool, error) {
 	if labels := cluster.ObjectMeta.Labels; labels != nil && sca.Status.Recommendations != nil {
-		if latestChecksum, ok := labels["sca-latest-checksum"]; ok {
+		if latestChecksum, ok := labels[util.LatestChecksumLabel]; ok {
 			if newChecksum, err := util.NewChecksum(*sca.Status.Recommendations); err != nil {
 				return false, err
 			} else {
@@ -151,17 +480,6 @@ func equalChecksums(cluster *scyllav1.ScyllaCluster, sca *v1alpha1.ScyllaCluster
 	return false, nil
 }
 
-func isScyllaClusterReady(cluster *scyllav1.ScyllaCluster) bool {
-	for _, rack := range cluster.Spec.Datacenter.Racks {
-		rackStatus, found := cluster.Status.Racks[rack.Name]
-		if !found || rackStatus.Members != rackStatus.ReadyMembers {
-			return false
-		}
-	}
-
-	return true
-}
-
 func getDatacenterRecommendations(sca *v1alpha1.ScyllaClusterAutoscaler) []v1alpha1.DatacenterRecommendations {
 	if sca.Status.Recommendations == nil {
 		return nil
@@ -190,30 +508,126 @@ func findRack(rackName string, racks []scyllav1.RackSpec) *scyllav1.RackSpec {
 	return nil
 }
 
-func applyRackRec(rack *scyllav1.RackSpec, rackRec *v1alpha1.RackRecommendations) {
+// applyRackRec applies the recommendation to the rack and reports whether it changed the rack's members
+// and resources respectively.
+func applyRackRec(rack *scyllav1.RackSpec, rackRec *v1alpha1.RackRecommendations) (bool, bool) {
+	horizontal, vertical := false, false
 	if rackRec.Members != nil {
+		horizontal = rack.Members != *rackRec.Members
 		rack.Members = *rackRec.Members
 	}
 	if rackRec.Resources != nil {
-		if CpuLimitRec, ok := rackRec.Resources.Limits[corev1.ResourceCPU]; ok {
-			rack.Resources.Limits[corev1.ResourceCPU] = CpuLimitRec
+		for _, name := range scaledResources {
+			if applyResourceRec(&rack.Resources.Limits, rackRec.Resources.Limits, name) {
+				vertical = true
+			}
+			if applyResourceRec(&rack.Resources.Requests, rackRec.Resources.Requests, name) {
+				vertical = true
+			}
+		}
+	}
+
+	return horizontal, vertical
+}
+
+// applyResourceRec sets the recommended quantity of the resource, if any, and reports whether it changed.
+func applyResourceRec(current *corev1.ResourceList, rec corev1.ResourceList, name corev1.ResourceName) bool {
+	quantity, ok := rec[name]
+	if !ok {
+		return false
+	}
+
+	if *current == nil {
+		*current = corev1.ResourceList{}
+	}
+	old, ok := (*current)[name]
+	(*current)[name] = quantity
+	return !ok || !old.Equal(quantity)
+}
+
+// splitRackRec splits the rack's recommendation into the part permitted to be applied by the update modes
+// of its dimensions and directions, and the rest. Parts in the "Approval" update mode are only permitted if approved,
+// and reported as awaiting approval otherwise. Parts of the dimensions whose action budgets are exhausted are held back,
+// and approved ones are kept awaiting approval, so that they are applied once the budget permits.
+func splitRackRec(rack *scyllav1.RackSpec, rackRec *v1alpha1.RackRecommendations, updatePolicy *v1alpha1.UpdatePolicy,
+	approved bool, budgets *exhaustedBudgets) (v1alpha1.RackRecommendations, v1alpha1.RackRecommendations, bool) {
+	permitted := v1alpha1.RackRecommendations{Name: rackRec.Name}
+	unapplied := v1alpha1.RackRecommendations{Name: rackRec.Name}
+	awaitingApproval := false
+	permits := func(horizontal, scaleUp bool) bool {
+		switch util.EffectiveUpdateMode(updatePolicy, horizontal, scaleUp) {
+		case v1alpha1.UpdateModeAuto:
+			return !budgets.holds(horizontal)
+		case v1alpha1.UpdateModeApproval:
+			if approved && budgets.holds(horizontal) {
+				awaitingApproval = true
+				return false
+			}
+			awaitingApproval = awaitingApproval || !approved
+			return approved
+		default:
+			return false
+		}
+	}
+
+	if rackRec.Members != nil {
+		if *rackRec.Members == rack.Members || permits(true, *rackRec.Members > rack.Members) {
+			permitted.Members = rackRec.Members
+		} else {
+			unapplied.Members = rackRec.Members
+		}
+	}
+
+	if rackRec.Resources != nil {
+		if cmp, changed := compareResources(&rack.Resources, rackRec.Resources); !changed || permits(false, cmp > 0) {
+			permitted.Resources = rackRec.Resources
+		} else {
+			unapplied.Resources = rackRec.Resources
 		}
-		if CpuRequestRec, ok := rackRec.Resources.Requests[corev1.ResourceCPU]; ok {
-			rack.Resources.Requests[corev1.ResourceCPU] = CpuRequestRec
+	}
+
+	return permitted, unapplied, awaitingApproval
+}
+
+// compareResources compares the recommended resources with the current ones, in the order of scaledResources.
+// The first resource changed by the recommendation determines the result, compared by requests if they are recommended,
+// and by limits otherwise. It returns false if the recommendation doesn't change any of the resources.
+func compareResources(current, rec *corev1.ResourceRequirements) (int, bool) {
+	for _, name := range scaledResources {
+		recommended, ok := rec.Requests[name]
+		currentQuantity := current.Requests[name]
+		if !ok {
+			if recommended, ok = rec.Limits[name]; !ok {
+				continue
+			}
+			currentQuantity = current.Limits[name]
+		}
+
+		if cmp := recommended.Cmp(currentQuantity); cmp != 0 {
+			return cmp, true
 		}
 	}
+
+	return 0, false
 }
 
+// updateScyllaCluster updates the cluster, marking the recommendations as applied.
+// If recs is nil, no recommendations are marked as applied.
 func (u *updater) updateScyllaCluster(ctx context.Context, cluster *scyllav1.ScyllaCluster,
 	recs *v1alpha1.ScyllaClusterRecommendations) error {
+	if recs == nil {
+		delete(cluster.ObjectMeta.Labels, util.LatestChecksumLabel)
+		return u.client.Update(ctx, cluster)
+	}
+
 	newChecksum, err := util.NewChecksum(*recs)
 	if err != nil {
 		return err
 	}
 	if cluster.ObjectMeta.Labels == nil {
-		cluster.ObjectMeta.Labels = map[string]string{"sca-latest-checksum": newChecksum}
+		cluster.ObjectMeta.Labels = map[string]string{util.LatestChecksumLabel: newChecksum}
 	} else {
-		cluster.ObjectMeta.Labels["sca-latest-checksum"] = newChecksum
+		cluster.ObjectMeta.Labels[util.LatestChecksumLabel] = newChecksum
 	}
 
 	if err = u.client.Update(ctx, cluster); err != nil {
@@ -6,10 +6,13 @@ import (
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
 	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
 	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/scylladb/scylla-operator/pkg/naming"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -41,11 +44,12 @@ func TestUpdater(t *testing.T) {
 	c := clientBuilder.Build()
 	atom := zap.NewAtomicLevelAt(zapcore.DebugLevel)
 	logger, _ := log.NewProduction(log.Config{Level: atom})
-	u := NewUpdater(c, logger)
+	u := NewUpdater(c, Options{}, logger)
 	ctx := context.Background()
 
 	autoUpdateMode := v1alpha1.UpdateModeAuto
 	offUpdateMode := v1alpha1.UpdateModeOff
+	approvalUpdateMode := v1alpha1.UpdateModeApproval
 	updateStatusOk := v1alpha1.UpdateStatusOk
 	basicTestClusterMeta := &metav1.ObjectMeta{
 		Name:      "test-cluster",
@@ -75,6 +79,26 @@ func TestUpdater(t *testing.T) {
 			corev1.ResourceCPU: *resource.NewQuantity(456, resource.DecimalSI),
 		},
 	}
+	testMemoryResources := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewQuantity(456, resource.DecimalSI),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewQuantity(123, resource.DecimalSI),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	testMemoryResourcesRecommendation := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewQuantity(456, resource.DecimalSI),
+			corev1.ResourceMemory: resource.MustParse("2Gi"),
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewQuantity(123, resource.DecimalSI),
+			corev1.ResourceMemory: resource.MustParse("2Gi"),
+		},
+	}
 	testChecksum, err := util.NewChecksum(v1alpha1.ScyllaClusterRecommendations{
 		DatacenterRecommendations: []v1alpha1.DatacenterRecommendations{
 			{
@@ -93,11 +117,52 @@ func TestUpdater(t *testing.T) {
 	testRecExpTime := metav1.Duration{Duration: time.Hour}
 	testUpdateCooldown := metav1.Duration{Duration: time.Minute * 20}
 	testLastAppliedTimestamp := metav1.NewTime(time.Now().Add(time.Minute * time.Duration(-10)))
+	testActionBudget := &v1alpha1.ActionBudget{MaxActions: 2, Window: metav1.Duration{Duration: time.Hour * 24}}
+	testRecentActions := []metav1.Time{
+		metav1.NewTime(time.Now().Add(time.Hour * time.Duration(-12))),
+		metav1.NewTime(time.Now().Add(time.Hour * time.Duration(-2))),
+	}
+	testActiveBlackoutWindows := []v1alpha1.BlackoutWindow{
+		{Name: "always", Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}},
+	}
+	testActiveFreezePeriods := []v1alpha1.FreezePeriod{
+		{Name: "freeze", Start: metav1.NewTime(time.Now().Add(-time.Hour)), End: metav1.NewTime(time.Now().Add(time.Hour))},
+	}
+	testPastFreezePeriods := []v1alpha1.FreezePeriod{
+		{Name: "freeze", Start: metav1.NewTime(time.Now().Add(-time.Hour * 2)), End: metav1.NewTime(time.Now().Add(-time.Hour))},
+	}
+	testManagerTaskHold := &v1alpha1.ManagerTaskHold{
+		TaskTypes:        []v1alpha1.ManagerTaskType{v1alpha1.ManagerTaskTypeRepair},
+		ExpectedDuration: metav1.Duration{Duration: 3 * time.Hour},
+	}
+	// Weekly tasks which started an hour ago.
+	testTaskStart := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	testTaskInterval := "7d"
+	testRunningRepair := scyllav1.RepairTaskSpec{
+		SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "weekly repair", StartDate: &testTaskStart, Interval: &testTaskInterval},
+	}
+	testRunningBackup := scyllav1.BackupTaskSpec{
+		SchedulerTaskSpec: scyllav1.SchedulerTaskSpec{Name: "weekly backup", StartDate: &testTaskStart, Interval: &testTaskInterval},
+	}
+	// The timestamps are stored with a precision of a second, so they have to be truncated for the IDs to match.
+	testPublishedAt := metav1.NewTime(time.Now().Add(-time.Minute * 10).Truncate(time.Second))
+	testExpiredPublishedAt := metav1.NewTime(time.Now().Add(-time.Hour * 2).Truncate(time.Second))
+	testRecentLastUpdated := metav1.NewTime(time.Now())
+	testOutdatedActions := []metav1.Time{
+		metav1.NewTime(time.Now().Add(time.Hour * time.Duration(-36))),
+		metav1.NewTime(time.Now().Add(time.Hour * time.Duration(-2))),
+	}
 	tests := []struct {
-		Name           string
-		ScyllaCluster  *scyllav1.ScyllaCluster
-		Sca            *v1alpha1.ScyllaClusterAutoscaler
-		ExpectedStates []ExpectedStateSpec
+		Name                      string
+		ScyllaCluster             *scyllav1.ScyllaCluster
+		Sca                       *v1alpha1.ScyllaClusterAutoscaler
+		ExpectedStates            []ExpectedStateSpec
+		ExpectedHorizontalActions int
+		ExpectedVerticalActions   int
+		ExpectedUpdatesHeld       bool
+		ExpectedUpdatesHeldReason string
+		ExpectedUnappliedRacks    []string
+		ExpectedLastAppliedRacks  []string
 	}{
 		{
 			Name: "applied recommendation",
@@ -116,6 +181,7 @@ func TestUpdater(t *testing.T) {
 			ExpectedStates: []ExpectedStateSpec{
 				{RackName: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
 			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
 		},
 		{
 			Name: "off mode sca",
@@ -170,6 +236,7 @@ func TestUpdater(t *testing.T) {
 			ExpectedStates: []ExpectedStateSpec{
 				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
 			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
 		},
 		{
 			Name: "equal checksums",
@@ -245,6 +312,466 @@ func TestUpdater(t *testing.T) {
 				{RackName: "test-rack-1", Members: util.Int32ptr(2)},
 			},
 		},
+		{
+			Name: "horizontal action budget exhausted",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setActionBudgets(testActionBudget, nil, testRecentActions, nil,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+			ExpectedHorizontalActions: 2,
+			ExpectedUpdatesHeld:       true,
+			ExpectedUpdatesHeldReason: horizontalBudgetExhaustedReason,
+			ExpectedUnappliedRacks:    []string{"test-rack-1"},
+		},
+		{
+			Name: "horizontal action budget holds back only members",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setActionBudgets(testActionBudget, nil, testRecentActions, nil,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1), Resources: &testResourcesRecommendation},
+			},
+			ExpectedHorizontalActions: 2,
+			ExpectedUpdatesHeld:       true,
+			ExpectedUpdatesHeldReason: horizontalBudgetExhaustedReason,
+			ExpectedUnappliedRacks:    []string{"test-rack-1"},
+			ExpectedLastAppliedRacks:  []string{"test-rack-1"},
+		},
+		{
+			Name: "horizontal action budget doesn't limit vertical actions",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setActionBudgets(testActionBudget, nil, testRecentActions, nil,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(1), Resources: &testResourcesRecommendation},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1), Resources: &testResourcesRecommendation},
+			},
+			ExpectedHorizontalActions: 2,
+			ExpectedLastAppliedRacks:  []string{"test-rack-1"},
+		},
+		{
+			Name: "vertical action budget exhausted",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setActionBudgets(nil, testActionBudget, nil, testRecentActions,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResources},
+			},
+			ExpectedVerticalActions:   2,
+			ExpectedUpdatesHeld:       true,
+			ExpectedUpdatesHeldReason: verticalBudgetExhaustedReason,
+			ExpectedUnappliedRacks:    []string{"test-rack-1"},
+			ExpectedLastAppliedRacks:  []string{"test-rack-1"},
+		},
+		{
+			Name: "outdated actions don't count towards the budget",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setActionBudgets(testActionBudget, testActionBudget, testOutdatedActions, testOutdatedActions,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+			},
+			ExpectedHorizontalActions: 2,
+			ExpectedVerticalActions:   2,
+			ExpectedLastAppliedRacks:  []string{"test-rack-1"},
+		},
+
+		{
+			Name: "blackout window active",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setHoldWindows(testActiveBlackoutWindows, nil,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+			ExpectedUpdatesHeld: true,
+		},
+		{
+			Name: "freeze period active",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setHoldWindows(nil, testActiveFreezePeriods,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+			ExpectedUpdatesHeld: true,
+		},
+		{
+			Name: "freeze period over",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setHoldWindows(nil, testPastFreezePeriods,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2)},
+			},
+			ExpectedUpdatesHeld:      false,
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "repair task running",
+			ScyllaCluster: setRepairs([]scyllav1.RepairTaskSpec{testRunningRepair},
+				newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+					[]scyllav1.RackSpec{
+						{Name: "test-rack-1", Members: 1},
+					},
+					map[string]scyllav1.RackStatus{
+						"test-rack-1": {Members: 1, ReadyMembers: 1},
+					})),
+			Sca: setManagerTaskHold(testManagerTaskHold,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+			ExpectedUpdatesHeld: true,
+		},
+		{
+			Name: "backup task running, only repairs hold updates",
+			ScyllaCluster: setBackups([]scyllav1.BackupTaskSpec{testRunningBackup},
+				newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+					[]scyllav1.RackSpec{
+						{Name: "test-rack-1", Members: 1},
+					},
+					map[string]scyllav1.RackStatus{
+						"test-rack-1": {Members: 1, ReadyMembers: 1},
+					})),
+			Sca: setManagerTaskHold(testManagerTaskHold,
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2)},
+			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "sca paused",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setScaAnnotation(v1alpha1.PausedAnnotation, "true",
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+		},
+		{
+			Name: "rack paused on cluster",
+			ScyllaCluster: setClusterAnnotation(v1alpha1.PausedRacksAnnotation, "test-rack-1",
+				newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+					[]scyllav1.RackSpec{
+						{Name: "test-rack-1", Members: 1},
+					},
+					map[string]scyllav1.RackStatus{
+						"test-rack-1": {Members: 1, ReadyMembers: 1},
+					})),
+			Sca: newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+				"test-dc",
+				[]v1alpha1.RackRecommendations{
+					{Name: "test-rack-1", Members: util.Int32ptr(2)},
+				}),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+		},
+		{
+			Name: "pause expired",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setScaAnnotation(v1alpha1.PausedAnnotation, time.Now().Add(-time.Hour).Format(time.RFC3339),
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2)},
+			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "approval mode recommendation not approved",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setPendingRecommendation(t, false, testPublishedAt,
+				newSingleDcSca(basicTestAutoModeScaMeta, &approvalUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+			ExpectedUnappliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "approval mode recommendation approved",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setPendingRecommendation(t, true, testPublishedAt,
+				newSingleDcSca(basicTestAutoModeScaMeta, &approvalUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2)},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2)},
+			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "approval mode approved recommendation expired",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setPendingRecommendation(t, true, testExpiredPublishedAt,
+				setExpTimeAndTimestamp(&testRecExpTime, &testRecentLastUpdated,
+					newSingleDcSca(basicTestAutoModeScaMeta, &approvalUpdateMode, &updateStatusOk, basicTestClusterMeta,
+						"test-dc",
+						[]v1alpha1.RackRecommendations{
+							{Name: "test-rack-1", Members: util.Int32ptr(2)},
+						}))),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1)},
+			},
+			ExpectedUnappliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "scale-downs only recommended",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 3, Resources: testResourcesRecommendation},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 3, ReadyMembers: 3},
+				}),
+			Sca: setDirectionalUpdateModes(&v1alpha1.DirectionalUpdateModes{ScaleDown: &offUpdateMode},
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResources},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(3), Resources: &testResourcesRecommendation},
+			},
+			ExpectedUnappliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "scale-ups applied while scale-downs only recommended",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResourcesRecommendation},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setDirectionalUpdateModes(&v1alpha1.DirectionalUpdateModes{ScaleDown: &offUpdateMode},
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResources},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+			},
+			ExpectedUnappliedRacks:   []string{"test-rack-1"},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "vertical changes await approval",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setPendingRecommendation(t, false, testPublishedAt,
+				setDirectionalUpdateModes(&v1alpha1.DirectionalUpdateModes{Vertical: &approvalUpdateMode},
+					newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+						"test-dc",
+						[]v1alpha1.RackRecommendations{
+							{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+						}))),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResources},
+			},
+			ExpectedUnappliedRacks:   []string{"test-rack-1"},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "approved vertical changes applied",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setPendingRecommendation(t, true, testPublishedAt,
+				setDirectionalUpdateModes(&v1alpha1.DirectionalUpdateModes{Vertical: &approvalUpdateMode},
+					newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+						"test-dc",
+						[]v1alpha1.RackRecommendations{
+							{Name: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+						}))),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(2), Resources: &testResourcesRecommendation},
+			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "applied memory recommendation",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testMemoryResources},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+				"test-dc",
+				[]v1alpha1.RackRecommendations{
+					{Name: "test-rack-1", Members: util.Int32ptr(1), Resources: &testMemoryResourcesRecommendation},
+				}),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1), Resources: &testMemoryResourcesRecommendation},
+			},
+			ExpectedLastAppliedRacks: []string{"test-rack-1"},
+		},
+		{
+			Name: "memory scale-down only recommended",
+			ScyllaCluster: newSingleDcScyllaCluster(basicTestClusterMeta, "test-dc",
+				[]scyllav1.RackSpec{
+					{Name: "test-rack-1", Members: 1, Resources: testMemoryResourcesRecommendation},
+				},
+				map[string]scyllav1.RackStatus{
+					"test-rack-1": {Members: 1, ReadyMembers: 1},
+				}),
+			Sca: setDirectionalUpdateModes(&v1alpha1.DirectionalUpdateModes{ScaleDown: &offUpdateMode},
+				newSingleDcSca(basicTestAutoModeScaMeta, &autoUpdateMode, &updateStatusOk, basicTestClusterMeta,
+					"test-dc",
+					[]v1alpha1.RackRecommendations{
+						{Name: "test-rack-1", Members: util.Int32ptr(1), Resources: &testMemoryResources},
+					})),
+			ExpectedStates: []ExpectedStateSpec{
+				{RackName: "test-rack-1", Members: util.Int32ptr(1), Resources: &testMemoryResourcesRecommendation},
+			},
+			ExpectedUnappliedRacks: []string{"test-rack-1"},
+		},
 	}
 
 	for _, test := range tests {
@@ -253,6 +780,10 @@ func TestUpdater(t *testing.T) {
 			require.NoError(t, err, "Couldn't create scylla cluster. Message: '%s'", err)
 			err = c.Create(ctx, test.Sca)
 			require.NoError(t, err, "Couldn't create SCA. Message: '%s'", err)
+			statefulSets := newStatefulSets(test.ScyllaCluster)
+			for _, sts := range statefulSets {
+				require.NoError(t, c.Create(ctx, sts))
+			}
 
 			err = u.RunOnce(ctx)
 			require.NoError(t, err, "Updater RunOnce. Message: '%s'", err)
@@ -263,6 +794,29 @@ func TestUpdater(t *testing.T) {
 			}, cluster)
 			require.NoError(t, err, "Couldn't get scylla cluster. Message: '%s'", err)
 
+			sca := &v1alpha1.ScyllaClusterAutoscaler{}
+			err = c.Get(ctx, client.ObjectKey{
+				Namespace: test.Sca.Namespace,
+				Name:      test.Sca.Name,
+			}, sca)
+			require.NoError(t, err, "Couldn't get SCA. Message: '%s'", err)
+			require.Len(t, sca.Status.RecentHorizontalActions, test.ExpectedHorizontalActions)
+			require.Len(t, sca.Status.RecentVerticalActions, test.ExpectedVerticalActions)
+			require.Equal(t, test.ExpectedUpdatesHeld, meta.IsStatusConditionTrue(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition))
+			if test.ExpectedUpdatesHeldReason != "" {
+				require.Equal(t, test.ExpectedUpdatesHeldReason, meta.FindStatusCondition(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition).Reason)
+			}
+			var unappliedRacks []string
+			for _, dcRec := range getDatacenterRecommendations(&v1alpha1.ScyllaClusterAutoscaler{
+				Status: v1alpha1.ScyllaClusterAutoscalerStatus{Recommendations: sca.Status.UnappliedRecommendations},
+			}) {
+				for _, rackRec := range dcRec.RackRecommendations {
+					unappliedRacks = append(unappliedRacks, rackRec.Name)
+				}
+			}
+			require.Equal(t, test.ExpectedUnappliedRacks, unappliedRacks)
+			require.Equal(t, test.ExpectedLastAppliedRacks, sca.Status.LastAppliedRacks)
+
 			for _, expectedState := range test.ExpectedStates {
 				rack := findRack(expectedState.RackName, cluster.Spec.Datacenter.Racks)
 				require.NotNil(t, rack)
@@ -286,6 +840,9 @@ func TestUpdater(t *testing.T) {
 
 			err = c.Delete(ctx, test.ScyllaCluster)
 			require.NoError(t, err, "Couldn't delete scylla cluster. Message: '%s'", err)
+			for _, sts := range statefulSets {
+				require.NoError(t, c.Delete(ctx, sts))
+			}
 			err = c.Delete(ctx, test.Sca)
 			require.NoError(t, err, "Couldn't delete SCA. Message: '%s'", err)
 
@@ -309,6 +866,23 @@ func newSingleDcScyllaCluster(clusterMeta *metav1.ObjectMeta, dcName string, rac
 	}
 }
 
+// newStatefulSets returns the StatefulSets of the cluster's racks, with as many ready pods as the racks' ready members.
+func newStatefulSets(sc *scyllav1.ScyllaCluster) []client.Object {
+	var res []client.Object
+	for _, rack := range sc.Spec.Datacenter.Racks {
+		res = append(res, &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: naming.StatefulSetNameForRack(rack, sc), Namespace: sc.Namespace},
+			Spec:       appsv1.StatefulSetSpec{Replicas: util.Int32ptr(rack.Members)},
+			Status: appsv1.StatefulSetStatus{
+				Replicas:        rack.Members,
+				ReadyReplicas:   sc.Status.Racks[rack.Name].ReadyMembers,
+				UpdatedReplicas: rack.Members,
+			},
+		})
+	}
+	return res
+}
+
 func newSingleDcSca(scaMeta *metav1.ObjectMeta, updateMode *v1alpha1.UpdateMode, updateStatus *v1alpha1.UpdateStatus,
 	targetClusterMeta *metav1.ObjectMeta, dcName string, rackRecs []v1alpha1.RackRecommendations) *v1alpha1.ScyllaClusterAutoscaler {
 	return &v1alpha1.ScyllaClusterAutoscaler{
@@ -354,3 +928,101 @@ func setCooldownAndTimestamp(updateCooldown *metav1.Duration, lastApplied *metav
 	sca.Status.LastApplied = lastApplied
 	return sca
 }
+
+func setActionBudgets(horizontalBudget, verticalBudget *v1alpha1.ActionBudget, horizontalActions, verticalActions []metav1.Time,
+	sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	sca.Spec.UpdatePolicy.HorizontalActionBudget = horizontalBudget
+	sca.Spec.UpdatePolicy.VerticalActionBudget = verticalBudget
+	sca.Status.RecentHorizontalActions = horizontalActions
+	sca.Status.RecentVerticalActions = verticalActions
+	return sca
+}
+
+func setHoldWindows(blackoutWindows []v1alpha1.BlackoutWindow, freezePeriods []v1alpha1.FreezePeriod,
+	sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	sca.Spec.UpdatePolicy.BlackoutWindows = blackoutWindows
+	sca.Spec.UpdatePolicy.FreezePeriods = freezePeriods
+	return sca
+}
+
+func setManagerTaskHold(hold *v1alpha1.ManagerTaskHold, sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	sca.Spec.UpdatePolicy.ManagerTaskHold = hold
+	return sca
+}
+
+func setRepairs(repairs []scyllav1.RepairTaskSpec, cluster *scyllav1.ScyllaCluster) *scyllav1.ScyllaCluster {
+	cluster.Spec.Repairs = repairs
+	return cluster
+}
+
+func setBackups(backups []scyllav1.BackupTaskSpec, cluster *scyllav1.ScyllaCluster) *scyllav1.ScyllaCluster {
+	cluster.Spec.Backups = backups
+	return cluster
+}
+
+func setClusterAnnotation(key, value string, cluster *scyllav1.ScyllaCluster) *scyllav1.ScyllaCluster {
+	cluster.ObjectMeta.Annotations = map[string]string{key: value}
+	return cluster
+}
+
+func setScaAnnotation(key, value string, sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	sca.ObjectMeta.Annotations = map[string]string{key: value}
+	return sca
+}
+
+func setPendingRecommendation(t *testing.T, approved bool, publishedAt metav1.Time,
+	sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	id, err := util.PendingRecommendationID(sca.Status.Recommendations, publishedAt)
+	require.NoError(t, err, "Couldn't get pending recommendation ID. Message: '%s'", err)
+	sca.Status.PendingRecommendation = &v1alpha1.PendingRecommendation{ID: id, PublishedAt: publishedAt}
+	if approved {
+		sca.ObjectMeta.Annotations = map[string]string{v1alpha1.ApprovedRecommendationAnnotation: id}
+	}
+	return sca
+}
+
+func setDirectionalUpdateModes(modes *v1alpha1.DirectionalUpdateModes,
+	sca *v1alpha1.ScyllaClusterAutoscaler) *v1alpha1.ScyllaClusterAutoscaler {
+	sca.Spec.UpdatePolicy.DirectionalUpdateModes = modes
+	return sca
+}
+
+func TestBudgetAvailableAt(t *testing.T) {
+	now := time.Now()
+	actions := []metav1.Time{
+		metav1.NewTime(now.Add(-time.Hour)),
+		metav1.NewTime(now.Add(-3 * time.Hour)),
+		metav1.NewTime(now.Add(-2 * time.Hour)),
+	}
+	window := metav1.Duration{Duration: 24 * time.Hour}
+
+	// The oldest action frees the budget.
+	require.True(t, now.Add(21*time.Hour).Equal(
+		budgetAvailableAt(actions, &v1alpha1.ActionBudget{MaxActions: 3, Window: window})))
+	// The budget was lowered below the number of recent actions, so the two oldest have to leave the window.
+	require.True(t, now.Add(22*time.Hour).Equal(
+		budgetAvailableAt(actions, &v1alpha1.ActionBudget{MaxActions: 2, Window: window})))
+}
+
+func TestSetBudgetHoldCondition(t *testing.T) {
+	now := time.Now()
+	budget := &v1alpha1.ActionBudget{MaxActions: 1, Window: metav1.Duration{Duration: time.Hour}}
+	actions := []metav1.Time{metav1.NewTime(now.Add(-time.Minute))}
+	sca := &v1alpha1.ScyllaClusterAutoscaler{
+		Spec: v1alpha1.ScyllaClusterAutoscalerSpec{
+			UpdatePolicy: &v1alpha1.UpdatePolicy{VerticalActionBudget: budget},
+		},
+	}
+
+	require.True(t, setBudgetHoldCondition(sca, &exhaustedBudgets{vertical: true, heldVertical: true}, nil, actions))
+	c := meta.FindStatusCondition(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition)
+	require.Equal(t, metav1.ConditionTrue, c.Status)
+	require.Equal(t, verticalBudgetExhaustedReason, c.Reason)
+	require.Contains(t, c.Message, now.Add(59*time.Minute).UTC().Format(time.RFC3339))
+	require.False(t, setBudgetHoldCondition(sca, &exhaustedBudgets{vertical: true, heldVertical: true}, nil, actions))
+
+	// The condition is cleared once the budget doesn't hold back anything.
+	require.True(t, setBudgetHoldCondition(sca, &exhaustedBudgets{}, nil, nil))
+	require.False(t, meta.IsStatusConditionTrue(sca.Status.Conditions, v1alpha1.UpdatesHeldCondition))
+	require.False(t, setBudgetHoldCondition(sca, &exhaustedBudgets{}, nil, nil))
+}
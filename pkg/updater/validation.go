@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/util"
+)
+
+// ValidateUpdatePolicy checks the blackout windows, freeze periods and Scylla Manager task hold of the policy,
+// so that invalid ones can be rejected before the updater relies on them.
+func ValidateUpdatePolicy(updatePolicy *v1alpha1.UpdatePolicy) error {
+	if updatePolicy == nil {
+		return nil
+	}
+
+	for _, w := range updatePolicy.BlackoutWindows {
+		if _, _, err := util.ParseCronWindow(w.Schedule, w.TimeZone); err != nil {
+			return errors.Wrapf(err, "blackout window \"%s\"", w.Name)
+		}
+		if w.Duration.Duration <= 0 {
+			return errors.Errorf("blackout window \"%s\": duration has to be positive", w.Name)
+		}
+	}
+
+	for _, p := range updatePolicy.FreezePeriods {
+		if !p.End.After(p.Start.Time) {
+			return errors.Errorf("freeze period \"%s\": end has to be after start", p.Name)
+		}
+	}
+
+	if hold := updatePolicy.ManagerTaskHold; hold != nil {
+		for _, t := range hold.TaskTypes {
+			if t != v1alpha1.ManagerTaskTypeRepair && t != v1alpha1.ManagerTaskTypeBackup {
+				return errors.Errorf("manager task hold: unknown task type \"%s\"", t)
+			}
+		}
+		if hold.ExpectedDuration.Duration <= 0 {
+			return errors.New("manager task hold: expected duration has to be positive")
+		}
+		if hold.Lead != nil && hold.Lead.Duration < 0 {
+			return errors.New("manager task hold: lead can't be negative")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateUpdatePolicy(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		updatePolicy  *v1alpha1.UpdatePolicy
+		errorExpected bool
+	}{
+		{
+			name:         "no update policy",
+			updatePolicy: nil,
+		},
+		{
+			name: "valid windows",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Name: "nightly", Schedule: "0 22 * * *", TimeZone: "Europe/Warsaw", Duration: metav1.Duration{Duration: 6 * time.Hour}},
+				},
+				FreezePeriods: []v1alpha1.FreezePeriod{
+					{Name: "release", Start: metav1.NewTime(now), End: metav1.NewTime(now.Add(time.Hour))},
+				},
+			},
+		},
+		{
+			name: "malformed schedule",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Name: "nightly", Schedule: "0 22 * *", Duration: metav1.Duration{Duration: time.Hour}},
+				},
+			},
+			errorExpected: true,
+		},
+		{
+			name: "unknown time zone",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Name: "nightly", Schedule: "0 22 * * *", TimeZone: "Mars/Olympus_Mons", Duration: metav1.Duration{Duration: time.Hour}},
+				},
+			},
+			errorExpected: true,
+		},
+		{
+			name: "non-positive duration",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Name: "nightly", Schedule: "0 22 * * *"},
+				},
+			},
+			errorExpected: true,
+		},
+		{
+			name: "freeze period ending before its start",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				FreezePeriods: []v1alpha1.FreezePeriod{
+					{Name: "release", Start: metav1.NewTime(now), End: metav1.NewTime(now.Add(-time.Hour))},
+				},
+			},
+			errorExpected: true,
+		},
+		{
+			name: "valid manager task hold",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				ManagerTaskHold: &v1alpha1.ManagerTaskHold{
+					TaskTypes:        []v1alpha1.ManagerTaskType{v1alpha1.ManagerTaskTypeRepair},
+					ExpectedDuration: metav1.Duration{Duration: 4 * time.Hour},
+					Lead:             &metav1.Duration{Duration: 30 * time.Minute},
+				},
+			},
+		},
+		{
+			name: "manager task hold without expected duration",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				ManagerTaskHold: &v1alpha1.ManagerTaskHold{},
+			},
+			errorExpected: true,
+		},
+		{
+			name: "manager task hold of unknown task type",
+			updatePolicy: &v1alpha1.UpdatePolicy{
+				ManagerTaskHold: &v1alpha1.ManagerTaskHold{
+					TaskTypes:        []v1alpha1.ManagerTaskType{"Compaction"},
+					ExpectedDuration: metav1.Duration{Duration: time.Hour},
+				},
+			},
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateUpdatePolicy(test.updatePolicy)
+			if test.errorExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
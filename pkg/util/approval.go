@@ -0,0 +1,26 @@
+package util
+
+import (
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LatestChecksumLabel is the label of the ScyllaCluster holding the checksum of the latest applied recommendations.
+const LatestChecksumLabel = "sca-latest-checksum"
+
+// PendingRecommendationID returns the ID of the recommendations published for approval at publishedAt.
+// Publishing the same recommendations again results in a different ID, so that earlier approvals don't apply.
+func PendingRecommendationID(recs *v1alpha1.ScyllaClusterRecommendations, publishedAt metav1.Time) (string, error) {
+	return NewChecksum(struct {
+		Recommendations *v1alpha1.ScyllaClusterRecommendations `json:"recommendations"`
+		PublishedAt     metav1.Time                            `json:"publishedAt"`
+	}{recs, publishedAt})
+}
+
+// PendingRecommendationExpired tells whether the pending recommendation can no longer be approved at now.
+// If expirationTime is nil, pending recommendations don't expire.
+func PendingRecommendationExpired(pending *v1alpha1.PendingRecommendation, expirationTime *metav1.Duration, now time.Time) bool {
+	return expirationTime != nil && now.Sub(pending.PublishedAt.Time) > expirationTime.Duration
+}
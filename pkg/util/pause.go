@@ -0,0 +1,105 @@
+package util
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Pause is the pause of autoscaling requested with the pause annotations of an SCA and its target.
+type Pause struct {
+	// Paused tells whether autoscaling of the whole target is paused.
+	Paused bool
+	// Until is the expiry of the pause of the whole target, zero if it doesn't expire.
+	Until time.Time
+	// Racks maps the names of the paused racks to the expiries of their pauses, zero if they don't expire.
+	Racks map[string]time.Time
+}
+
+// PauseFromAnnotations returns the pause requested with PausedAnnotation and PausedRacksAnnotation of the objects.
+// Pauses expired before now are ignored. If several objects pause the same target, the longest pause wins.
+func PauseFromAnnotations(now time.Time, objects ...metav1.Object) *Pause {
+	p := &Pause{Racks: map[string]time.Time{}}
+	for _, obj := range objects {
+		annotations := obj.GetAnnotations()
+
+		if value, ok := annotations[v1alpha1.PausedAnnotation]; ok {
+			if until, paused := pausedUntil(value, now); paused {
+				p.Until = longerPause(p.Paused, p.Until, until)
+				p.Paused = true
+			}
+		}
+
+		value, ok := annotations[v1alpha1.PausedRacksAnnotation]
+		if !ok {
+			continue
+		}
+		for _, entry := range strings.Split(value, ",") {
+			name, expiry := strings.TrimSpace(entry), "true"
+			if i := strings.Index(name, "="); i >= 0 {
+				name, expiry = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+			}
+			if name == "" {
+				continue
+			}
+			if until, paused := pausedUntil(expiry, now); paused {
+				prev, ok := p.Racks[name]
+				p.Racks[name] = longerPause(ok, prev, until)
+			}
+		}
+	}
+
+	return p
+}
+
+// RackPaused tells whether autoscaling of the rack is paused, either on its own or along with the whole target.
+func (p *Pause) RackPaused(rack string) bool {
+	if p.Paused {
+		return true
+	}
+	_, ok := p.Racks[rack]
+	return ok
+}
+
+// PausedRacks returns the sorted names of the paused racks.
+func (p *Pause) PausedRacks() []string {
+	if len(p.Racks) == 0 {
+		return nil
+	}
+
+	res := make([]string, 0, len(p.Racks))
+	for name := range p.Racks {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// pausedUntil parses the value of a pause annotation. "false" means no pause and an RFC 3339 timestamp
+// means a pause until then, while any other value means a pause that doesn't expire.
+func pausedUntil(value string, now time.Time) (time.Time, bool) {
+	if value == "false" {
+		return time.Time{}, false
+	}
+	if until, err := time.Parse(time.RFC3339, value); err == nil {
+		return until, now.Before(until)
+	}
+	return time.Time{}, true
+}
+
+// longerPause returns the expiry of the longer of two pauses, the first of which may not be set.
+func longerPause(set bool, prev, until time.Time) time.Time {
+	if !set {
+		return until
+	}
+	if prev.IsZero() || until.IsZero() {
+		return time.Time{}
+	}
+	if until.After(prev) {
+		return until
+	}
+	return prev
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPauseFromAnnotations(t *testing.T) {
+	now := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	latest := now.Add(2 * time.Hour)
+	withAnnotations := func(annotations map[string]string) metav1.Object {
+		return &metav1.ObjectMeta{Annotations: annotations}
+	}
+
+	tests := []struct {
+		name     string
+		objects  []metav1.Object
+		expected *Pause
+	}{
+		{
+			name:     "no annotations",
+			objects:  []metav1.Object{withAnnotations(nil)},
+			expected: &Pause{Racks: map[string]time.Time{}},
+		},
+		{
+			name:     "indefinite pause",
+			objects:  []metav1.Object{withAnnotations(map[string]string{v1alpha1.PausedAnnotation: "true"})},
+			expected: &Pause{Paused: true, Racks: map[string]time.Time{}},
+		},
+		{
+			name:     "unpaused",
+			objects:  []metav1.Object{withAnnotations(map[string]string{v1alpha1.PausedAnnotation: "false"})},
+			expected: &Pause{Racks: map[string]time.Time{}},
+		},
+		{
+			name:     "expired pause",
+			objects:  []metav1.Object{withAnnotations(map[string]string{v1alpha1.PausedAnnotation: now.Format(time.RFC3339)})},
+			expected: &Pause{Racks: map[string]time.Time{}},
+		},
+		{
+			name: "longest pause wins",
+			objects: []metav1.Object{
+				withAnnotations(map[string]string{v1alpha1.PausedAnnotation: latest.Format(time.RFC3339)}),
+				withAnnotations(map[string]string{v1alpha1.PausedAnnotation: later.Format(time.RFC3339)}),
+			},
+			expected: &Pause{Paused: true, Until: latest, Racks: map[string]time.Time{}},
+		},
+		{
+			name: "paused racks",
+			objects: []metav1.Object{
+				withAnnotations(map[string]string{v1alpha1.PausedRacksAnnotation: "a, b=" + later.Format(time.RFC3339) + ",c=" + now.Format(time.RFC3339)}),
+				withAnnotations(map[string]string{v1alpha1.PausedRacksAnnotation: "b=" + latest.Format(time.RFC3339) + ",a=" + later.Format(time.RFC3339)}),
+			},
+			expected: &Pause{Racks: map[string]time.Time{"a": {}, "b": latest}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, PauseFromAnnotations(now, test.objects...))
+		})
+	}
+}
+
+func TestPauseRackPaused(t *testing.T) {
+	p := &Pause{Racks: map[string]time.Time{"b": {}, "a": {}}}
+	require.True(t, p.RackPaused("a"))
+	require.False(t, p.RackPaused("c"))
+	require.Equal(t, []string{"a", "b"}, p.PausedRacks())
+
+	p.Paused = true
+	require.True(t, p.RackPaused("c"))
+	require.Nil(t, (&Pause{}).PausedRacks())
+}
@@ -0,0 +1,126 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/scylladb/scylla-operator/pkg/naming"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of a ScyllaCluster not being ready. Rack conditions in progress are reported with their types as reasons,
+// e.g. "MemberLeaving".
+const (
+	TargetReadyReason                  = "Ready"
+	RackStatusMissingReason            = "RackStatusMissing"
+	ReplacementPendingReason           = "ReplacementPending"
+	VersionMismatchReason              = "VersionMismatch"
+	MembersChangingReason              = "MembersChanging"
+	MembersNotReadyReason              = "MembersNotReady"
+	StatefulSetMissingReason           = "StatefulSetMissing"
+	StatefulSetPodsNotReadyReason      = "StatefulSetPodsNotReady"
+	StatefulSetRolloutInProgressReason = "StatefulSetRolloutInProgress"
+)
+
+// TargetReadiness tells whether a ScyllaCluster is stable enough for its recommendations to be prepared and applied,
+// and if not, why.
+type TargetReadiness struct {
+	Ready bool
+	// Reason is the reason of the target not being ready, or TargetReadyReason.
+	Reason string
+	// Rack is the name of the first rack found not ready.
+	Rack string
+	// Message is the human readable description of the reason.
+	Message string
+}
+
+// EvaluateTargetReadiness checks the racks of the cluster, in the order of its spec. A rack isn't ready if any of its
+// conditions is true, any of its members awaits replacement, it runs a Scylla version other than the one of the spec,
+// its members don't match the spec or aren't all ready, or the pods of its StatefulSet aren't all ready and updated.
+func EvaluateTargetReadiness(ctx context.Context, c client.Reader, cluster *scyllav1.ScyllaCluster) (*TargetReadiness, error) {
+	for i := range cluster.Spec.Datacenter.Racks {
+		readiness, err := EvaluateRackReadiness(ctx, c, cluster, &cluster.Spec.Datacenter.Racks[i])
+		if err != nil || !readiness.Ready {
+			return readiness, err
+		}
+	}
+
+	return &TargetReadiness{Ready: true, Reason: TargetReadyReason, Message: "Target is ready."}, nil
+}
+
+// EvaluateRackReadiness checks a single rack of the cluster the same way EvaluateTargetReadiness does.
+func EvaluateRackReadiness(ctx context.Context, c client.Reader, cluster *scyllav1.ScyllaCluster, rack *scyllav1.RackSpec) (*TargetReadiness, error) {
+	rackStatus, found := cluster.Status.Racks[rack.Name]
+	if !found {
+		return notReady(rack.Name, RackStatusMissingReason, "status of rack \"%s\" is missing", rack.Name), nil
+	}
+	if readiness := rackStatusReadiness(cluster, rack, &rackStatus); readiness != nil {
+		return readiness, nil
+	}
+
+	sts := &appsv1.StatefulSet{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: naming.StatefulSetNameForRack(*rack, cluster)}, sts)
+	if apierrors.IsNotFound(err) {
+		return notReady(rack.Name, StatefulSetMissingReason, "StatefulSet of rack \"%s\" doesn't exist", rack.Name), nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "get StatefulSet of rack \"%s\"", rack.Name)
+	}
+	if readiness := statefulSetReadiness(rack.Name, sts); readiness != nil {
+		return readiness, nil
+	}
+
+	return &TargetReadiness{Ready: true, Rack: rack.Name, Reason: TargetReadyReason, Message: "Target is ready."}, nil
+}
+
+func rackStatusReadiness(cluster *scyllav1.ScyllaCluster, rack *scyllav1.RackSpec, rackStatus *scyllav1.RackStatus) *TargetReadiness {
+	for _, condition := range rackStatus.Conditions {
+		if condition.Status == corev1.ConditionTrue {
+			return notReady(rack.Name, string(condition.Type), "rack \"%s\" is in condition %s", rack.Name, condition.Type)
+		}
+	}
+	if len(rackStatus.ReplaceAddressFirstBoot) > 0 {
+		return notReady(rack.Name, ReplacementPendingReason, "%d member(s) of rack \"%s\" await replacement",
+			len(rackStatus.ReplaceAddressFirstBoot), rack.Name)
+	}
+	// The version isn't reported until the rack's members are up.
+	if rackStatus.Version != "" && rackStatus.Version != cluster.Spec.Version {
+		return notReady(rack.Name, VersionMismatchReason, "rack \"%s\" runs version %s instead of %s",
+			rack.Name, rackStatus.Version, cluster.Spec.Version)
+	}
+	if rackStatus.Members != rack.Members {
+		return notReady(rack.Name, MembersChangingReason, "rack \"%s\" has %d member(s) instead of %d",
+			rack.Name, rackStatus.Members, rack.Members)
+	}
+	if rackStatus.ReadyMembers != rackStatus.Members {
+		return notReady(rack.Name, MembersNotReadyReason, "%d of %d member(s) of rack \"%s\" are ready",
+			rackStatus.ReadyMembers, rackStatus.Members, rack.Name)
+	}
+	return nil
+}
+
+func statefulSetReadiness(rack string, sts *appsv1.StatefulSet) *TargetReadiness {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Status.UpdatedReplicas != replicas ||
+		(sts.Status.UpdateRevision != "" && sts.Status.CurrentRevision != sts.Status.UpdateRevision) {
+		return notReady(rack, StatefulSetRolloutInProgressReason, "%d of %d pod(s) of rack \"%s\" are updated",
+			sts.Status.UpdatedReplicas, replicas, rack)
+	}
+	if sts.Status.ReadyReplicas != replicas {
+		return notReady(rack, StatefulSetPodsNotReadyReason, "%d of %d pod(s) of rack \"%s\" are ready",
+			sts.Status.ReadyReplicas, replicas, rack)
+	}
+	return nil
+}
+
+func notReady(rack, reason, format string, args ...interface{}) *TargetReadiness {
+	return &TargetReadiness{Rack: rack, Reason: reason, Message: "Target isn't ready: " + fmt.Sprintf(format, args...) + "."}
+}
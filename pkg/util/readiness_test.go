@@ -0,0 +1,144 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	scyllav1 "github.com/scylladb/scylla-operator/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEvaluateTargetReadiness(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	newCluster := func(status scyllav1.RackStatus) *scyllav1.ScyllaCluster {
+		return &scyllav1.ScyllaCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "ns"},
+			Spec: scyllav1.ClusterSpec{
+				Version: "4.4.0",
+				Datacenter: scyllav1.DatacenterSpec{
+					Name:  "dc",
+					Racks: []scyllav1.RackSpec{{Name: "rack", Members: 3}},
+				},
+			},
+			Status: scyllav1.ClusterStatus{Racks: map[string]scyllav1.RackStatus{"rack": status}},
+		}
+	}
+	readyStatus := scyllav1.RackStatus{Version: "4.4.0", Members: 3, ReadyMembers: 3}
+	newStatefulSet := func(status appsv1.StatefulSetStatus) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-dc-rack", Namespace: "ns"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: Int32ptr(3)},
+			Status:     status,
+		}
+	}
+	readyStatefulSet := newStatefulSet(appsv1.StatefulSetStatus{
+		Replicas: 3, ReadyReplicas: 3, UpdatedReplicas: 3, CurrentRevision: "rev-1", UpdateRevision: "rev-1",
+	})
+
+	tests := []struct {
+		name           string
+		cluster        *scyllav1.ScyllaCluster
+		statefulSet    *appsv1.StatefulSet
+		expectedReason string
+	}{
+		{
+			name:           "ready",
+			cluster:        newCluster(readyStatus),
+			statefulSet:    readyStatefulSet,
+			expectedReason: TargetReadyReason,
+		},
+		{
+			name: "rack status missing",
+			cluster: func() *scyllav1.ScyllaCluster {
+				sc := newCluster(readyStatus)
+				sc.Status.Racks = nil
+				return sc
+			}(),
+			statefulSet:    readyStatefulSet,
+			expectedReason: RackStatusMissingReason,
+		},
+		{
+			name: "member leaving",
+			cluster: newCluster(scyllav1.RackStatus{Version: "4.4.0", Members: 3, ReadyMembers: 3, Conditions: []scyllav1.RackCondition{
+				{Type: scyllav1.RackConditionTypeMemberReplacing, Status: corev1.ConditionFalse},
+				{Type: scyllav1.RackConditionTypeMemberLeaving, Status: corev1.ConditionTrue},
+			}}),
+			statefulSet:    readyStatefulSet,
+			expectedReason: string(scyllav1.RackConditionTypeMemberLeaving),
+		},
+		{
+			name: "replacement pending",
+			cluster: newCluster(scyllav1.RackStatus{Version: "4.4.0", Members: 3, ReadyMembers: 3,
+				ReplaceAddressFirstBoot: map[string]string{"cluster-dc-rack-1": "10.0.0.1"}}),
+			statefulSet:    readyStatefulSet,
+			expectedReason: ReplacementPendingReason,
+		},
+		{
+			name:           "version mismatch",
+			cluster:        newCluster(scyllav1.RackStatus{Version: "4.3.0", Members: 3, ReadyMembers: 3}),
+			statefulSet:    readyStatefulSet,
+			expectedReason: VersionMismatchReason,
+		},
+		{
+			name:           "members changing",
+			cluster:        newCluster(scyllav1.RackStatus{Version: "4.4.0", Members: 2, ReadyMembers: 2}),
+			statefulSet:    readyStatefulSet,
+			expectedReason: MembersChangingReason,
+		},
+		{
+			name:           "members not ready",
+			cluster:        newCluster(scyllav1.RackStatus{Version: "4.4.0", Members: 3, ReadyMembers: 2}),
+			statefulSet:    readyStatefulSet,
+			expectedReason: MembersNotReadyReason,
+		},
+		{
+			name:           "StatefulSet missing",
+			cluster:        newCluster(readyStatus),
+			expectedReason: StatefulSetMissingReason,
+		},
+		{
+			name:    "StatefulSet pods not ready",
+			cluster: newCluster(readyStatus),
+			statefulSet: newStatefulSet(appsv1.StatefulSetStatus{
+				Replicas: 3, ReadyReplicas: 2, UpdatedReplicas: 3, CurrentRevision: "rev-1", UpdateRevision: "rev-1",
+			}),
+			expectedReason: StatefulSetPodsNotReadyReason,
+		},
+		{
+			name:    "StatefulSet rollout in progress",
+			cluster: newCluster(readyStatus),
+			statefulSet: newStatefulSet(appsv1.StatefulSetStatus{
+				Replicas: 3, ReadyReplicas: 3, UpdatedReplicas: 1, CurrentRevision: "rev-1", UpdateRevision: "rev-2",
+			}),
+			expectedReason: StatefulSetRolloutInProgressReason,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []client.Object
+			if test.statefulSet != nil {
+				objects = append(objects, test.statefulSet)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			readiness, err := EvaluateTargetReadiness(context.Background(), c, test.cluster)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedReason, readiness.Reason)
+			require.Equal(t, test.expectedReason == TargetReadyReason, readiness.Ready)
+			if !readiness.Ready {
+				require.Equal(t, "rack", readiness.Rack)
+				require.Contains(t, readiness.Message, "rack \"rack\"")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+)
+
+// updateModeRestrictiveness orders the update modes from the least to the most restrictive.
+var updateModeRestrictiveness = map[v1alpha1.UpdateMode]int{
+	v1alpha1.UpdateModeAuto:     0,
+	v1alpha1.UpdateModeApproval: 1,
+	v1alpha1.UpdateModeOff:      2,
+}
+
+// EffectiveUpdateMode returns the update mode of a change in the given dimension and direction.
+// The overrides of the dimension and the direction take precedence over the policy's update mode,
+// and the more restrictive of them applies if both are set.
+func EffectiveUpdateMode(updatePolicy *v1alpha1.UpdatePolicy, horizontal, scaleUp bool) v1alpha1.UpdateMode {
+	overrides := updatePolicy.DirectionalUpdateModes
+	if overrides == nil {
+		return updatePolicy.UpdateMode
+	}
+
+	dimension, direction := overrides.Vertical, overrides.ScaleDown
+	if horizontal {
+		dimension = overrides.Horizontal
+	}
+	if scaleUp {
+		direction = overrides.ScaleUp
+	}
+
+	switch {
+	case dimension == nil && direction == nil:
+		return updatePolicy.UpdateMode
+	case dimension == nil:
+		return *direction
+	case direction == nil:
+		return *dimension
+	case updateModeRestrictiveness[*direction] > updateModeRestrictiveness[*dimension]:
+		return *direction
+	default:
+		return *dimension
+	}
+}
+
+// UpdateModes returns the update modes of the changes in all dimensions and directions.
+func UpdateModes(updatePolicy *v1alpha1.UpdatePolicy) []v1alpha1.UpdateMode {
+	var modes []v1alpha1.UpdateMode
+	for _, horizontal := range []bool{true, false} {
+		for _, scaleUp := range []bool{true, false} {
+			modes = append(modes, EffectiveUpdateMode(updatePolicy, horizontal, scaleUp))
+		}
+	}
+	return modes
+}
+
+// UpdatesEnabled tells whether any changes can be applied to the target under the update policy.
+func UpdatesEnabled(updatePolicy *v1alpha1.UpdatePolicy) bool {
+	for _, mode := range UpdateModes(updatePolicy) {
+		if mode != v1alpha1.UpdateModeOff {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalRequired tells whether any changes have to be approved before being applied under the update policy.
+func ApprovalRequired(updatePolicy *v1alpha1.UpdatePolicy) bool {
+	for _, mode := range UpdateModes(updatePolicy) {
+		if mode == v1alpha1.UpdateModeApproval {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/scylladb/scylla-operator-autoscaler/pkg/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveUpdateMode(t *testing.T) {
+	off, approval, auto := v1alpha1.UpdateModeOff, v1alpha1.UpdateModeApproval, v1alpha1.UpdateModeAuto
+
+	tests := []struct {
+		name       string
+		policy     *v1alpha1.UpdatePolicy
+		horizontal bool
+		scaleUp    bool
+		expected   v1alpha1.UpdateMode
+	}{
+		{
+			name:       "no overrides",
+			policy:     &v1alpha1.UpdatePolicy{UpdateMode: auto},
+			horizontal: true,
+			scaleUp:    false,
+			expected:   auto,
+		},
+		{
+			name:       "other direction overridden",
+			policy:     &v1alpha1.UpdatePolicy{UpdateMode: auto, DirectionalUpdateModes: &v1alpha1.DirectionalUpdateModes{ScaleDown: &off}},
+			horizontal: true,
+			scaleUp:    true,
+			expected:   auto,
+		},
+		{
+			name:       "direction overridden",
+			policy:     &v1alpha1.UpdatePolicy{UpdateMode: auto, DirectionalUpdateModes: &v1alpha1.DirectionalUpdateModes{ScaleDown: &off}},
+			horizontal: true,
+			scaleUp:    false,
+			expected:   off,
+		},
+		{
+			name:       "override less restrictive than update mode",
+			policy:     &v1alpha1.UpdatePolicy{UpdateMode: approval, DirectionalUpdateModes: &v1alpha1.DirectionalUpdateModes{ScaleUp: &auto}},
+			horizontal: false,
+			scaleUp:    true,
+			expected:   auto,
+		},
+		{
+			name: "more restrictive of dimension and direction",
+			policy: &v1alpha1.UpdatePolicy{UpdateMode: auto, DirectionalUpdateModes: &v1alpha1.DirectionalUpdateModes{
+				ScaleUp:  &auto,
+				Vertical: &approval,
+			}},
+			horizontal: false,
+			scaleUp:    true,
+			expected:   approval,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, EffectiveUpdateMode(test.policy, test.horizontal, test.scaleUp))
+		})
+	}
+}
+
+func TestUpdatesEnabledAndApprovalRequired(t *testing.T) {
+	off, approval := v1alpha1.UpdateModeOff, v1alpha1.UpdateModeApproval
+
+	policy := &v1alpha1.UpdatePolicy{UpdateMode: off}
+	require.False(t, UpdatesEnabled(policy))
+	require.False(t, ApprovalRequired(policy))
+
+	policy.DirectionalUpdateModes = &v1alpha1.DirectionalUpdateModes{ScaleUp: &approval}
+	require.True(t, UpdatesEnabled(policy))
+	require.True(t, ApprovalRequired(policy))
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	// Time zones of the windows are resolved without relying on the image's zoneinfo.
+	_ "time/tzdata"
+)
+
+// ParseCronWindow parses a cron expression in the standard five-field format and the IANA time zone
+// it is evaluated in. An empty time zone means UTC.
+func ParseCronWindow(schedule, timeZone string) (cron.Schedule, *time.Location, error) {
+	location := time.UTC
+	if timeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, nil, errors.Wrap(err, "time zone")
+		}
+	}
+
+	s, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "schedule")
+	}
+
+	return s, location, nil
+}
+
+// CronWindowEnd returns the end of the window active at now, i.e. started by the schedule less than duration ago.
+// It returns false if no window is active.
+func CronWindowEnd(schedule, timeZone string, duration time.Duration, now time.Time) (time.Time, bool, error) {
+	s, location, err := ParseCronWindow(schedule, timeZone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	start := s.Next(now.In(location).Add(-duration))
+	if start.After(now) {
+		return time.Time{}, false, nil
+	}
+	return start.Add(duration), true, nil
+}